@@ -1,19 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	_ "net/http/pprof"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/clicks"
 	"github.com/GTedya/shortener/internal/app/handlers"
+	"github.com/GTedya/shortener/internal/app/idgen"
 	"github.com/GTedya/shortener/internal/app/logger"
 	"github.com/GTedya/shortener/internal/app/middlewares"
 	pb "github.com/GTedya/shortener/internal/app/proto"
 	"github.com/GTedya/shortener/internal/app/repository"
 	"github.com/GTedya/shortener/internal/app/server"
 	"github.com/GTedya/shortener/internal/app/service"
+	"github.com/GTedya/shortener/internal/app/sweeper"
+	"github.com/GTedya/shortener/internal/app/tokenutils"
 )
 
 var (
@@ -23,7 +31,7 @@ var (
 )
 
 // main - основная функция, которая инициализирует конфигурацию, логгер, базу данных,
-// запускает миграции и запускает сервер.
+// запускает миграции и запускает HTTP- и gRPC-серверы до получения сигнала остановки.
 func main() {
 	// Print environment flags
 	fmt.Printf("Build version: %s\n", buildVersion)
@@ -36,28 +44,96 @@ func main() {
 	log := logger.CreateLogger()
 	repo := repository.GetRepo(conf)
 
-	handler, err := handlers.NewHandler(log, conf)
+	// Файловое хранилище восстанавливается при открытии, поврежденные записи сохраняются в отдельный файл.
+	if fileRepo, ok := repo.(*repository.FileRepository); ok {
+		if report := fileRepo.Recovery(); report != (repository.RecoveryReport{}) {
+			log.Warnw("file storage recovered", "truncated", report.Truncated, "quarantined", report.Quarantined)
+		}
+	}
+
+	// Последовательные генераторы продолжают нумерацию после наибольшего номера среди сохраненных URL.
+	start, err := idgen.Start(context.Background(), conf, repo)
 	if err != nil {
-		log.Errorw("handler creation error", err)
+		log.Errorw("id sequence start error", err)
+		return
+	}
+	gen, err := idgen.New(conf, start)
+	if err != nil {
+		log.Errorw("id generator creation error", err)
+		return
 	}
-	shortener := service.NewShortener(repo, &conf)
 
-	grpcServer, err := pb.NewGRPCServer(shortener, conf)
+	// Фоновые задачи работают до остановки серверов.
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+
+	// Переходы по ссылкам сохраняются в фоне пачками.
+	recorder := clicks.NewRecorder(repo, log, conf.ClickBuffer, conf.ClickFlush.Duration)
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		recorder.Run(jobsCtx)
+	}()
+
+	// Фоновое удаление истекших ссылок и окончательное удаление ссылок, удаленных дольше срока восстановления.
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		sweeper.New(repo, conf.SweepInterval.Duration, conf.DeleteGrace.Duration, log).Run(jobsCtx)
+	}()
+
+	// Единый сервис с общим хранилищем используется и HTTP-, и gRPC-сервером.
+	shortener := service.NewShortener(repo, gen, &conf)
+
+	// Токены пользователей выдаются и проверяются одними ключами в HTTP и gRPC.
+	tokens, err := tokenutils.NewKeyring(conf)
 	if err != nil {
+		log.Errorw("token keys error", err)
 		return
 	}
-	err = grpcServer.Run()
+
+	handler, err := handlers.NewHandler(log, conf, shortener, tokens, recorder)
 	if err != nil {
+		log.Errorw("handler creation error", err)
 		return
 	}
 
-	middle := middlewares.Middleware{Log: log, SecretKey: conf.SecretKey, TrustedSubnet: conf.TrustedSubnet}
+	grpcServer, err := pb.NewGRPCServer(shortener, tokens, conf, log)
+	if err != nil {
+		log.Errorw("gRPC server creation error", err)
+		return
+	}
+
+	middle := middlewares.Middleware{
+		Log:           log,
+		Tokens:        tokens,
+		APIKeys:       shortener,
+		TrustedSubnet: conf.TrustedSubnet,
+		HSTSMaxAge:    conf.HSTSMaxAge.Duration,
+	}
 
 	router := chi.NewRouter()
+	httpServer := server.NewHTTPServer(conf, log, router, handler, middle)
+	runners := []server.Runner{httpServer, grpcServer}
 
-	// Запуск сервера.
-	err = server.Start(conf, log, router, handler, middle)
-	if err != nil {
-		log.Errorw("server starting error", err)
+	// HTTP-клиенты перенаправляются на HTTPS вместо ошибки соединения.
+	if conf.EnableHTTPS && conf.RedirectAddress != "" {
+		runners = append(runners, server.NewRedirectServer(conf, chi.NewRouter(), handler, middle))
+	}
+
+	// Все серверы работают вместе и останавливаются вместе по сигналу.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
+	if err = server.Run(ctx, log, runners...); err != nil {
+		log.Errorw("server running error", err)
+	}
+
+	// После остановки серверов сохраняются оставшиеся переходы, затем закрывается хранилище.
+	stopJobs()
+	jobs.Wait()
+
+	if err = repo.Close(context.Background()); err != nil {
+		log.Errorw("repository closing error", err)
 	}
 }
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is time.Duration that is read from JSON config as a string like "1m30s".
+// Plain numbers are treated as nanoseconds, the same as in time.Duration.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses duration from JSON string or number.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Errorf("duration unmarshalling error: %w", err)
+	}
+
+	switch value := v.(type) {
+	case float64:
+		d.Duration = time.Duration(value)
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("duration parsing error: %w", err)
+		}
+		d.Duration = parsed
+	default:
+		return fmt.Errorf("invalid duration: %s", b)
+	}
+	return nil
+}
+
+// MarshalJSON writes duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(d.String())
+	if err != nil {
+		return nil, fmt.Errorf("duration marshalling error: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,148 @@
+// Package clicks records redirects through short links without slowing the redirect down.
+package clicks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// flushTimeout limits the time of saving one batch of clicks.
+const flushTimeout = 5 * time.Second
+
+// maxBatch is the number of clicks that triggers flush before interval passes.
+const maxBatch = 100
+
+// Repository is the storage of clicks.
+type Repository interface {
+	SaveClicks(ctx context.Context, clicks []models.Click) error
+}
+
+// Recorder buffers clicks in memory and saves them to repository in batches.
+type Recorder struct {
+	repo     Repository
+	log      *zap.SugaredLogger
+	queue    chan models.Click
+	interval time.Duration
+}
+
+// NewRecorder creates recorder that keeps up to bufferSize unsaved clicks
+// and flushes them at least once per interval.
+func NewRecorder(repo Repository, log *zap.SugaredLogger, bufferSize int, interval time.Duration) *Recorder {
+	return &Recorder{
+		repo:     repo,
+		log:      log,
+		queue:    make(chan models.Click, bufferSize),
+		interval: interval,
+	}
+}
+
+// Record queues click for saving. It never blocks: when the buffer is full the click is dropped.
+func (r *Recorder) Record(click models.Click) {
+	select {
+	case r.queue <- click:
+	default:
+		r.log.Warnw("click buffer is full, click dropped", "id", click.ShortURL)
+	}
+}
+
+// Run saves queued clicks until ctx is done, then flushes what is left in the buffer.
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	batch := make([]models.Click, 0, maxBatch)
+	for {
+		select {
+		case click := <-r.queue:
+			batch = append(batch, click)
+			if len(batch) >= maxBatch {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		case <-ctx.Done():
+			for {
+				select {
+				case click := <-r.queue:
+					batch = append(batch, click)
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush saves batch and returns it emptied for reuse.
+func (r *Recorder) flush(batch []models.Click) []models.Click {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	if err := r.repo.SaveClicks(ctx, batch); err != nil {
+		r.log.Errorw("clicks saving error", "error", err, "count", len(batch))
+	}
+	return batch[:0]
+}
+
+// NewClick describes redirect to short link id made by request r, see ClientIP for proxies.
+// Client IP is hashed with salt, so raw addresses are never stored.
+func NewClick(r *http.Request, id string, salt string, proxies *net.IPNet) models.Click {
+	return models.Click{
+		ShortURL:  id,
+		At:        time.Now().UTC(),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		IPHash:    HashIP(ClientIP(r, proxies), salt),
+	}
+}
+
+// ClientIP returns address of the client. Proxy headers are taken into account only if the request
+// comes from a proxy in the proxies subnet, otherwise any client could forge them: X-Real-IP is used
+// if it is set, else the last X-Forwarded-For address that isn't a proxy. Nil proxies trusts no one.
+func ClientIP(r *http.Request, proxies *net.IPNet) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !isProxy(remote, proxies) {
+		return remote
+	}
+
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if ip != "" && (!isProxy(ip, proxies) || i == 0) {
+			return ip
+		}
+	}
+	return remote
+}
+
+// isProxy reports whether ip belongs to the proxies subnet.
+func isProxy(ip string, proxies *net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	return proxies != nil && parsed != nil && proxies.Contains(parsed)
+}
+
+// HashIP returns hex encoded salted SHA-256 of ip.
+func HashIP(ip string, salt string) string {
+	sum := sha256.Sum256([]byte(salt + ip))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,109 @@
+package clicks
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+func TestRecorder_Run(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	require.NoError(t, repo.Save(context.Background(), models.ShortURL{ShortURL: "abc", OriginalURL: "https://example.com"}))
+	recorder := NewRecorder(repo, zap.S(), 10, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		recorder.Run(ctx)
+		close(done)
+	}()
+
+	r := httptest.NewRequest("GET", "/abc", nil)
+	r.RemoteAddr = "10.0.0.1:5555"
+	recorder.Record(NewClick(r, "abc", "salt", nil))
+	recorder.Record(NewClick(r, "abc", "salt", nil))
+	r.RemoteAddr = "10.0.0.2:5555"
+	recorder.Record(NewClick(r, "abc", "salt", nil))
+
+	// remaining clicks are flushed on shutdown
+	cancel()
+	<-done
+
+	stats, err := repo.GetClickStats(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.TotalClicks)
+	assert.Equal(t, 2, stats.UniqueVisitors)
+	require.Len(t, stats.Daily, 1)
+	assert.Equal(t, 3, stats.Daily[0].Clicks)
+}
+
+func TestRecorder_RecordDoesNotBlock(t *testing.T) {
+	recorder := NewRecorder(repository.NewInMemoryRepository(), zap.S(), 1, time.Hour)
+
+	r := httptest.NewRequest("GET", "/abc", nil)
+	recorder.Record(NewClick(r, "abc", "salt", nil))
+	recorder.Record(NewClick(r, "abc", "salt", nil))
+
+	assert.Len(t, recorder.queue, 1)
+}
+
+func TestClientIP(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		remote    string
+		realIP    string
+		forwarded string
+		want      string
+	}{
+		{name: "direct client", remote: "192.168.1.1:5555", want: "192.168.1.1"},
+		{name: "spoofed real ip", remote: "192.168.1.1:5555", realIP: "172.16.0.1", want: "192.168.1.1"},
+		{name: "spoofed forwarded for", remote: "192.168.1.1:5555", forwarded: "172.16.0.1", want: "192.168.1.1"},
+		{name: "real ip from proxy", remote: "10.0.0.1:5555", realIP: "172.16.0.1", want: "172.16.0.1"},
+		{
+			name:      "forwarded for from proxies",
+			remote:    "10.0.0.1:5555",
+			forwarded: "172.16.0.1, 192.168.1.1, 10.0.0.2",
+			want:      "192.168.1.1",
+		},
+		{name: "proxy without headers", remote: "10.0.0.1:5555", want: "10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/abc", nil)
+			r.RemoteAddr = test.remote
+			if test.realIP != "" {
+				r.Header.Set("X-Real-IP", test.realIP)
+			}
+			if test.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", test.forwarded)
+			}
+			assert.Equal(t, test.want, ClientIP(r, proxies))
+		})
+	}
+
+	t.Run("no proxies", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/abc", nil)
+		r.RemoteAddr = "10.0.0.1:5555"
+		r.Header.Set("X-Real-IP", "172.16.0.1")
+		assert.Equal(t, "10.0.0.1", ClientIP(r, nil))
+	})
+}
+
+func TestHashIP(t *testing.T) {
+	assert.Equal(t, HashIP("10.0.0.1", "salt"), HashIP("10.0.0.1", "salt"))
+	assert.NotEqual(t, HashIP("10.0.0.1", "salt"), HashIP("10.0.0.1", "pepper"))
+	assert.NotContains(t, HashIP("10.0.0.1", "salt"), "10.0.0.1")
+}
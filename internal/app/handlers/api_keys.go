@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+// APIKeyResponse представляет структуру ответа на выпуск API-ключа.
+// Сам ключ возвращается только один раз, в хранилище сохраняется лишь его хеш.
+type APIKeyResponse struct {
+	ID        string    `json:"id"`
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// issueAPIKey выпускает новый API-ключ пользователя.
+// Ключ передается в заголовке "Authorization: Bearer <key>", и созданные с ним ссылки принадлежат пользователю.
+func (h *handler) issueAPIKey(w http.ResponseWriter, r *http.Request) {
+	apiKey, key, err := h.service.IssueAPIKey(r.Context(), tokenutils.GetUserID(r, h.tokens))
+	if err != nil {
+		h.log.Errorw("API key issuing error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, APIKeyResponse{ID: apiKey.ID, Key: key, CreatedAt: apiKey.CreatedAt})
+}
+
+// revokeAPIKey отзывает API-ключ пользователя по его идентификатору.
+// Для чужих, уже отозванных и несуществующих ключей возвращается http.StatusNotFound.
+func (h *handler) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	err := h.service.RevokeAPIKey(r.Context(), chi.URLParam(r, "id"), tokenutils.GetUserID(r, h.tokens))
+	if errors.Is(err, repository.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.log.Errorw("API key revoking error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
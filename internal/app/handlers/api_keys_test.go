@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/idgen"
+	"github.com/GTedya/shortener/internal/app/middlewares"
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/app/service"
+)
+
+func TestHandler_APIKeys(t *testing.T) {
+	repo := repository.NewInMemoryRepository()
+	conf := config.Config{URL: "http://localhost:8080"}
+	shortener := service.NewShortener(repo, idgen.NewRandom(8), &conf)
+	tokens := testKeyring(t)
+
+	h := &handler{tokens: tokens, service: shortener, log: zap.S(), conf: conf}
+	router := chi.NewRouter()
+	h.Register(router, middlewares.Middleware{Log: zap.S(), Tokens: tokens, APIKeys: shortener})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/user/keys", nil)
+	addUserCookie(t, r, "owner")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var issued APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	assert.NotEmpty(t, issued.ID)
+	assert.NotEmpty(t, issued.Key)
+
+	shorten := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com/"+key))
+		r.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("link belongs to key owner", func(t *testing.T) {
+		w := shorten(issued.Key)
+		require.Equal(t, http.StatusCreated, w.Code)
+		// the key isn't traded for the user token
+		assert.Empty(t, w.Result().Cookies())
+
+		urls, err := repo.GetUsersUrls(r.Context(), "owner", models.URLQuery{})
+		require.NoError(t, err)
+		assert.Len(t, urls, 1)
+	})
+
+	t.Run("json link belongs to key owner", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com/json"}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer "+issued.Key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Result().Cookies())
+
+		url, err := repo.ShortenByURL(r.Context(), "https://example.com/json")
+		require.NoError(t, err)
+		assert.Equal(t, "owner", url.CreatedByID)
+	})
+
+	t.Run("key authenticates user routes", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		r.Header.Set("Authorization", "Bearer "+issued.Key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("key can't manage keys", func(t *testing.T) {
+		for _, r := range []*http.Request{
+			httptest.NewRequest(http.MethodPost, "/api/user/keys", nil),
+			httptest.NewRequest(http.MethodDelete, "/api/user/keys/"+issued.ID, nil),
+		} {
+			r.Header.Set("Authorization", "Bearer "+issued.Key)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, r.Method)
+		}
+	})
+
+	t.Run("someone else can't revoke", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodDelete, "/api/user/keys/"+issued.ID, nil)
+		addUserCookie(t, r, "someone else")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("revoked key is rejected", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodDelete, "/api/user/keys/"+issued.ID, nil)
+		addUserCookie(t, r, "owner")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, shorten(issued.Key).Code)
+	})
+}
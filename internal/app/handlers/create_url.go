@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
-	"github.com/google/uuid"
-
+	"github.com/GTedya/shortener/internal/app/idgen"
 	"github.com/GTedya/shortener/internal/app/models"
 	"github.com/GTedya/shortener/internal/app/repository"
 	"github.com/GTedya/shortener/internal/app/tokenutils"
@@ -16,8 +18,11 @@ import (
 
 // ReqMultipleURL представляет структуру запроса для создания нескольких коротких URL.
 type ReqMultipleURL struct {
-	CorrelationID string `json:"correlation_id"`
-	OriginalURL   string `json:"original_url"`
+	CorrelationID string     `json:"correlation_id"`
+	OriginalURL   string     `json:"original_url"`
+	Alias         string     `json:"alias,omitempty"`      // пользовательский короткий идентификатор
+	TTL           int64      `json:"ttl,omitempty"`        // время жизни ссылки в секундах
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"` // время истечения ссылки
 }
 
 // ResMultipleURL представляет структуру ответа с коротким URL и соответствующим ID запроса.
@@ -34,7 +39,10 @@ var errJSONMarshal = errors.New("json marshalling error")
 
 // URL представляет структуру для хранения URL.
 type URL struct {
-	URL string `json:"url"`
+	URL       string     `json:"url"`
+	Alias     string     `json:"alias,omitempty"`      // пользовательский короткий идентификатор
+	TTL       int64      `json:"ttl,omitempty"`        // время жизни ссылки в секундах
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // время истечения ссылки
 }
 
 // ShortURL представляет структуру для хранения сокращенного URL.
@@ -42,7 +50,54 @@ type ShortURL struct {
 	URL string `json:"result"`
 }
 
+// aliasErrorStatus возвращает HTTP-статус для ошибок пользовательского псевдонима.
+func aliasErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, idgen.ErrInvalidAlias), errors.Is(err, idgen.ErrReservedAlias):
+		return http.StatusBadRequest, true
+	case errors.Is(err, repository.ErrIDTaken):
+		return http.StatusConflict, true
+	default:
+		return 0, false
+	}
+}
+
+// expirationFromQuery вычисляет время истечения ссылки из параметров запроса ttl (в секундах)
+// и expires_at (в формате RFC 3339).
+func expirationFromQuery(query url.Values) (*time.Time, error) {
+	var ttl int64
+	if value := query.Get("ttl"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: ttl parsing error: %w", models.ErrInvalidExpiration, err)
+		}
+		ttl = parsed
+	}
+
+	var expiresAt *time.Time
+	if value := query.Get("expires_at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: expires_at parsing error: %w", models.ErrInvalidExpiration, err)
+		}
+		expiresAt = &parsed
+	}
+
+	return expiration(ttl, expiresAt)
+}
+
+// expiration вычисляет время истечения ссылки из времени жизни в секундах или абсолютного времени.
+func expiration(ttl int64, expiresAt *time.Time) (*time.Time, error) {
+	at, err := models.ExpirationTime(time.Duration(ttl)*time.Second, expiresAt, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("expiration error: %w", err)
+	}
+	return at, nil
+}
+
 // createURL обрабатывает запрос на создание сокращенного URL.
+// Пользовательский псевдоним можно передать в параметре запроса alias,
+// время жизни ссылки - в параметрах ttl или expires_at.
 func (h *handler) createURL(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -54,27 +109,25 @@ func (h *handler) createURL(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	var shortID string
-
 	id := string(body)
 	w.Header().Add(contentType, "text/plain; application/json")
-	shortID = uuid.NewString()
 
-	userID := tokenutils.GetUserID(r)
-	err = h.repo.Save(r.Context(), models.ShortURL{
+	expiresAt, err := expirationFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := tokenutils.GetUserID(r, h.tokens)
+	shortURL, err := h.service.Shorten(r.Context(), models.ShortURL{
 		OriginalURL: id,
-		ShortURL:    shortID,
+		ShortURL:    r.URL.Query().Get("alias"),
 		CreatedByID: userID,
+		ExpiresAt:   expiresAt,
 	})
 
 	if errors.Is(err, repository.ErrDuplicate) {
 		w.WriteHeader(http.StatusConflict)
-		shortURL, err := h.repo.ShortenByURL(r.Context(), id)
-		if err != nil {
-			h.log.Errorw("short url getting error", err)
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
 		if _, err = w.Write([]byte(fmt.Sprintf("%s/%s", h.conf.URL, shortURL.ShortURL))); err != nil {
 			h.log.Error(errResponseWrite)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -83,13 +136,17 @@ func (h *handler) createURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if status, ok := aliasErrorStatus(err); ok {
+		http.Error(w, err.Error(), status)
+		return
+	}
 	if err != nil {
 		h.log.Errorw("data saving error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	if err = tokenutils.AddEncryptedUserIDToCookie(&w, userID); err != nil {
+	if err = h.setUserCookie(w, r, userID); err != nil {
 		h.log.Errorw("adding cookie error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -97,7 +154,7 @@ func (h *handler) createURL(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusCreated)
 
-	if _, err = w.Write([]byte(fmt.Sprintf("%s/%s", h.conf.URL, shortID))); err != nil {
+	if _, err = w.Write([]byte(fmt.Sprintf("%s/%s", h.conf.URL, shortURL.ShortURL))); err != nil {
 		h.log.Error(errResponseWrite)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -105,6 +162,7 @@ func (h *handler) createURL(w http.ResponseWriter, r *http.Request) {
 }
 
 // urlByJSON обрабатывает запрос на создание сокращенного URL, переданный в формате JSON.
+// Помимо url можно передать псевдоним alias и время жизни ссылки ttl или expires_at.
 func (h *handler) urlByJSON(w http.ResponseWriter, r *http.Request) {
 	content := r.Header.Get(contentType)
 	if content != appJSON {
@@ -134,23 +192,23 @@ func (h *handler) urlByJSON(w http.ResponseWriter, r *http.Request) {
 	}
 
 	id := u.URL
-	shortID := uuid.NewString()
 
-	token := r.Header.Get("Authorization")
-	err = h.repo.Save(r.Context(), models.ShortURL{
+	expiresAt, err := expiration(u.TTL, u.ExpiresAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := tokenutils.GetUserID(r, h.tokens)
+	shortURL, err := h.service.Shorten(r.Context(), models.ShortURL{
 		OriginalURL: id,
-		ShortURL:    shortID,
-		CreatedByID: token,
+		ShortURL:    u.Alias,
+		CreatedByID: userID,
+		ExpiresAt:   expiresAt,
 	})
 
 	if errors.Is(err, repository.ErrDuplicate) {
 		w.WriteHeader(http.StatusConflict)
-		shortURL, err := h.repo.ShortenByURL(r.Context(), id)
-		if err != nil {
-			h.log.Errorw("short url getting error", err)
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
 		encodedID := ShortURL{URL: fmt.Sprintf("http://%s/%s", h.conf.Address, shortURL.ShortURL)}
 		marshal, err := json.Marshal(encodedID)
 		if err != nil {
@@ -167,13 +225,23 @@ func (h *handler) urlByJSON(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if status, ok := aliasErrorStatus(err); ok {
+		http.Error(w, err.Error(), status)
+		return
+	}
 	if err != nil {
 		h.log.Errorw("data saving error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	encodedID := ShortURL{URL: fmt.Sprintf("http://%s/%s", h.conf.Address, shortID)}
+	if err = h.setUserCookie(w, r, userID); err != nil {
+		h.log.Errorw("adding cookie error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	encodedID := ShortURL{URL: fmt.Sprintf("http://%s/%s", h.conf.Address, shortURL.ShortURL)}
 	marshal, err := json.Marshal(encodedID)
 	if err != nil {
 		h.log.Error(errJSONMarshal)
@@ -190,6 +258,7 @@ func (h *handler) urlByJSON(w http.ResponseWriter, r *http.Request) {
 }
 
 // batch обрабатывает запрос на пакетное создание сокращенных URL.
+// Для каждого URL можно указать пользовательский псевдоним в поле alias и время жизни в полях ttl или expires_at.
 func (h *handler) batch(w http.ResponseWriter, r *http.Request) {
 	content := r.Header.Get(contentType)
 	if content != appJSON {
@@ -219,33 +288,43 @@ func (h *handler) batch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := tokenutils.GetUserID(r)
+	userID := tokenutils.GetUserID(r, h.tokens)
 
 	for _, url := range reqUrls {
 		if len(url.OriginalURL) == 0 {
 			break
 		}
-		shortID := uuid.NewString()
-		res := ResMultipleURL{
-			CorrelationID: url.CorrelationID,
-			ShortURL:      fmt.Sprintf("http://%s/%s", h.conf.Address, shortID),
+		expiresAt, err := expiration(url.TTL, url.ExpiresAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-
-		resUrls = append(resUrls, res)
 		urls = append(urls, models.ShortURL{
 			OriginalURL: url.OriginalURL,
-			ShortURL:    shortID,
+			ShortURL:    url.Alias,
 			CreatedByID: userID,
+			ExpiresAt:   expiresAt,
 		})
 	}
 
-	err = h.repo.SaveBatch(r.Context(), urls)
+	urls, err = h.service.ShortenBatch(r.Context(), urls, userID)
+	if status, ok := aliasErrorStatus(err); ok {
+		http.Error(w, err.Error(), status)
+		return
+	}
 	if err != nil {
 		h.log.Errorw("data saving error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	for i, url := range urls {
+		resUrls = append(resUrls, ResMultipleURL{
+			CorrelationID: reqUrls[i].CorrelationID,
+			ShortURL:      fmt.Sprintf("http://%s/%s", h.conf.Address, url.ShortURL),
+		})
+	}
+
 	marshal, err := json.Marshal(resUrls)
 	if err != nil {
 		h.log.Error(errJSONMarshal)
@@ -262,3 +341,15 @@ func (h *handler) batch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// setUserCookie добавляет в ответ куки с токеном пользователя. Пользователю, аутентифицированному по API-ключу,
+// куки не выдается, иначе ключ можно было бы обменять на токен, который действует после отзыва ключа.
+func (h *handler) setUserCookie(w http.ResponseWriter, r *http.Request, userID string) error {
+	if tokenutils.IsAPIKeyUser(r.Context()) {
+		return nil
+	}
+	if err := tokenutils.AddEncryptedUserIDToCookie(&w, userID, h.tokens); err != nil {
+		return fmt.Errorf("user cookie error: %w", err)
+	}
+	return nil
+}
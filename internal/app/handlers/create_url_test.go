@@ -2,30 +2,39 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 
 	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/idgen"
 	"github.com/GTedya/shortener/internal/app/logger"
 	mock_repo "github.com/GTedya/shortener/internal/app/mocks"
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/app/service"
 )
 
 func TestHandler_createURL(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockRepo := mock_repo.NewMockRepository(ctrl)
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
 	h := &handler{
-		repo: mockRepo,
-		log:  zap.S(),
+		tokens:  testKeyring(t),
+		service: mockService,
+		log:     zap.S(),
 		conf: config.Config{
 			URL: "http://localhost:8080",
 		},
@@ -43,7 +52,8 @@ func TestHandler_createURL(t *testing.T) {
 	t.Run("successful creation", func(t *testing.T) {
 		originalURL := "http://example.com"
 
-		mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		mockService.EXPECT().Shorten(gomock.Any(), gomock.Any()).
+			Return(models.ShortURL{OriginalURL: originalURL, ShortURL: "abc"}, nil).AnyTimes()
 
 		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(originalURL)))
 		rr := httptest.NewRecorder()
@@ -51,17 +61,115 @@ func TestHandler_createURL(t *testing.T) {
 		h.createURL(rr, req)
 
 		assert.Equal(t, http.StatusCreated, rr.Code)
-		assert.NotEmpty(t, rr.Body.String())
+		assert.Equal(t, "http://localhost:8080/abc", rr.Body.String())
 	})
 }
 
+func TestHandler_createURLDuplicate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conf := config.Config{URL: "http://localhost:8080"}
+	mockRepo := mock_repo.NewMockRepository(ctrl)
+	h := &handler{
+		tokens:  testKeyring(t),
+		service: service.NewShortener(mockRepo, idgen.NewRandom(8), &conf),
+		log:     zap.S(),
+		conf:    conf,
+	}
+
+	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(repository.ErrDuplicate)
+	mockRepo.EXPECT().ShortenByURL(gomock.Any(), "http://example.com").
+		Return(models.ShortURL{OriginalURL: "http://example.com", ShortURL: "existing"}, nil).Times(2)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("http://example.com"))
+	rr := httptest.NewRecorder()
+
+	h.createURL(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "http://localhost:8080/existing", rr.Body.String())
+}
+
+func TestHandler_createURLWithAlias(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conf := config.Config{URL: "http://localhost:8080"}
+	mockRepo := mock_repo.NewMockRepository(ctrl)
+	h := &handler{
+		tokens:  testKeyring(t),
+		service: service.NewShortener(mockRepo, idgen.NewRandom(8), &conf),
+		log:     zap.S(),
+		conf:    conf,
+	}
+
+	tests := []struct {
+		name           string
+		alias          string
+		saveErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "free alias",
+			alias:          "q3-launch",
+			expectedStatus: http.StatusCreated,
+			expectedBody:   "http://localhost:8080/q3-launch",
+		},
+		{
+			name:           "taken alias",
+			alias:          "q3-launch",
+			saveErr:        repository.ErrIDTaken,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "reserved alias",
+			alias:          "api",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid alias",
+			alias:          "q3/launch",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.expectedStatus != http.StatusBadRequest {
+				mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, shortURL models.ShortURL) error {
+						assert.Equal(t, test.alias, shortURL.ShortURL)
+						return test.saveErr
+					})
+			}
+			if test.saveErr != nil {
+				mockRepo.EXPECT().GetByID(gomock.Any(), test.alias).
+					Return(models.ShortURL{ShortURL: test.alias, OriginalURL: "http://other.example"}, nil)
+			}
+
+			target := "/?alias=" + url.QueryEscape(test.alias)
+			req := httptest.NewRequest(http.MethodPost, target, strings.NewReader("http://example.com"))
+			rr := httptest.NewRecorder()
+
+			h.createURL(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
 func BenchmarkCreateURL(b *testing.B) {
 	conf := config.Config{Address: "localhost:8080", URL: "short"}
 	log := logger.CreateLogger()
 	ctrl := gomock.NewController(b)
-	mockRepo := mock_repo.NewMockRepository(ctrl)
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
 
-	h := &handler{log: log, conf: conf, repo: mockRepo}
+	h := &handler{log: log, conf: conf, service: mockService, tokens: testKeyring(b)}
 
 	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`https://example.com`))
 	request.Header.Add("Content-Type", "text/plain; charset=utf-8; application/json")
@@ -70,7 +178,7 @@ func BenchmarkCreateURL(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		request.Body = io.NopCloser(strings.NewReader("example body"))
 
-		mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
+		mockService.EXPECT().Shorten(gomock.Any(), gomock.Any()).Return(models.ShortURL{ShortURL: "abc"}, nil)
 
 		h.createURL(w, request)
 
@@ -84,10 +192,11 @@ func TestJsonHandler(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockRepo := mock_repo.NewMockRepository(ctrl)
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
 	h := &handler{
-		repo: mockRepo,
-		log:  zap.S(),
+		tokens:  testKeyring(t),
+		service: mockService,
+		log:     zap.S(),
 		conf: config.Config{
 			URL: "http://localhost:8080",
 		},
@@ -123,7 +232,7 @@ func TestJsonHandler(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			if test.contentType == "application/json" && test.body != "" {
-				mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+				mockService.EXPECT().Shorten(gomock.Any(), gomock.Any()).Return(models.ShortURL{ShortURL: "abc"}, nil).Times(1)
 			}
 			request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/shorten/", strings.NewReader(test.body))
 			request.Header.Add("Content-Type", test.contentType)
@@ -141,9 +250,9 @@ func BenchmarkUrlByJSON(b *testing.B) {
 	log := zap.S()
 
 	ctrl := gomock.NewController(b)
-	mockRepo := mock_repo.NewMockRepository(ctrl)
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
 
-	h := &handler{log: log, conf: conf, repo: mockRepo}
+	h := &handler{log: log, conf: conf, service: mockService, tokens: testKeyring(b)}
 	reader := strings.NewReader(`{"url": "https://example.com"}`)
 	request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/shorten/", reader)
 	request.Header.Add("Content-Type", "application/json")
@@ -153,7 +262,7 @@ func BenchmarkUrlByJSON(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		request.Body = io.NopCloser(reader)
 
-		mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		mockService.EXPECT().Shorten(gomock.Any(), gomock.Any()).Return(models.ShortURL{ShortURL: "abc"}, nil).AnyTimes()
 
 		h.urlByJSON(w, request)
 		if w.Code != http.StatusCreated {
@@ -169,9 +278,9 @@ func TestBatch(t *testing.T) {
 	}
 	log := logger.CreateLogger()
 	ctrl := gomock.NewController(t)
-	mockRepo := mock_repo.NewMockRepository(ctrl)
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
 
-	h := &handler{log: log, conf: conf, repo: mockRepo}
+	h := &handler{log: log, conf: conf, service: mockService, tokens: testKeyring(t)}
 
 	tests := []struct {
 		name           string
@@ -206,7 +315,7 @@ func TestBatch(t *testing.T) {
 			request.Header.Add("Content-Type", test.contentType)
 
 			if test.contentType == "application/json" && test.body != "" {
-				mockRepo.EXPECT().SaveBatch(gomock.Any(), gomock.Any()).Return(nil)
+				mockService.EXPECT().ShortenBatch(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(returnBatch)
 			}
 
 			w := httptest.NewRecorder()
@@ -240,9 +349,9 @@ func BenchmarkBatch(b *testing.B) {
 	conf := config.Config{Address: "localhost:8080", URL: "short"}
 	log := logger.CreateLogger()
 	ctrl := gomock.NewController(b)
-	mockRepo := mock_repo.NewMockRepository(ctrl)
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
 
-	h := &handler{log: log, conf: conf, repo: mockRepo}
+	h := &handler{log: log, conf: conf, service: mockService, tokens: testKeyring(b)}
 	reader := strings.NewReader(`[{"original_url": "https://example.com", "correlation_id": "123456"},
 {"original_url": "https://example2.com", "correlation_id": "1234567"}]`)
 
@@ -252,8 +361,53 @@ func BenchmarkBatch(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		request.Body = io.NopCloser(reader)
-		mockRepo.EXPECT().SaveBatch(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		mockService.EXPECT().ShortenBatch(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(returnBatch).AnyTimes()
 
 		h.batch(w, request)
 	}
 }
+
+// returnBatch заполняет короткие идентификаторы пакета, как это делает сервис.
+func returnBatch(_ context.Context, batch []models.ShortURL, userID string) ([]models.ShortURL, error) {
+	for i := range batch {
+		batch[i].ShortURL = fmt.Sprintf("id%d", i)
+		batch[i].CreatedByID = userID
+	}
+	return batch, nil
+}
+
+func TestExpirationFromQuery(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		query     url.Values
+		wantErr   bool
+		expiresAt *time.Time
+	}{
+		{name: "no expiration", query: url.Values{}},
+		{name: "ttl", query: url.Values{"ttl": {"60"}}},
+		{name: "expires_at", query: url.Values{"expires_at": {future.Format(time.RFC3339)}}, expiresAt: &future},
+		{name: "negative ttl", query: url.Values{"ttl": {"-1"}}, wantErr: true},
+		{name: "malformed ttl", query: url.Values{"ttl": {"soon"}}, wantErr: true},
+		{name: "past expires_at", query: url.Values{"expires_at": {"2000-01-01T00:00:00Z"}}, wantErr: true},
+		{name: "both", query: url.Values{"ttl": {"60"}, "expires_at": {future.Format(time.RFC3339)}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			at, err := expirationFromQuery(test.query)
+			if test.wantErr {
+				assert.ErrorIs(t, err, models.ErrInvalidExpiration)
+				return
+			}
+			assert.NoError(t, err)
+			if test.expiresAt != nil {
+				assert.True(t, test.expiresAt.Equal(*at))
+			}
+			if test.query.Get("ttl") != "" {
+				assert.NotNil(t, at)
+			}
+		})
+	}
+}
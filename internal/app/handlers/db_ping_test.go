@@ -17,12 +17,13 @@ func TestGetPing(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockRepo := mock_repo.NewMockRepository(ctrl)
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
 	log := zap.NewExample().Sugar()
 	h := &handler{
-		repo: mockRepo,
-		log:  log,
-		conf: config.Config{URL: "http://localhost:8080"},
+		tokens:  testKeyring(t),
+		service: mockService,
+		log:     log,
+		conf:    config.Config{URL: "http://localhost:8080"},
 	}
 
 	tests := []struct {
@@ -39,7 +40,7 @@ func TestGetPing(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			mockRepo.EXPECT().Check(gomock.Any()).Return(test.mockError).AnyTimes()
+			mockService.EXPECT().HealthCheck(gomock.Any()).Return(test.mockError).AnyTimes()
 
 			req := httptest.NewRequest(http.MethodGet, "http://localhost:8080/ping", nil)
 			w := httptest.NewRecorder()
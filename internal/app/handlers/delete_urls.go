@@ -5,13 +5,12 @@ import (
 	"io"
 	"net/http"
 
-	"github.com/GTedya/shortener/internal/app/models"
 	"github.com/GTedya/shortener/internal/app/tokenutils"
 )
 
 // deleteUrls обрабатывает запрос на удаление сокращенных URL, принадлежащих пользователю.
 func (h *handler) deleteUrls(w http.ResponseWriter, r *http.Request) {
-	token := tokenutils.GetUserID(r)
+	token := tokenutils.GetUserID(r, h.tokens)
 
 	var shortURLs []string
 	body, err := io.ReadAll(r.Body)
@@ -31,21 +30,5 @@ func (h *handler) deleteUrls(w http.ResponseWriter, r *http.Request) {
 	}
 	w.WriteHeader(http.StatusAccepted)
 
-	ctx := r.Context()
-
-	urls := make([]models.ShortURL, 0)
-
-	for _, shortURL := range shortURLs {
-		urls = append(urls, models.ShortURL{
-			ShortURL:    shortURL,
-			CreatedByID: token,
-		})
-	}
-
-	err = h.repo.DeleteUrls(ctx, urls)
-	if err != nil {
-		h.log.Errorw("User deleting error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	h.service.DeleteUrls(r.Context(), shortURLs, token)
 }
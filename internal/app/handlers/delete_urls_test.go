@@ -21,12 +21,13 @@ func TestDeleteUrls(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockRepo := mock_repo.NewMockRepository(ctrl)
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
 	log := zap.NewExample().Sugar()
 	h := &handler{
-		repo: mockRepo,
-		log:  log,
-		conf: config.Config{URL: "http://localhost:8080"},
+		tokens:  testKeyring(t),
+		service: mockService,
+		log:     log,
+		conf:    config.Config{URL: "http://localhost:8080"},
 	}
 
 	tests := []struct {
@@ -75,7 +76,7 @@ func TestDeleteUrls(t *testing.T) {
 			w := httptest.NewRecorder()
 
 			if test.body != nil && test.body != "invalid" {
-				mockRepo.EXPECT().DeleteUrls(gomock.Any(), gomock.Any()).Return(test.mockError).AnyTimes()
+				mockService.EXPECT().DeleteUrls(gomock.Any(), test.body, gomock.Any()).AnyTimes()
 			}
 
 			h.deleteUrls(w, req)
@@ -3,23 +3,18 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-
-	"github.com/GTedya/shortener/internal/app/models"
 )
 
 func (h *handler) getStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	userCount, urlsCount, err := h.repo.GetUsersAndUrlsCount(r.Context())
+	stats, err := h.service.GetStats(r.Context())
 	if err != nil {
 		h.log.Errorw("stats getting error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	marshal, err := json.Marshal(models.Stats{
-		UrlsCount:  urlsCount,
-		UsersCount: userCount,
-	})
+	marshal, err := json.Marshal(stats)
 	if err != nil {
 		h.log.Error(errJSONMarshal)
 		w.WriteHeader(http.StatusInternalServerError)
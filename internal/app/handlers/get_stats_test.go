@@ -19,12 +19,13 @@ func TestGetStats(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockRepo := mock_repo.NewMockRepository(ctrl)
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
 	log := zap.NewExample().Sugar()
 	h := &handler{
-		repo: mockRepo,
-		log:  log,
-		conf: config.Config{URL: "http://localhost:8080"},
+		tokens:  testKeyring(t),
+		service: mockService,
+		log:     log,
+		conf:    config.Config{URL: "http://localhost:8080"},
 	}
 
 	tests := []struct {
@@ -47,8 +48,8 @@ func TestGetStats(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			mockRepo.EXPECT().GetUsersAndUrlsCount(gomock.Any()).
-				Return(test.mockUserCount, test.mockUrlsCount, test.mockError).AnyTimes()
+			mockService.EXPECT().GetStats(gomock.Any()).
+				Return(models.Stats{UsersCount: test.mockUserCount, UrlsCount: test.mockUrlsCount}, test.mockError).AnyTimes()
 
 			req := httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/stats", nil)
 			w := httptest.NewRecorder()
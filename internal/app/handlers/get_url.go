@@ -2,19 +2,28 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/GTedya/shortener/internal/app/clicks"
+	"github.com/GTedya/shortener/internal/app/models"
 	"github.com/GTedya/shortener/internal/app/tokenutils"
 )
 
 // getURLByID получает оригинальный URL по его сокращенной версии.
+// Для удаленных и истекших ссылок возвращает http.StatusGone.
+// Каждое перенаправление учитывается в статистике переходов.
 func (h *handler) getURLByID(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	shortenURL, err := h.repo.GetByID(r.Context(), id)
-	if shortenURL.IsDeleted && err != nil {
+	shortenURL, err := h.service.Expand(r.Context(), id)
+	if shortenURL.IsDeleted {
 		w.WriteHeader(http.StatusGone)
 		return
 	}
@@ -23,23 +32,92 @@ func (h *handler) getURLByID(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if shortenURL.IsExpired(time.Now()) {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
 
 	w.Header().Add(contentType, "text/plain; application/json")
 
+	h.clicks.Record(clicks.NewClick(r, id, h.conf.SecretKey, h.proxies))
 	http.Redirect(w, r, shortenURL.OriginalURL, http.StatusTemporaryRedirect)
 }
 
-// userUrls получает список сокращенных URL, принадлежащих текущему пользователю.
+// nextCursorHeader - заголовок ответа с курсором следующей страницы списка URL пользователя.
+const nextCursorHeader = "X-Next-Cursor"
+
+// errInvalidQuery возвращается для неверных параметров запроса списка URL пользователя.
+var errInvalidQuery = errors.New("invalid query")
+
+// urlQueryFromRequest разбирает параметры запроса списка URL пользователя:
+// limit - размер страницы (от 1 до models.MaxPageSize), cursor - курсор из заголовка X-Next-Cursor
+// предыдущего ответа, sort - порядок по времени создания (created_at или -created_at),
+// deleted - только удаленные или только неудаленные ссылки, domain - домен назначения,
+// q - подстрока оригинального URL.
+func urlQueryFromRequest(values url.Values) (models.URLQuery, error) {
+	var query models.URLQuery
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > models.MaxPageSize {
+			return query, fmt.Errorf("%w: limit must be from 1 to %d", errInvalidQuery, models.MaxPageSize)
+		}
+		query.Limit = limit
+	}
+
+	if value := values.Get("cursor"); value != "" {
+		cursor, err := models.DecodeCursor(value)
+		if err != nil {
+			return query, fmt.Errorf("%w: %w", errInvalidQuery, err)
+		}
+		query.After = &cursor
+	}
+
+	switch values.Get("sort") {
+	case "", "created_at":
+	case "-created_at":
+		query.Desc = true
+	default:
+		return query, fmt.Errorf("%w: unknown sort", errInvalidQuery)
+	}
+
+	if value := values.Get("deleted"); value != "" {
+		deleted, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, fmt.Errorf("%w: deleted parsing error: %w", errInvalidQuery, err)
+		}
+		query.Deleted = &deleted
+	}
+
+	query.Domain = values.Get("domain")
+	query.Contains = values.Get("q")
+	return query, nil
+}
+
+// userUrls получает страницу сокращенных URL, принадлежащих текущему пользователю.
+// Параметры страницы и фильтры описаны в urlQueryFromRequest, курсор следующей страницы
+// передается в заголовке X-Next-Cursor.
 func (h *handler) userURLS(w http.ResponseWriter, r *http.Request) {
-	userID := tokenutils.GetUserID(r)
+	userID := tokenutils.GetUserID(r, h.tokens)
 	w.Header().Add(contentType, appJSON)
 
-	urls, err := h.repo.GetUsersUrls(r.Context(), userID)
+	query, err := urlQueryFromRequest(r.URL.Query())
+	if err != nil {
+		h.log.Debug(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.service.GetUrlsCreatedBy(r.Context(), userID, query)
 	if err != nil {
 		h.log.Errorw("URL getting error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	urls := page.URLs
+	if page.NextCursor != "" {
+		w.Header().Set(nextCursorHeader, page.NextCursor)
+	}
 	if len(urls) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
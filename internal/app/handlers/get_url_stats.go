@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+// urlStats возвращает статистику переходов по сокращенному URL.
+// Статистика доступна только создателю ссылки, для чужих и несуществующих ссылок возвращается http.StatusNotFound.
+func (h *handler) urlStats(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := tokenutils.GetUserID(r, h.tokens)
+
+	stats, err := h.service.GetClickStats(r.Context(), id, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.log.Errorw("click stats getting error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/config"
+	mock_repo "github.com/GTedya/shortener/internal/app/mocks"
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/app/service"
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+func TestURLStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conf := config.Config{URL: "http://localhost:8080"}
+	mockRepo := mock_repo.NewMockRepository(ctrl)
+	h := &handler{
+		tokens:  testKeyring(t),
+		service: service.NewShortener(mockRepo, nil, &conf),
+		log:     zap.S(),
+		conf:    conf,
+	}
+
+	userID := "owner"
+	stats := models.LinkStats{
+		TotalClicks:    3,
+		UniqueVisitors: 2,
+		Daily:          []models.DailyClicks{{Date: "2024-05-01", Clicks: 3}},
+	}
+
+	tests := []struct {
+		name           string
+		url            models.ShortURL
+		getErr         error
+		expectedStatus int
+	}{
+		{
+			name:           "owner",
+			url:            models.ShortURL{ShortURL: "abc", CreatedByID: userID},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "not owner",
+			url:            models.ShortURL{ShortURL: "abc", CreatedByID: "someone else"},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "not found",
+			getErr:         repository.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockRepo.EXPECT().GetByID(gomock.Any(), "abc").Return(test.url, test.getErr)
+			if test.expectedStatus == http.StatusOK {
+				mockRepo.EXPECT().GetClickStats(gomock.Any(), "abc").Return(stats, nil)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/user/urls/abc/stats", nil)
+			addUserCookie(t, r, userID)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "abc")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			h.urlStats(w, r)
+
+			assert.Equal(t, test.expectedStatus, w.Code)
+			if test.expectedStatus == http.StatusOK {
+				var got models.LinkStats
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+				assert.Equal(t, stats, got)
+			}
+		})
+	}
+}
+
+// testKeyring создает набор ключей токенов для тестовых обработчиков.
+func testKeyring(tb testing.TB) *tokenutils.Keyring {
+	tb.Helper()
+
+	tokens, err := tokenutils.NewKeyring(config.Config{SecretKey: "secret_key"})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return tokens
+}
+
+// addUserCookie добавляет в запрос куки с зашифрованным идентификатором пользователя.
+func addUserCookie(t *testing.T, r *http.Request, userID string) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	var w http.ResponseWriter = recorder
+	assert.NoError(t, tokenutils.AddEncryptedUserIDToCookie(&w, userID, testKeyring(t)))
+
+	for _, cookie := range recorder.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+}
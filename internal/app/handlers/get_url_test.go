@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/GTedya/shortener/config"
 	mock_repo "github.com/GTedya/shortener/internal/app/mocks"
@@ -17,15 +18,30 @@ import (
 	"go.uber.org/zap"
 )
 
+// clickRecorderStub запоминает переданные переходы.
+type clickRecorderStub struct {
+	clicks []models.Click
+}
+
+func (s *clickRecorderStub) Record(click models.Click) {
+	s.clicks = append(s.clicks, click)
+}
+
 func TestGetURLByID(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+	notExpired := time.Now().Add(time.Hour)
+
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	mockRepo := mock_repo.NewMockRepository(ctrl)
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
+	recorder := &clickRecorderStub{}
 	h := &handler{
-		repo: mockRepo,
-		log:  zap.S(),
-		conf: config.Config{URL: "http://example.com"},
+		tokens:  testKeyring(t),
+		service: mockService,
+		log:     zap.S(),
+		clicks:  recorder,
+		conf:    config.Config{URL: "http://example.com"},
 	}
 
 	tests := []struct {
@@ -60,11 +76,29 @@ func TestGetURLByID(t *testing.T) {
 			},
 			mockReturnError: errors.New("URL is deleted"),
 		},
+		{
+			name:           "URL Expired",
+			id:             "expiredID",
+			expectedStatus: http.StatusGone,
+			mockReturnURL: models.ShortURL{
+				OriginalURL: "https://example.com",
+				ExpiresAt:   &expired,
+			},
+		},
+		{
+			name:           "URL Not Expired Yet",
+			id:             "aliveID",
+			expectedStatus: http.StatusTemporaryRedirect,
+			mockReturnURL: models.ShortURL{
+				OriginalURL: "https://example.com",
+				ExpiresAt:   &notExpired,
+			},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			mockRepo.EXPECT().GetByID(gomock.Any(), test.id).Return(test.mockReturnURL, test.mockReturnError)
+			mockService.EXPECT().Expand(gomock.Any(), test.id).Return(test.mockReturnURL, test.mockReturnError)
 
 			r := httptest.NewRequest(http.MethodGet, "http://example.com/"+test.id, nil)
 			w := httptest.NewRecorder()
@@ -79,7 +113,83 @@ func TestGetURLByID(t *testing.T) {
 
 			if test.expectedStatus == http.StatusTemporaryRedirect {
 				assert.Equal(t, test.mockReturnURL.OriginalURL, w.Header().Get("Location"))
+				assert.Equal(t, test.id, recorder.clicks[len(recorder.clicks)-1].ShortURL)
+			}
+		})
+	}
+}
+
+func TestUserURLS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
+	h := &handler{
+		tokens:  testKeyring(t),
+		service: mockService,
+		log:     zap.S(),
+		conf:    config.Config{URL: "http://example.com"},
+	}
+
+	cursor := models.URLCursor{CreatedAt: time.Unix(1700000000, 0).UTC(), ID: "abc"}
+	deleted := false
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedQuery  models.URLQuery
+		page           models.URLPage
+		expectedStatus int
+	}{
+		{
+			name:           "Default query",
+			expectedQuery:  models.URLQuery{},
+			page:           models.URLPage{URLs: []models.ShortURL{{ShortURL: "abc", OriginalURL: "https://example.com"}}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:  "All parameters",
+			query: "?limit=10&cursor=" + models.EncodeCursor(cursor) + "&sort=-created_at&deleted=false&domain=example.com&q=path",
+			expectedQuery: models.URLQuery{
+				Limit:    10,
+				After:    &cursor,
+				Desc:     true,
+				Deleted:  &deleted,
+				Domain:   "example.com",
+				Contains: "path",
+			},
+			page: models.URLPage{
+				URLs:       []models.ShortURL{{ShortURL: "def", OriginalURL: "https://example.com/path"}},
+				NextCursor: "next",
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Empty page",
+			expectedQuery:  models.URLQuery{},
+			expectedStatus: http.StatusNoContent,
+		},
+		{name: "Zero limit", query: "?limit=0", expectedStatus: http.StatusBadRequest},
+		{name: "Too large limit", query: "?limit=1001", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid cursor", query: "?cursor=invalid", expectedStatus: http.StatusBadRequest},
+		{name: "Unknown sort", query: "?sort=url", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid deleted", query: "?deleted=maybe", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.expectedStatus != http.StatusBadRequest {
+				mockService.EXPECT().GetUrlsCreatedBy(gomock.Any(), "owner", test.expectedQuery).Return(test.page, nil)
 			}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/user/urls"+test.query, nil)
+			addUserCookie(t, r, "owner")
+			w := httptest.NewRecorder()
+
+			h.userURLS(w, r)
+
+			assert.Equal(t, test.expectedStatus, w.Code)
+			assert.Equal(t, test.page.NextCursor, w.Header().Get(nextCursorHeader))
 		})
 	}
 }
@@ -88,14 +198,16 @@ func BenchmarkGetURLByID(b *testing.B) {
 	ctrl := gomock.NewController(b)
 	defer ctrl.Finish()
 
-	mockRepo := mock_repo.NewMockRepository(ctrl)
+	mockService := mock_repo.NewMockShortenerInterface(ctrl)
 	log := zap.NewExample().Sugar()
 	conf := config.Config{URL: "http://localhost:8080"}
 
 	h := &handler{
-		repo: mockRepo,
-		log:  log,
-		conf: conf,
+		tokens:  testKeyring(b),
+		service: mockService,
+		log:     log,
+		clicks:  &clickRecorderStub{},
+		conf:    conf,
 	}
 
 	testID := "testID"
@@ -103,7 +215,7 @@ func BenchmarkGetURLByID(b *testing.B) {
 		OriginalURL: "http://localhost:8080/testID",
 	}
 
-	mockRepo.EXPECT().GetByID(gomock.Any(), testID).Return(testURL, nil).AnyTimes()
+	mockService.EXPECT().Expand(gomock.Any(), testID).Return(testURL, nil).AnyTimes()
 
 	r := chi.NewRouter()
 	r.Get("/{id:[a-zA-Z0-9]+}", func(writer http.ResponseWriter, request *http.Request) {
@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"context"
+	"fmt"
+	"net"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
@@ -9,14 +10,18 @@ import (
 	"github.com/GTedya/shortener/config"
 	"github.com/GTedya/shortener/internal/app/middlewares"
 	"github.com/GTedya/shortener/internal/app/models"
-	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/app/service"
+	"github.com/GTedya/shortener/internal/app/tokenutils"
 )
 
 // handler представляет обработчик HTTP-запросов.
 type handler struct {
-	log  *zap.SugaredLogger
-	repo Repository
-	conf config.Config
+	log     *zap.SugaredLogger
+	service service.ShortenerInterface
+	clicks  ClickRecorder
+	tokens  *tokenutils.Keyring
+	conf    config.Config
+	proxies *net.IPNet // доверенная подсеть прокси, заголовкам адреса клиента верят только от них
 }
 
 // contentType представляет тип контента HTTP.
@@ -25,42 +30,48 @@ const contentType = "Content-Type"
 // appJSON представляет значение Content-Type для JSON.
 const appJSON = "application/json"
 
-// Repository saves and retrieves data from storage.
-type Repository interface {
-	Save(ctx context.Context, shortURL models.ShortURL) error
-	GetByID(ctx context.Context, id string) (models.ShortURL, error)
-	ShortenByURL(ctx context.Context, url string) (models.ShortURL, error)
-	GetUsersUrls(ctx context.Context, userID string) ([]models.ShortURL, error)
-	Close(_ context.Context) error
-	Check(ctx context.Context) error
-	SaveBatch(ctx context.Context, batch []models.ShortURL) error
-	DeleteUrls(ctx context.Context, urls []models.ShortURL) error
-	GetUsersAndUrlsCount(ctx context.Context) (int, int, error)
+// ClickRecorder сохраняет переходы по коротким ссылкам, не замедляя перенаправление.
+type ClickRecorder interface {
+	Record(click models.Click)
 }
 
 // NewHandler создает новый экземпляр обработчика HTTP-запросов.
-func NewHandler(logger *zap.SugaredLogger, conf config.Config) (Handler, error) {
-	repo := repository.GetRepo(conf)
-
-	return &handler{log: logger, conf: conf, repo: repo}, nil
+// Вся бизнес-логика выполняется сервисом shortener, общим с gRPC-сервером,
+// tokens - ключи токенов пользователей, recorder используется для учета переходов по ссылкам.
+// Адрес клиента берется из заголовков прокси, только если запрос пришел из config.Config.TrustedSubnet.
+func NewHandler(
+	logger *zap.SugaredLogger,
+	conf config.Config,
+	shortener service.ShortenerInterface,
+	tokens *tokenutils.Keyring,
+	recorder ClickRecorder,
+) (Handler, error) {
+	var proxies *net.IPNet
+	if conf.TrustedSubnet != "" {
+		var err error
+		if _, proxies, err = net.ParseCIDR(conf.TrustedSubnet); err != nil {
+			return nil, fmt.Errorf("trusted subnet parsing error: %w", err)
+		}
+	}
+	return &handler{log: logger, conf: conf, service: shortener, tokens: tokens, clicks: recorder, proxies: proxies}, nil
 }
 
 // Register регистрирует обработчики маршрутов HTTP в маршрутизаторе chi.
 func (h *handler) Register(router *chi.Mux, middleware middlewares.Middleware) {
 	// Создает сокращенный URL.
-	router.Post("/", h.createURL)
+	router.With(middleware.APIKeyAuth).Post("/", h.createURL)
 
 	// Получает оригинальный URL по его сокращенной версии.
 	router.Get("/{id}", h.getURLByID)
 
 	// Создает сокращенный URL из JSON-данных.
-	router.Post("/api/shorten", h.urlByJSON)
+	router.With(middleware.APIKeyAuth).Post("/api/shorten", h.urlByJSON)
 
 	// Проверяет доступность сервера.
 	router.Get("/ping", h.getPing)
 
 	// Пакетно создает сокращенные URL.
-	router.Post("/api/shorten/batch", h.batch)
+	router.With(middleware.APIKeyAuth).Post("/api/shorten/batch", h.batch)
 
 	// Получает все сокращенные URL пользователя.
 	router.With(middleware.AuthCheck).Get("/api/user/urls", h.userURLS)
@@ -68,6 +79,29 @@ func (h *handler) Register(router *chi.Mux, middleware middlewares.Middleware) {
 	// Удаляет сокращенные URL пользователя.
 	router.With(middleware.AuthCheck).Delete("/api/user/urls", h.deleteUrls)
 
+	// Восстанавливает удаленные сокращенные URL пользователя.
+	router.With(middleware.AuthCheck).Post("/api/user/urls/restore", h.restoreUrls)
+
+	// Возвращает статистику переходов по сокращенному URL пользователя.
+	router.With(middleware.AuthCheck).Get("/api/user/urls/{id}/stats", h.urlStats)
+
+	// Изменяет оригинальный URL сокращенной ссылки пользователя.
+	router.With(middleware.AuthCheck).Patch("/api/user/urls/{id}", h.updateURL)
+
+	// Возвращает историю изменений оригинального URL сокращенной ссылки пользователя.
+	router.With(middleware.AuthCheck).Get("/api/user/urls/{id}/revisions", h.urlRevisions)
+
+	// Выпускает API-ключ пользователя для серверных клиентов. Ключами управляют только по токену из куки.
+	router.With(middleware.TokenAuthCheck).Post("/api/user/keys", h.issueAPIKey)
+
+	// Отзывает API-ключ пользователя.
+	router.With(middleware.TokenAuthCheck).Delete("/api/user/keys/{id}", h.revokeAPIKey)
+
 	// Return statistic
 	router.With(middleware.IPCheck).Get("/api/internal/stats", h.getStats)
 }
+
+// RegisterRedirects регистрирует в маршрутизаторе chi только переходы по коротким ссылкам.
+func (h *handler) RegisterRedirects(router *chi.Mux) {
+	router.Get("/{id}", h.getURLByID)
+}
@@ -9,4 +9,6 @@ import (
 type Handler interface {
 	// Register регистрирует обработчики маршрутов HTTP в маршрутизаторе chi.
 	Register(router *chi.Mux, middleware middlewares.Middleware)
+	// RegisterRedirects регистрирует в маршрутизаторе chi только переходы по коротким ссылкам.
+	RegisterRedirects(router *chi.Mux)
 }
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+// restoreUrls обрабатывает запрос на восстановление удаленных сокращенных URL, принадлежащих пользователю.
+// Восстановить можно только ссылки, удаленные не раньше срока восстановления config.Config.DeleteGrace.
+// В ответе возвращаются идентификаторы восстановленных ссылок.
+func (h *handler) restoreUrls(w http.ResponseWriter, r *http.Request) {
+	token := tokenutils.GetUserID(r, h.tokens)
+
+	var shortURLs []string
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err = json.Unmarshal(body, &shortURLs); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	restored, err := h.service.RestoreUrls(r.Context(), shortURLs, token)
+	if err != nil {
+		h.log.Errorw("URL restoring error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, restored)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/idgen"
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/app/service"
+)
+
+func TestHandler_restoreUrls(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{OriginalURL: "https://first.example.com", ShortURL: "first", CreatedByID: "owner"},
+		{OriginalURL: "https://second.example.com", ShortURL: "second", CreatedByID: "owner"},
+		{OriginalURL: "https://alive.example.com", ShortURL: "alive", CreatedByID: "owner"},
+	}))
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{
+		{ShortURL: "first", CreatedByID: "owner"},
+		{ShortURL: "second", CreatedByID: "owner"},
+	}))
+
+	conf := config.Config{DeleteGrace: config.Duration{Duration: time.Hour}}
+	h := &handler{
+		tokens:  testKeyring(t),
+		service: service.NewShortener(repo, idgen.NewRandom(8), &conf),
+		log:     zap.S(),
+		conf:    conf,
+	}
+
+	tests := []struct {
+		name             string
+		userID           string
+		body             string
+		expectedStatus   int
+		expectedRestored []string
+	}{
+		{name: "empty body", userID: "owner", expectedStatus: http.StatusBadRequest},
+		{name: "not owner", userID: "someone else", body: `["first"]`, expectedStatus: http.StatusOK, expectedRestored: []string{}},
+		{
+			name:             "owner",
+			userID:           "owner",
+			body:             `["first","alive","missing"]`,
+			expectedStatus:   http.StatusOK,
+			expectedRestored: []string{"first"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/user/urls/restore", strings.NewReader(test.body))
+			addUserCookie(t, r, test.userID)
+			w := httptest.NewRecorder()
+
+			h.restoreUrls(w, r)
+
+			require.Equal(t, test.expectedStatus, w.Code)
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+			var restored []string
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &restored))
+			assert.Equal(t, test.expectedRestored, restored)
+		})
+	}
+
+	first, err := repo.GetByID(ctx, "first")
+	require.NoError(t, err)
+	assert.False(t, first.IsDeleted)
+	assert.Nil(t, first.DeletedAt)
+
+	// deleted before the grace period
+	restored, err := repo.RestoreUrls(ctx, []models.ShortURL{{ShortURL: "second", CreatedByID: "owner"}},
+		time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+}
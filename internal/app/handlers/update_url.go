@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+// UpdateURLRequest представляет структуру запроса на изменение оригинального URL.
+type UpdateURLRequest struct {
+	URL string `json:"url"`
+}
+
+// updateURL обрабатывает запрос на изменение оригинального URL сокращенной ссылки.
+// Изменить ссылку может только ее создатель, для чужих, удаленных и несуществующих ссылок
+// возвращается http.StatusNotFound. Предыдущий URL сохраняется в истории изменений.
+func (h *handler) updateURL(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(contentType) != appJSON {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateURLRequest
+	if err = json.Unmarshal(body, &req); err != nil || req.URL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	shortURL := models.ShortURL{
+		OriginalURL: req.URL,
+		ShortURL:    chi.URLParam(r, "id"),
+		CreatedByID: tokenutils.GetUserID(r, h.tokens),
+	}
+
+	err = h.service.UpdateURL(r.Context(), shortURL)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrDuplicate):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		h.log.Errorw("URL updating error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, shortURL)
+}
+
+// urlRevisions возвращает предыдущие оригинальные URL сокращенной ссылки, начиная с самого старого.
+// История доступна только создателю ссылки, для чужих и несуществующих ссылок возвращается http.StatusNotFound.
+func (h *handler) urlRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions, err := h.service.GetRevisions(r.Context(), chi.URLParam(r, "id"), tokenutils.GetUserID(r, h.tokens))
+	if errors.Is(err, repository.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.log.Errorw("revisions getting error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, revisions)
+}
+
+// writeJSON записывает value в ответ в формате JSON с заданным статусом.
+func (h *handler) writeJSON(w http.ResponseWriter, status int, value any) {
+	marshal, err := json.Marshal(value)
+	if err != nil {
+		h.log.Error(errJSONMarshal)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentType, appJSON)
+	w.WriteHeader(status)
+
+	if _, err = w.Write(marshal); err != nil {
+		h.log.Error(errResponseWrite)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/idgen"
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/app/service"
+)
+
+func TestHandler_updateURL(t *testing.T) {
+	conf := config.Config{URL: "http://localhost:8080"}
+	repo := repository.NewInMemoryRepository()
+	h := &handler{
+		tokens:  testKeyring(t),
+		service: service.NewShortener(repo, idgen.NewRandom(8), &conf),
+		log:     zap.S(),
+		conf:    conf,
+	}
+
+	ctx := context.Background()
+	require.NoError(t, repo.Save(ctx, models.ShortURL{OriginalURL: "https://old.example.com", ShortURL: "abc", CreatedByID: "owner"}))
+	require.NoError(t, repo.Save(ctx, models.ShortURL{OriginalURL: "https://taken.example.com", ShortURL: "def", CreatedByID: "owner"}))
+
+	tests := []struct {
+		name           string
+		userID         string
+		id             string
+		body           string
+		expectedStatus int
+	}{
+		{name: "empty url", userID: "owner", id: "abc", body: `{"url":""}`, expectedStatus: http.StatusBadRequest},
+		{name: "not owner", userID: "someone else", id: "abc", body: `{"url":"https://new.example.com"}`, expectedStatus: http.StatusNotFound},
+		{name: "not found", userID: "owner", id: "xyz", body: `{"url":"https://new.example.com"}`, expectedStatus: http.StatusNotFound},
+		{name: "duplicate", userID: "owner", id: "abc", body: `{"url":"https://taken.example.com"}`, expectedStatus: http.StatusConflict},
+		{name: "success", userID: "owner", id: "abc", body: `{"url":"https://new.example.com"}`, expectedStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/api/user/urls/"+test.id, strings.NewReader(test.body))
+			r.Header.Set(contentType, appJSON)
+			addUserCookie(t, r, test.userID)
+			w := httptest.NewRecorder()
+
+			h.updateURL(w, withURLParam(r, "id", test.id))
+
+			assert.Equal(t, test.expectedStatus, w.Code)
+		})
+	}
+
+	updated, err := repo.GetByID(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://new.example.com", updated.OriginalURL)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/user/urls/abc/revisions", nil)
+	addUserCookie(t, r, "owner")
+	w := httptest.NewRecorder()
+
+	h.urlRevisions(w, withURLParam(r, "id", "abc"))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var revisions []models.Revision
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &revisions))
+	require.Len(t, revisions, 1)
+	assert.Equal(t, "https://old.example.com", revisions[0].OriginalURL)
+}
+
+// withURLParam добавляет в контекст запроса параметр маршрута chi.
+func withURLParam(r *http.Request, key, value string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add(key, value)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
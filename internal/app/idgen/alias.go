@@ -0,0 +1,47 @@
+package idgen
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Alias length limits.
+const (
+	minAliasLength = 3
+	maxAliasLength = 64
+)
+
+// ErrInvalidAlias is returned for aliases with unsupported length or symbols.
+var ErrInvalidAlias = errors.New("invalid alias")
+
+// ErrReservedAlias is returned for aliases that would shadow service routes.
+var ErrReservedAlias = errors.New("alias is reserved")
+
+// reservedAliases are first path segments of service routes.
+var reservedAliases = map[string]bool{
+	"api":   true,
+	"ping":  true,
+	"debug": true,
+}
+
+// ValidateAlias checks that custom alias can be used as short id.
+// Alias may contain latin letters, digits, '-' and '_'.
+func ValidateAlias(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return fmt.Errorf("%w: length must be from %d to %d", ErrInvalidAlias, minAliasLength, maxAliasLength)
+	}
+
+	for _, c := range alias {
+		isLetter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !isDigit && c != '-' && c != '_' {
+			return fmt.Errorf("%w: unexpected symbol %q", ErrInvalidAlias, c)
+		}
+	}
+
+	if reservedAliases[strings.ToLower(alias)] {
+		return fmt.Errorf("%w: %s", ErrReservedAlias, alias)
+	}
+	return nil
+}
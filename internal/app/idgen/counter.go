@@ -0,0 +1,73 @@
+package idgen
+
+import (
+	"math"
+	"strings"
+	"sync/atomic"
+)
+
+// base62Alphabet is alphabet of the counter generator.
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// Counter generates ids by base62 encoding of monotonically increasing sequence.
+type Counter struct {
+	seq atomic.Uint64
+}
+
+// NewCounter creates counter generator. The first generated id encodes start + 1.
+func NewCounter(start uint64) *Counter {
+	c := &Counter{}
+	c.seq.Store(start)
+	return c
+}
+
+// Generate returns id for the next sequence value.
+func (g *Counter) Generate() (string, error) {
+	return g.Encode(g.seq.Add(1)), nil
+}
+
+// Encode encodes n to id.
+func (g *Counter) Encode(n uint64) string {
+	return encode(n, base62Alphabet)
+}
+
+// Decode restores number encoded to id by Encode.
+func (g *Counter) Decode(id string) (uint64, error) {
+	return decode(id, base62Alphabet)
+}
+
+// encode converts n to string in positional system with given alphabet.
+func encode(n uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+	if n == 0 {
+		return alphabet[:1]
+	}
+
+	var buf [64]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = alphabet[n%base]
+		n /= base
+	}
+	return string(buf[i:])
+}
+
+// decode converts string in positional system with given alphabet to number.
+// Returns ErrMalformedID for empty strings, unknown symbols and numbers that overflow uint64.
+func decode(s, alphabet string) (uint64, error) {
+	if s == "" {
+		return 0, ErrMalformedID
+	}
+
+	var n uint64
+	base := uint64(len(alphabet))
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(alphabet, s[i])
+		if d < 0 || n > (math.MaxUint64-uint64(d))/base {
+			return 0, ErrMalformedID
+		}
+		n = n*base + uint64(d)
+	}
+	return n, nil
+}
@@ -0,0 +1,137 @@
+// Package idgen provides generators of short ids for shortened urls.
+package idgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+// Supported generator kinds for config.Config.IDGenerator.
+const (
+	KindRandom  = "random"  // random base58 string of fixed length
+	KindCounter = "counter" // base62 encoded sequence number
+	KindSqids   = "sqids"   // sqids-style obfuscated sequence number
+)
+
+// maxAttempts is how many ids are tried before giving up on collisions.
+const maxAttempts = 10
+
+// startBatchSize is the number of urls read at once by Start.
+const startBatchSize = 1000
+
+// ErrUnknownGenerator is returned by New for unsupported generator kind.
+var ErrUnknownGenerator = errors.New("unknown id generator")
+
+// ErrAttemptsExceeded is returned when every generated id was already taken.
+var ErrAttemptsExceeded = errors.New("couldn't find free short id")
+
+// Generator generates ids for shortened urls.
+type Generator interface {
+	Generate() (string, error)
+}
+
+// URLSource lists stored urls, see repository.Repository.GetUrls.
+type URLSource interface {
+	GetUrls(ctx context.Context, afterID string, limit int) ([]models.ShortURL, error)
+}
+
+// sequenceCodec is implemented by sequence based generators.
+type sequenceCodec interface {
+	Encode(n uint64) string
+	Decode(id string) (uint64, error)
+}
+
+// New creates generator configured by conf.
+// start is the first value of the sequence for sequence based generators, see Start.
+func New(conf config.Config, start uint64) (Generator, error) {
+	switch conf.IDGenerator {
+	case KindRandom, "":
+		return NewRandom(conf.IDLength), nil
+	case KindCounter:
+		return NewCounter(start), nil
+	case KindSqids:
+		return NewSqids(conf.IDSalt, conf.IDLength, start), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownGenerator, conf.IDGenerator)
+	}
+}
+
+// Start returns the largest sequence value encoded in short ids of the urls in src by the sequence based
+// generator configured by conf, or 0 for other generators. The generator created by New with it
+// doesn't collide with stored urls, unlike the generator started from the number of urls,
+// which is less than the issued values after urls are removed or custom aliases are saved.
+// Ids that the generator couldn't produce, like most custom aliases, are skipped.
+func Start(ctx context.Context, conf config.Config, src URLSource) (uint64, error) {
+	var codec sequenceCodec
+	switch conf.IDGenerator {
+	case KindCounter:
+		codec = NewCounter(0)
+	case KindSqids:
+		codec = NewSqids(conf.IDSalt, conf.IDLength, 0)
+	default:
+		return 0, nil
+	}
+
+	var start uint64
+	afterID := ""
+	for {
+		urls, err := src.GetUrls(ctx, afterID, startBatchSize)
+		if err != nil {
+			return 0, fmt.Errorf("urls reading error: %w", err)
+		}
+		if len(urls) == 0 {
+			return start, nil
+		}
+
+		for _, url := range urls {
+			n, err := codec.Decode(url.ShortURL)
+			if err == nil && n > start && codec.Encode(n) == url.ShortURL {
+				start = n
+			}
+		}
+		afterID = urls[len(urls)-1].ShortURL
+	}
+}
+
+// Reserve generates id and passes it to save until save succeeds.
+// New id is generated only when save returns repository.ErrIDTaken,
+// any other error is returned to caller together with the last id.
+func Reserve(gen Generator, save func(id string) error) (string, error) {
+	ids, err := ReserveBatch(gen, 1, func(ids []string) error {
+		return save(ids[0])
+	})
+	if len(ids) == 0 {
+		return "", err
+	}
+	return ids[0], err
+}
+
+// ReserveBatch works like Reserve, but generates count ids at once.
+// The whole batch is regenerated when save reports collision.
+func ReserveBatch(gen Generator, count int, save func(ids []string) error) ([]string, error) {
+	ids := make([]string, count)
+	if count == 0 {
+		// nothing to regenerate, so collision can't be resolved by retry
+		return ids, save(ids)
+	}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		for i := range ids {
+			id, err := gen.Generate()
+			if err != nil {
+				return nil, fmt.Errorf("id generation error: %w", err)
+			}
+			ids[i] = id
+		}
+
+		err := save(ids)
+		if !errors.Is(err, repository.ErrIDTaken) {
+			return ids, err
+		}
+	}
+	return nil, ErrAttemptsExceeded
+}
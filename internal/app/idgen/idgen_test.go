@@ -0,0 +1,208 @@
+package idgen
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+func TestRandom_Generate(t *testing.T) {
+	gen := NewRandom(6)
+
+	for i := 0; i < 100; i++ {
+		id, err := gen.Generate()
+		require.NoError(t, err)
+		assert.Len(t, id, 6)
+		for _, c := range id {
+			assert.True(t, strings.ContainsRune(base58Alphabet, c), "unexpected symbol %q", c)
+		}
+	}
+}
+
+func TestCounter_Generate(t *testing.T) {
+	gen := NewCounter(60)
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		id, err := gen.Generate()
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	assert.Equal(t, []string{"z", "10", "11", "12"}, ids)
+}
+
+func TestSqids_EncodeDecode(t *testing.T) {
+	gen := NewSqids("salt", 6, 0)
+
+	seen := make(map[string]bool)
+	for n := uint64(0); n < 5000; n++ {
+		id := gen.Encode(n)
+		assert.GreaterOrEqual(t, len(id), 6)
+		assert.False(t, seen[id], "duplicate id %s", id)
+		seen[id] = true
+
+		decoded, err := gen.Decode(id)
+		require.NoError(t, err)
+		assert.Equal(t, n, decoded)
+	}
+
+	_, err := gen.Decode("!")
+	assert.ErrorIs(t, err, ErrMalformedID)
+}
+
+func TestSqids_Salt(t *testing.T) {
+	a := NewSqids("first", 0, 0)
+	b := NewSqids("second", 0, 0)
+
+	assert.NotEqual(t, a.Encode(42), b.Encode(42))
+	assert.Equal(t, a.Encode(42), NewSqids("first", 0, 0).Encode(42))
+}
+
+func TestNew(t *testing.T) {
+	for _, kind := range []string{"", KindRandom, KindCounter, KindSqids} {
+		gen, err := New(config.Config{IDGenerator: kind, IDLength: 8}, 0)
+		require.NoError(t, err, kind)
+		assert.NotNil(t, gen)
+	}
+
+	_, err := New(config.Config{IDGenerator: "uuid"}, 0)
+	assert.ErrorIs(t, err, ErrUnknownGenerator)
+}
+
+func TestReserve(t *testing.T) {
+	t.Run("retries taken ids", func(t *testing.T) {
+		var tried []string
+		id, err := Reserve(NewCounter(0), func(id string) error {
+			tried = append(tried, id)
+			if len(tried) < 3 {
+				return repository.ErrIDTaken
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "3", id)
+		assert.Equal(t, []string{"1", "2", "3"}, tried)
+	})
+
+	t.Run("returns other errors", func(t *testing.T) {
+		id, err := Reserve(NewCounter(0), func(string) error {
+			return repository.ErrDuplicate
+		})
+		assert.ErrorIs(t, err, repository.ErrDuplicate)
+		assert.Equal(t, "1", id)
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		calls := 0
+		_, err := Reserve(NewCounter(0), func(string) error {
+			calls++
+			return repository.ErrIDTaken
+		})
+		assert.True(t, errors.Is(err, ErrAttemptsExceeded))
+		assert.Equal(t, maxAttempts, calls)
+	})
+}
+
+func TestReserveBatch(t *testing.T) {
+	attempts := 0
+	ids, err := ReserveBatch(NewCounter(0), 2, func(ids []string) error {
+		attempts++
+		if attempts == 1 {
+			return repository.ErrIDTaken
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3", "4"}, ids)
+}
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		alias string
+		err   error
+	}{
+		{alias: "q3-launch"},
+		{alias: "Summer_2024"},
+		{alias: "ab", err: ErrInvalidAlias},
+		{alias: strings.Repeat("a", 65), err: ErrInvalidAlias},
+		{alias: "with space", err: ErrInvalidAlias},
+		{alias: "ключ", err: ErrInvalidAlias},
+		{alias: "ping", err: ErrReservedAlias},
+		{alias: "API", err: ErrReservedAlias},
+	}
+
+	for _, test := range tests {
+		t.Run(test.alias, func(t *testing.T) {
+			err := ValidateAlias(test.alias)
+			if test.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	ctx := context.Background()
+	sqids := NewSqids("salt", 6, 0)
+
+	tests := []struct {
+		name string
+		conf config.Config
+		ids  []string
+		want uint64
+	}{
+		{name: "random", conf: config.Config{IDGenerator: KindRandom}, ids: []string{"1", "2"}, want: 0},
+		{name: "empty storage", conf: config.Config{IDGenerator: KindCounter}, want: 0},
+		{
+			name: "counter",
+			conf: config.Config{IDGenerator: KindCounter},
+			// "1" and "3" are purged, "my-link" is a custom alias
+			ids:  []string{"2", "Z", "my-link"},
+			want: 35,
+		},
+		{
+			name: "sqids",
+			conf: config.Config{IDGenerator: KindSqids, IDSalt: "salt", IDLength: 6},
+			ids:  []string{sqids.Encode(5), sqids.Encode(40), "my-link"},
+			want: 40,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewInMemoryRepository()
+			for _, id := range tt.ids {
+				require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: id, OriginalURL: "https://example.com/" + id}))
+			}
+
+			start, err := Start(ctx, tt.conf, repo)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, start)
+		})
+	}
+}
+
+func TestCounter_Decode(t *testing.T) {
+	gen := NewCounter(0)
+	for _, n := range []uint64{0, 1, 61, 62, 1 << 40, math.MaxUint64} {
+		got, err := gen.Decode(gen.Encode(n))
+		require.NoError(t, err)
+		assert.Equal(t, n, got)
+	}
+
+	for _, id := range []string{"", "my-link", "zzzzzzzzzzzzzzzzzzzz"} {
+		_, err := gen.Decode(id)
+		assert.ErrorIs(t, err, ErrMalformedID, id)
+	}
+}
@@ -0,0 +1,40 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// base58Alphabet doesn't contain look-alike symbols 0, O, I and l.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// defaultLength is used when configured length is not positive.
+const defaultLength = 8
+
+// Random generates random base58 ids of fixed length.
+type Random struct {
+	length int
+}
+
+// NewRandom creates generator of random ids with given length.
+func NewRandom(length int) *Random {
+	if length <= 0 {
+		length = defaultLength
+	}
+	return &Random{length: length}
+}
+
+// Generate returns new random id.
+func (g *Random) Generate() (string, error) {
+	max := big.NewInt(int64(len(base58Alphabet)))
+	id := make([]byte, g.length)
+	for i := range id {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("random reading error: %w", err)
+		}
+		id[i] = base58Alphabet[n.Int64()]
+	}
+	return string(id), nil
+}
@@ -0,0 +1,104 @@
+package idgen
+
+import (
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrMalformedID is returned by Sqids.Decode for ids that weren't produced by the generator.
+var ErrMalformedID = errors.New("malformed id")
+
+// Sqids generates ids from a sequence like Sqids/Hashids do: the number is encoded
+// with an alphabet shuffled by salt and rotated by the number itself,
+// so consecutive values don't look consecutive. Ids are padded up to minLength.
+type Sqids struct {
+	seq       atomic.Uint64
+	alphabet  string
+	minLength int
+}
+
+// NewSqids creates sqids-style generator. The first generated id encodes start + 1.
+func NewSqids(salt string, minLength int, start uint64) *Sqids {
+	g := &Sqids{
+		alphabet:  shuffle(base62Alphabet, salt),
+		minLength: minLength,
+	}
+	g.seq.Store(start)
+	return g
+}
+
+// Generate returns id for the next sequence value.
+func (g *Sqids) Generate() (string, error) {
+	return g.Encode(g.seq.Add(1)), nil
+}
+
+// Encode encodes n to id.
+// The first symbol selects alphabet rotation, the second symbol of rotated alphabet
+// is used as separator before padding and the rest are the digits.
+func (g *Sqids) Encode(n uint64) string {
+	alphabet := g.rotated(int(n % uint64(len(g.alphabet))))
+	separator, digits := alphabet[1], alphabet[2:]
+
+	var id strings.Builder
+	id.WriteByte(alphabet[0])
+	id.WriteString(encode(n, digits))
+
+	if id.Len() < g.minLength {
+		id.WriteByte(separator)
+		for i := 0; id.Len() < g.minLength; i++ {
+			id.WriteByte(digits[(int(n%uint64(len(digits)))+i)%len(digits)])
+		}
+	}
+	return id.String()
+}
+
+// Decode restores number encoded to id by Encode.
+func (g *Sqids) Decode(id string) (uint64, error) {
+	if len(id) < 2 { //nolint:gomnd // prefix and at least one digit
+		return 0, ErrMalformedID
+	}
+
+	offset := strings.IndexByte(g.alphabet, id[0])
+	if offset < 0 {
+		return 0, ErrMalformedID
+	}
+	alphabet := g.rotated(offset)
+	separator, digits := alphabet[1], alphabet[2:]
+
+	body := id[1:]
+	if i := strings.IndexByte(body, separator); i >= 0 {
+		body = body[:i]
+	}
+	n, err := decode(body, digits)
+	if err != nil {
+		return 0, err
+	}
+
+	if n%uint64(len(g.alphabet)) != uint64(offset) {
+		return 0, ErrMalformedID
+	}
+	return n, nil
+}
+
+// rotated returns generator alphabet rotated left by offset.
+func (g *Sqids) rotated(offset int) string {
+	return g.alphabet[offset:] + g.alphabet[:offset]
+}
+
+// shuffle deterministically shuffles alphabet with salt. Empty salt keeps alphabet as is.
+func shuffle(alphabet, salt string) string {
+	if salt == "" {
+		return alphabet
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(salt))
+	rnd := rand.New(rand.NewSource(int64(h.Sum64()))) //nolint:gosec // shuffle must be reproducible
+
+	b := []byte(alphabet)
+	rnd.Shuffle(len(b), func(i, j int) { b[i], b[j] = b[j], b[i] })
+	return string(b)
+}
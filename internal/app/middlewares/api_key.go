@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+// bearerPrefix - схема заголовка Authorization, в которой передается API-ключ.
+const bearerPrefix = "Bearer "
+
+// APIKeyResolver возвращает идентификатор владельца действующего API-ключа.
+type APIKeyResolver interface {
+	ResolveAPIKey(ctx context.Context, key string) (string, error)
+}
+
+// APIKeyAuth представляет middleware для аутентификации по API-ключу из заголовка "Authorization: Bearer <key>".
+// Если заголовок передан, идентификатор владельца ключа сохраняется в контексте запроса,
+// и созданные запросом ссылки принадлежат владельцу ключа. Для недействительного ключа возвращает
+// статус http.StatusUnauthorized. Запросы без ключа передаются следующему обработчику без изменений.
+func (m Middleware) APIKeyAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, ok, err := m.apiKeyContext(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if ok {
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiKeyContext проверяет API-ключ из заголовка Authorization и возвращает контекст запроса
+// с идентификатором владельца ключа. ok равен false, если ключ не передан.
+func (m Middleware) apiKeyContext(r *http.Request) (ctx context.Context, ok bool, err error) {
+	header := r.Header.Get("Authorization")
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return nil, false, nil
+	}
+
+	userID, err := m.APIKeys.ResolveAPIKey(r.Context(), strings.TrimSpace(header[len(bearerPrefix):]))
+	if err != nil {
+		return nil, true, fmt.Errorf("api key resolving error: %w", err)
+	}
+	return tokenutils.WithAPIKeyUserID(r.Context(), userID), true, nil
+}
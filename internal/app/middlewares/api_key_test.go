@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+// keysStub считает действительным только ключ "valid", принадлежащий пользователю "owner".
+type keysStub struct{}
+
+func (keysStub) ResolveAPIKey(_ context.Context, key string) (string, error) {
+	if key != "valid" {
+		return "", errors.New("unknown key")
+	}
+	return "owner", nil
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	middleware := Middleware{APIKeys: keysStub{}}
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+		expectedUserID string
+	}{
+		{name: "no key", expectedStatus: http.StatusOK},
+		{name: "valid key", authorization: "Bearer valid", expectedStatus: http.StatusOK, expectedUserID: "owner"},
+		{name: "lowercase scheme", authorization: "bearer valid", expectedStatus: http.StatusOK, expectedUserID: "owner"},
+		{name: "invalid key", authorization: "Bearer invalid", expectedStatus: http.StatusUnauthorized},
+		{name: "other scheme", authorization: "Basic dXNlcjpwYXNz", expectedStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var userID string
+			handler := middleware.APIKeyAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userID, _ = tokenutils.UserIDFromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, test.expectedStatus, w.Code)
+			assert.Equal(t, test.expectedUserID, userID)
+		})
+	}
+}
@@ -1,22 +1,50 @@
 package middlewares
 
 import (
-	"errors"
 	"net/http"
 
 	"github.com/GTedya/shortener/internal/app/tokenutils"
 )
 
 // AuthCheck представляет middleware для проверки авторизации пользователя.
-// Если токен пользователя отсутствует в запросе, возвращает статус http.StatusUnauthorized.
-// В противном случае передает запрос следующему обработчику.
+// Пользователь аутентифицируется по API-ключу из заголовка "Authorization: Bearer <key>",
+// а если ключ не передан - по токену из куки.
+// Если ключ недействителен, токен отсутствует, поврежден или его срок истек, возвращает статус http.StatusUnauthorized.
+// В противном случае сохраняет идентификатор пользователя в контексте запроса
+// (см. tokenutils.UserIDFromContext) и передает запрос следующему обработчику.
 func (m Middleware) AuthCheck(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		_, err := r.Cookie(tokenutils.UserIDCookieName)
-		if errors.Is(err, http.ErrNoCookie) {
+		ctx, ok, err := m.apiKeyContext(r)
+		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
-		next.ServeHTTP(w, r)
+		if ok {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		m.TokenAuthCheck(next).ServeHTTP(w, r)
+	})
+}
+
+// TokenAuthCheck представляет middleware для проверки авторизации пользователя только по токену из куки.
+// API-ключ не принимается, поэтому им нельзя выпускать и отзывать API-ключи владельца.
+// Если токен отсутствует, поврежден или его срок истек, возвращает статус http.StatusUnauthorized.
+// В противном случае сохраняет идентификатор пользователя в контексте запроса
+// (см. tokenutils.UserIDFromContext) и передает запрос следующему обработчику.
+func (m Middleware) TokenAuthCheck(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(tokenutils.UserIDCookieName)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		userID, _, err := m.Tokens.Parse(cookie.Value)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(tokenutils.WithUserID(r.Context(), userID)))
 	})
 }
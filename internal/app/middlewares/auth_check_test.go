@@ -2,10 +2,14 @@ package middlewares
 
 import (
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/tokenutils"
 )
 
 func TestAuthCheck(t *testing.T) {
@@ -28,3 +32,68 @@ func TestAuthCheck(t *testing.T) {
 
 	assert.Equal(t, http.StatusUnauthorized, wWithoutToken.Code, "Статус код ответа должен быть 401 (Unauthorized)")
 }
+
+func TestAuthCheck_APIKey(t *testing.T) {
+	middleware := Middleware{APIKeys: keysStub{}}
+	authMiddleware := middleware.AuthCheck(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := tokenutils.UserIDFromContext(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "owner", userID)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.Header.Set("Authorization", "Bearer valid")
+	w := httptest.NewRecorder()
+	authMiddleware.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	req.AddCookie(&http.Cookie{Name: tokenutils.UserIDCookieName, Value: "token"})
+	w = httptest.NewRecorder()
+	authMiddleware.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthCheck_Token(t *testing.T) {
+	tokens, err := tokenutils.NewKeyring(config.Config{SecretKeys: "k2:second"})
+	require.NoError(t, err)
+	retired, err := tokenutils.NewKeyring(config.Config{SecretKeys: "k1:first"})
+	require.NoError(t, err)
+
+	valid, err := tokens.Issue("owner")
+	require.NoError(t, err)
+	retiredToken, err := retired.Issue("owner")
+	require.NoError(t, err)
+
+	middleware := Middleware{Tokens: tokens}
+
+	tests := []struct {
+		name           string
+		token          string
+		expectedStatus int
+		expectedUserID string
+	}{
+		{name: "valid token", token: valid, expectedStatus: http.StatusOK, expectedUserID: "owner"},
+		{name: "garbage", token: "fake_token", expectedStatus: http.StatusUnauthorized},
+		{name: "retired key", token: retiredToken, expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var userID string
+			authMiddleware := middleware.AuthCheck(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userID, _ = tokenutils.UserIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+			req.AddCookie(&http.Cookie{Name: tokenutils.UserIDCookieName, Value: test.token})
+			w := httptest.NewRecorder()
+
+			authMiddleware.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatus, w.Code)
+			assert.Equal(t, test.expectedUserID, userID)
+		})
+	}
+}
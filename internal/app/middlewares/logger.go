@@ -6,13 +6,17 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/internal/app/tokenutils"
 )
 
 // Middleware представляет middleware для логирования HTTP-запросов.
 type Middleware struct {
 	Log           *zap.SugaredLogger
-	SecretKey     string
+	Tokens        *tokenutils.Keyring
+	APIKeys       APIKeyResolver
 	TrustedSubnet string
+	HSTSMaxAge    time.Duration // срок действия Strict-Transport-Security, 0 отключает заголовок
 }
 
 // loggerWriter представляет структуру для перехвата записи в ответ.
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+// RefreshToken представляет middleware для перевыпуска токена пользователя.
+// Если токен из куки действителен, но выдан не самым новым ключом, в ответ добавляется
+// куки с тем же идентификатором пользователя, зашифрованным самым новым ключом.
+// Так после смены ключа пользователи не теряют доступ к своим ссылкам.
+func (m Middleware) RefreshToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(tokenutils.UserIDCookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, rotate, err := m.Tokens.Parse(cookie.Value)
+		if err == nil && rotate {
+			if err = tokenutils.AddEncryptedUserIDToCookie(&w, userID, m.Tokens); err != nil {
+				m.Log.Errorw("token refreshing error", err)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+func TestRefreshToken(t *testing.T) {
+	old, err := tokenutils.NewKeyring(config.Config{SecretKeys: "k1:first"})
+	require.NoError(t, err)
+	tokens, err := tokenutils.NewKeyring(config.Config{SecretKeys: "k1:first,k2:second"})
+	require.NoError(t, err)
+
+	oldToken, err := old.Issue("user")
+	require.NoError(t, err)
+	newToken, err := tokens.Issue("user")
+	require.NoError(t, err)
+
+	middleware := Middleware{Log: zap.S(), Tokens: tokens}
+	refresh := middleware.RefreshToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "user", tokenutils.GetUserID(r, tokens))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("old key", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		r.AddCookie(&http.Cookie{Name: tokenutils.UserIDCookieName, Value: oldToken})
+		w := httptest.NewRecorder()
+
+		refresh.ServeHTTP(w, r)
+
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		userID, rotate, err := tokens.Parse(cookies[0].Value)
+		require.NoError(t, err)
+		assert.Equal(t, "user", userID)
+		assert.False(t, rotate)
+	})
+
+	t.Run("newest key", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		r.AddCookie(&http.Cookie{Name: tokenutils.UserIDCookieName, Value: newToken})
+		w := httptest.NewRecorder()
+
+		refresh.ServeHTTP(w, r)
+
+		assert.Empty(t, w.Result().Cookies())
+	})
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// SecurityHeaders добавляет в ответ заголовки, запрещающие браузеру небезопасное обращение с ответом.
+// Strict-Transport-Security добавляется только к ответам по HTTPS, как требует RFC 6797,
+// и только если задан срок его действия HSTSMaxAge.
+func (m Middleware) SecurityHeaders(next http.Handler) http.Handler {
+	hsts := "max-age=" + strconv.FormatInt(int64(m.HSTSMaxAge.Seconds()), 10)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		if r.TLS != nil && m.HSTSMaxAge > 0 {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		header.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+
+		next.ServeHTTP(w, r)
+	})
+}
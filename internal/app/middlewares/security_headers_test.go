@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		tls    bool
+		want   string
+	}{
+		{name: "https", maxAge: 24 * time.Hour, tls: true, want: "max-age=86400"},
+		{name: "plain http", maxAge: 24 * time.Hour, tls: false, want: ""},
+		{name: "hsts disabled", maxAge: 0, tls: true, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			w := httptest.NewRecorder()
+
+			Middleware{HSTSMaxAge: tt.maxAge}.SecurityHeaders(ok).ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.want, w.Header().Get("Strict-Transport-Security"))
+			assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+			assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+			assert.NotEmpty(t, w.Header().Get("Content-Security-Policy"))
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/helpers"
+)
+
+// LoadLegacy reads the legacy JSON array of urls, see helpers.FileData, into memory, so it can be migrated
+// like any other storage. Legacy urls have no owner and get current time as creation time.
+// Returns the repository and short ids of urls skipped because their original url is shortened by another url.
+func LoadLegacy(ctx context.Context, path string) (*repository.InMemoryRepository, []string, error) {
+	// FileData treats missing file as empty, but missing legacy file is a mistake of the caller
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("legacy file error: %w", err)
+	}
+
+	data := make(map[string]string)
+	if err := helpers.FileData(data, path); err != nil {
+		return nil, nil, fmt.Errorf("legacy file reading error: %w", err)
+	}
+
+	// urls are saved in order of short ids, so the same urls are skipped every time
+	ids := make([]string, 0, len(data))
+	for id := range data {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	repo := repository.NewInMemoryRepository()
+	var skipped []string
+	for _, id := range ids {
+		err := repo.Save(ctx, models.ShortURL{ShortURL: id, OriginalURL: data[id]})
+		if errors.Is(err, repository.ErrDuplicate) {
+			skipped = append(skipped, id)
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("legacy url saving error: %w", err)
+		}
+	}
+	return repo, skipped, nil
+}
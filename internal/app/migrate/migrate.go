@@ -0,0 +1,423 @@
+// Package migrate copies urls with their clicks and revisions, and api keys from one storage to another.
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+// DefaultBatchSize is the number of urls read and saved at once if Options.BatchSize isn't set.
+const DefaultBatchSize = 500
+
+// ErrConflict is returned by Run if short ids or original urls of some urls are taken in the destination
+// by other urls. Conflicting urls are logged and skipped.
+var ErrConflict = errors.New("urls conflict with destination urls")
+
+// ErrUnverified is returned by Run if some urls of the source are missing in the destination after migration.
+var ErrUnverified = errors.New("urls are missing in destination")
+
+// Source is the storage urls are read from. Clicks and revisions are read for every migrated url,
+// so the source must look them up without reading all of them, e.g. a file storage is loaded
+// by repository.LoadFileSnapshot.
+type Source interface {
+	GetUrls(ctx context.Context, afterID string, limit int) ([]models.ShortURL, error)
+	GetClicks(ctx context.Context, id string) ([]models.Click, error)
+	GetRevisions(ctx context.Context, id string) ([]models.Revision, error)
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+}
+
+// Destination is the storage urls are saved to.
+type Destination interface {
+	Save(ctx context.Context, shortURL models.ShortURL) error
+	SaveBatch(ctx context.Context, batch []models.ShortURL) error
+	GetByID(ctx context.Context, id string) (models.ShortURL, error)
+	ShortenByURL(ctx context.Context, url string) (models.ShortURL, error)
+	GetClicks(ctx context.Context, id string) ([]models.Click, error)
+	SaveClicks(ctx context.Context, clicks []models.Click) error
+	GetRevisions(ctx context.Context, id string) ([]models.Revision, error)
+	SaveRevisions(ctx context.Context, revisions []models.Revision) error
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	SaveAPIKey(ctx context.Context, key models.APIKey) error
+}
+
+// Options contains settings of migration.
+type Options struct {
+	BatchSize int // number of urls read and saved at once, DefaultBatchSize if zero
+	// path of the file where progress is saved after every batch, so interrupted migration is resumed
+	// from it, migration starts from the beginning if empty
+	Checkpoint string
+	DryRun     bool // only compare urls with the destination, nothing is saved
+}
+
+// Report contains counts of migrated urls. Counts of resumed migration include urls migrated before.
+type Report struct {
+	Read      int `json:"read"`      // urls read from the source
+	Copied    int `json:"copied"`    // urls saved to the destination, or that would be saved in dry run
+	Existing  int `json:"existing"`  // urls that are already in the destination, e.g. saved before interruption
+	Conflicts int `json:"conflicts"` // urls skipped because their short id or original url is taken
+	// clicks and revisions of copied and existing urls saved to the destination, history of a url is copied
+	// only if the url has none in the destination, so it isn't duplicated by repeated migration
+	Clicks    int `json:"clicks"`
+	Revisions int `json:"revisions"`
+	APIKeys   int `json:"-"` // api keys saved to the destination, keys with taken ids are skipped
+	// counts of verification, which compares all urls of the source with the destination after migration
+	SourceURLs int `json:"-"` // urls in the source
+	Verified   int `json:"-"` // urls of the source found in the destination
+}
+
+// checkpoint is the progress of migration.
+type checkpoint struct {
+	AfterID string `json:"after_id"` // short id of the last migrated url, urls are read ordered by short id
+	Report  Report `json:"report"`   // counts of urls migrated up to AfterID
+}
+
+// Migrator copies urls from the source to the destination.
+type Migrator struct {
+	src  Source
+	dst  Destination
+	opts Options
+	log  *zap.SugaredLogger
+}
+
+// New creates migrator.
+func New(src Source, dst Destination, opts Options, log *zap.SugaredLogger) *Migrator {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	return &Migrator{src: src, dst: dst, opts: opts, log: log}
+}
+
+// Run copies urls with their owners, deletion, expiration, clicks and revisions from the source to the destination
+// batch by batch, then copies api keys and verifies that all urls of the source are in the destination.
+// Urls already saved to the destination are skipped, so migration can be run again, e.g. after conflicts
+// are resolved. The checkpoint is removed once all urls are read.
+func (m *Migrator) Run(ctx context.Context) (Report, error) {
+	progress, err := m.loadCheckpoint()
+	if err != nil {
+		return Report{}, err
+	}
+	if progress.AfterID != "" {
+		m.log.Infow("migration resumed", "after", progress.AfterID, "read", progress.Report.Read)
+	}
+
+	if err = m.migrate(ctx, &progress); err != nil {
+		return progress.Report, err
+	}
+	if err = m.migrateAPIKeys(ctx, &progress.Report); err != nil {
+		return progress.Report, err
+	}
+	if err = m.removeCheckpoint(); err != nil {
+		return progress.Report, err
+	}
+
+	report := progress.Report
+	if report.SourceURLs, report.Verified, err = m.verify(ctx); err != nil {
+		return report, err
+	}
+	if report.Conflicts > 0 {
+		return report, fmt.Errorf("%w: %d", ErrConflict, report.Conflicts)
+	}
+	if !m.opts.DryRun && report.Verified < report.SourceURLs {
+		return report, fmt.Errorf("%w: %d", ErrUnverified, report.SourceURLs-report.Verified)
+	}
+	return report, nil
+}
+
+// migrate migrates urls after the checkpoint and saves the checkpoint after every batch.
+func (m *Migrator) migrate(ctx context.Context, progress *checkpoint) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("migration interrupted: %w", err)
+		}
+
+		urls, err := m.src.GetUrls(ctx, progress.AfterID, m.opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("source reading error: %w", err)
+		}
+		if len(urls) == 0 {
+			return nil
+		}
+
+		migrated, err := m.migrateBatch(ctx, urls, &progress.Report)
+		if err != nil {
+			return err
+		}
+		for _, url := range migrated {
+			if err = m.migrateHistory(ctx, url.ShortURL, &progress.Report); err != nil {
+				return err
+			}
+		}
+		progress.AfterID = urls[len(urls)-1].ShortURL
+		if err = m.saveCheckpoint(*progress); err != nil {
+			return err
+		}
+	}
+}
+
+// migrateBatch saves the urls to the destination and counts them in the report.
+// The batch is saved at once, if some of its urls are taken the urls are saved one by one.
+// Returns the urls which are in the destination after saving, that is all urls except conflicting ones.
+func (m *Migrator) migrateBatch(ctx context.Context, urls []models.ShortURL, report *Report) ([]models.ShortURL, error) {
+	report.Read += len(urls)
+	if m.opts.DryRun {
+		return m.checkAll(ctx, urls, report)
+	}
+
+	err := m.dst.SaveBatch(ctx, urls)
+	if err == nil {
+		report.Copied += len(urls)
+		return urls, nil
+	}
+	if !isTaken(err) {
+		return nil, fmt.Errorf("destination saving error: %w", err)
+	}
+
+	migrated := make([]models.ShortURL, 0, len(urls))
+	for _, url := range urls {
+		err = m.dst.Save(ctx, url)
+		switch {
+		case err == nil:
+			report.Copied++
+			migrated = append(migrated, url)
+		case isTaken(err):
+			ok, err := m.check(ctx, url, report)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				migrated = append(migrated, url)
+			}
+		default:
+			return nil, fmt.Errorf("destination saving error: %w", err)
+		}
+	}
+	return migrated, nil
+}
+
+// checkAll checks the urls one by one and returns the urls which aren't conflicting.
+func (m *Migrator) checkAll(ctx context.Context, urls []models.ShortURL, report *Report) ([]models.ShortURL, error) {
+	migrated := make([]models.ShortURL, 0, len(urls))
+	for _, url := range urls {
+		ok, err := m.check(ctx, url, report)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			migrated = append(migrated, url)
+		}
+	}
+	return migrated, nil
+}
+
+// check counts the url as existing if it is already in the destination, as conflicting if its short id
+// or original url is taken by another url, otherwise as copied. Reports whether the url isn't conflicting.
+func (m *Migrator) check(ctx context.Context, url models.ShortURL, report *Report) (bool, error) {
+	found, err := m.dst.GetByID(ctx, url.ShortURL)
+	if err == nil {
+		if sameURL(found, url) {
+			report.Existing++
+			return true, nil
+		}
+		m.conflict(url, "short id is taken", report)
+		return false, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return false, fmt.Errorf("destination reading error: %w", err)
+	}
+
+	found, err = m.dst.ShortenByURL(ctx, url.OriginalURL)
+	if err == nil {
+		m.conflict(url, "original url is shortened as "+found.ShortURL, report)
+		return false, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return false, fmt.Errorf("destination reading error: %w", err)
+	}
+
+	report.Copied++
+	return true, nil
+}
+
+// migrateHistory copies clicks and revisions of the url with given id unless the url already has them
+// in the destination, e.g. copied before interruption, and counts them in the report.
+func (m *Migrator) migrateHistory(ctx context.Context, id string, report *Report) error {
+	clicks, err := m.src.GetClicks(ctx, id)
+	if err != nil {
+		return fmt.Errorf("source clicks reading error: %w", err)
+	}
+	if len(clicks) > 0 {
+		found, err := m.dst.GetClicks(ctx, id)
+		if err != nil {
+			return fmt.Errorf("destination clicks reading error: %w", err)
+		}
+		if len(found) == 0 {
+			if !m.opts.DryRun {
+				if err = m.dst.SaveClicks(ctx, clicks); err != nil {
+					return fmt.Errorf("destination clicks saving error: %w", err)
+				}
+			}
+			report.Clicks += len(clicks)
+		}
+	}
+
+	revisions, err := m.src.GetRevisions(ctx, id)
+	if err != nil {
+		return fmt.Errorf("source revisions reading error: %w", err)
+	}
+	if len(revisions) > 0 {
+		found, err := m.dst.GetRevisions(ctx, id)
+		if err != nil {
+			return fmt.Errorf("destination revisions reading error: %w", err)
+		}
+		if len(found) == 0 {
+			if !m.opts.DryRun {
+				if err = m.dst.SaveRevisions(ctx, revisions); err != nil {
+					return fmt.Errorf("destination revisions saving error: %w", err)
+				}
+			}
+			report.Revisions += len(revisions)
+		}
+	}
+	return nil
+}
+
+// migrateAPIKeys copies api keys, including revoked ones, whose ids aren't taken in the destination.
+func (m *Migrator) migrateAPIKeys(ctx context.Context, report *Report) error {
+	keys, err := m.src.GetAPIKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("source api keys reading error: %w", err)
+	}
+	found, err := m.dst.GetAPIKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("destination api keys reading error: %w", err)
+	}
+	taken := make(map[string]bool, len(found))
+	for _, key := range found {
+		taken[key.ID] = true
+	}
+
+	for _, key := range keys {
+		if taken[key.ID] {
+			continue
+		}
+		if !m.opts.DryRun {
+			err = m.dst.SaveAPIKey(ctx, key)
+			if errors.Is(err, repository.ErrIDTaken) {
+				m.log.Warnw("api key conflicts with destination", "id", key.ID, "reason", "hash is taken")
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("destination api key saving error: %w", err)
+			}
+		}
+		report.APIKeys++
+	}
+	return nil
+}
+
+// conflict logs the conflicting url and counts it in the report.
+func (m *Migrator) conflict(url models.ShortURL, reason string, report *Report) {
+	m.log.Warnw("url conflicts with destination", "id", url.ShortURL, "url", url.OriginalURL, "reason", reason)
+	report.Conflicts++
+}
+
+// verify reads all urls of the source and returns their number and number of them found in the destination.
+func (m *Migrator) verify(ctx context.Context) (int, int, error) {
+	total, verified := 0, 0
+	afterID := ""
+	for {
+		urls, err := m.src.GetUrls(ctx, afterID, m.opts.BatchSize)
+		if err != nil {
+			return total, verified, fmt.Errorf("source reading error: %w", err)
+		}
+		if len(urls) == 0 {
+			return total, verified, nil
+		}
+
+		for _, url := range urls {
+			total++
+			found, err := m.dst.GetByID(ctx, url.ShortURL)
+			if errors.Is(err, repository.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return total, verified, fmt.Errorf("destination reading error: %w", err)
+			}
+			if sameURL(found, url) {
+				verified++
+			}
+		}
+		afterID = urls[len(urls)-1].ShortURL
+	}
+}
+
+// sameURL reports whether the url found in the destination is the migrated url.
+func sameURL(found, url models.ShortURL) bool {
+	return found.OriginalURL == url.OriginalURL && found.CreatedByID == url.CreatedByID
+}
+
+// isTaken reports whether saving failed because the short id or the original url is taken.
+func isTaken(err error) bool {
+	return errors.Is(err, repository.ErrIDTaken) || errors.Is(err, repository.ErrDuplicate)
+}
+
+// loadCheckpoint reads progress of interrupted migration. Returns empty progress if there is no checkpoint.
+func (m *Migrator) loadCheckpoint() (checkpoint, error) {
+	if m.opts.Checkpoint == "" {
+		return checkpoint{}, nil
+	}
+
+	data, err := os.ReadFile(m.opts.Checkpoint)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint{}, nil
+	}
+	if err != nil {
+		return checkpoint{}, fmt.Errorf("checkpoint reading error: %w", err)
+	}
+
+	var progress checkpoint
+	if err = json.Unmarshal(data, &progress); err != nil {
+		return checkpoint{}, fmt.Errorf("checkpoint unmarshalling error: %w", err)
+	}
+	return progress, nil
+}
+
+// saveCheckpoint writes progress to a temporary file and renames it over the checkpoint,
+// so an interruption leaves either the previous or the new progress. Progress of dry run isn't saved.
+func (m *Migrator) saveCheckpoint(progress checkpoint) error {
+	if m.opts.Checkpoint == "" || m.opts.DryRun {
+		return nil
+	}
+
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("checkpoint marshalling error: %w", err)
+	}
+	tmpPath := m.opts.Checkpoint + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0600); err != nil { //nolint:gomnd // permission
+		return fmt.Errorf("checkpoint writing error: %w", err)
+	}
+	if err = os.Rename(tmpPath, m.opts.Checkpoint); err != nil {
+		return fmt.Errorf("checkpoint renaming error: %w", err)
+	}
+	return nil
+}
+
+// removeCheckpoint removes the checkpoint when all urls are read, so the next migration starts from the beginning.
+func (m *Migrator) removeCheckpoint() error {
+	if m.opts.Checkpoint == "" || m.opts.DryRun {
+		return nil
+	}
+	if err := os.Remove(m.opts.Checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("checkpoint removing error: %w", err)
+	}
+	return nil
+}
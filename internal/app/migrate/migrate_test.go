@@ -0,0 +1,210 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+// sourceRepo returns repository with three urls, one of them is deleted.
+func sourceRepo(t *testing.T) *repository.InMemoryRepository {
+	t.Helper()
+
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner"},
+		{ShortURL: "b", OriginalURL: "https://b.example", CreatedByID: "owner"},
+		{ShortURL: "c", OriginalURL: "https://c.example", CreatedByID: "other"},
+	}))
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "b", CreatedByID: "owner"}}))
+	return repo
+}
+
+func TestMigrator_Run(t *testing.T) {
+	ctx := context.Background()
+	src := sourceRepo(t)
+	dst := repository.NewInMemoryRepository()
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint")
+
+	report, err := New(src, dst, Options{BatchSize: 2, Checkpoint: checkpointPath}, zap.S()).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Read: 3, Copied: 3, SourceURLs: 3, Verified: 3}, report)
+	assert.NoFileExists(t, checkpointPath)
+
+	for _, id := range []string{"a", "b", "c"} {
+		want, err := src.GetByID(ctx, id)
+		require.NoError(t, err)
+		got, err := dst.GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	// migrated urls are skipped when migration is run again
+	report, err = New(src, dst, Options{BatchSize: 2}, zap.S()).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Read: 3, Existing: 3, SourceURLs: 3, Verified: 3}, report)
+}
+
+func TestMigrator_Run_history(t *testing.T) {
+	ctx := context.Background()
+	src := sourceRepo(t)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, src.SaveClicks(ctx, []models.Click{
+		{ShortURL: "a", At: created, IPHash: "1"},
+		{ShortURL: "a", At: created.Add(time.Minute), IPHash: "2"},
+		{ShortURL: "c", At: created, IPHash: "1"},
+	}))
+	require.NoError(t, src.UpdateURL(ctx,
+		models.ShortURL{ShortURL: "a", OriginalURL: "https://new.example", CreatedByID: "owner"}, created))
+	revoked := created.Add(time.Hour)
+	require.NoError(t, src.SaveAPIKey(ctx, models.APIKey{ID: "k1", UserID: "owner", Hash: "h1", CreatedAt: created}))
+	require.NoError(t, src.SaveAPIKey(ctx,
+		models.APIKey{ID: "k2", UserID: "owner", Hash: "h2", CreatedAt: created, RevokedAt: &revoked}))
+
+	// the url conflicting with the destination doesn't get clicks of the source url
+	dst := repository.NewInMemoryRepository()
+	require.NoError(t, dst.Save(ctx, models.ShortURL{ShortURL: "c", OriginalURL: "https://x.example", CreatedByID: "x"}))
+
+	// dry run only counts history
+	report, err := New(src, dst, Options{DryRun: true}, zap.S()).Run(ctx)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, 2, report.Clicks)
+	assert.Equal(t, 1, report.Revisions)
+	assert.Equal(t, 2, report.APIKeys)
+	keys, err := dst.GetAPIKeys(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	report, err = New(src, dst, Options{}, zap.S()).Run(ctx)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, Report{
+		Read: 3, Copied: 2, Conflicts: 1, Clicks: 2, Revisions: 1, APIKeys: 2, SourceURLs: 3, Verified: 2,
+	}, report)
+
+	clicks, err := dst.GetClicks(ctx, "a")
+	require.NoError(t, err)
+	assert.Len(t, clicks, 2)
+	clicks, err = dst.GetClicks(ctx, "c")
+	require.NoError(t, err)
+	assert.Empty(t, clicks)
+
+	revisions, err := dst.GetRevisions(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, "https://a.example", revisions[0].OriginalURL)
+
+	keys, err = dst.GetAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Nil(t, keys[0].RevokedAt)
+	require.NotNil(t, keys[1].RevokedAt)
+	assert.True(t, revoked.Equal(*keys[1].RevokedAt))
+
+	// history and keys aren't duplicated when migration is run again
+	report, err = New(src, dst, Options{}, zap.S()).Run(ctx)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, Report{Read: 3, Existing: 2, Conflicts: 1, SourceURLs: 3, Verified: 2}, report)
+	clicks, err = dst.GetClicks(ctx, "a")
+	require.NoError(t, err)
+	assert.Len(t, clicks, 2)
+}
+
+func TestMigrator_Run_resume(t *testing.T) {
+	ctx := context.Background()
+	src := sourceRepo(t)
+	dst := repository.NewInMemoryRepository()
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint")
+
+	// the first batch was migrated before interruption
+	urls, err := src.GetUrls(ctx, "", 2)
+	require.NoError(t, err)
+	require.NoError(t, dst.SaveBatch(ctx, urls))
+	require.NoError(t, os.WriteFile(checkpointPath,
+		[]byte(`{"after_id":"b","report":{"read":2,"copied":2}}`), 0600))
+
+	report, err := New(src, dst, Options{BatchSize: 2, Checkpoint: checkpointPath}, zap.S()).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Read: 3, Copied: 3, SourceURLs: 3, Verified: 3}, report)
+	assert.NoFileExists(t, checkpointPath)
+}
+
+func TestMigrator_Run_interrupted(t *testing.T) {
+	src := sourceRepo(t)
+	dst := repository.NewInMemoryRepository()
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := New(src, dst, Options{Checkpoint: checkpointPath}, zap.S()).Run(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoFileExists(t, checkpointPath)
+}
+
+func TestMigrator_Run_conflicts(t *testing.T) {
+	ctx := context.Background()
+	src := sourceRepo(t)
+	dst := repository.NewInMemoryRepository()
+	require.NoError(t, dst.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "a", OriginalURL: "https://another.example", CreatedByID: "owner"},
+		{ShortURL: "x", OriginalURL: "https://b.example", CreatedByID: "owner"},
+	}))
+
+	report, err := New(src, dst, Options{}, zap.S()).Run(ctx)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, Report{Read: 3, Copied: 1, Conflicts: 2, SourceURLs: 3, Verified: 1}, report)
+
+	url, err := dst.GetByID(ctx, "c")
+	require.NoError(t, err)
+	assert.Equal(t, "https://c.example", url.OriginalURL)
+}
+
+func TestMigrator_Run_dryRun(t *testing.T) {
+	ctx := context.Background()
+	src := sourceRepo(t)
+	dst := repository.NewInMemoryRepository()
+	require.NoError(t, dst.Save(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner"}))
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint")
+
+	report, err := New(src, dst, Options{Checkpoint: checkpointPath, DryRun: true}, zap.S()).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Read: 3, Copied: 2, Existing: 1, SourceURLs: 3, Verified: 1}, report)
+	assert.NoFileExists(t, checkpointPath)
+
+	_, err = dst.GetByID(ctx, "b")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestLoadLegacy(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "legacy.json")
+	legacy := `[
+{"uuid":"1","short_url":"b","original_url":"https://a.example"},
+{"uuid":"2","short_url":"a","original_url":"https://a.example"},
+{"uuid":"3","short_url":"c","original_url":"https://c.example"}
+]`
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0600))
+
+	repo, skipped, err := LoadLegacy(ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, skipped)
+
+	urls, err := repo.GetUrls(ctx, "", 0)
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+	assert.Equal(t, "a", urls[0].ShortURL)
+	assert.Equal(t, "https://a.example", urls[0].OriginalURL)
+	assert.WithinDuration(t, time.Now(), urls[0].CreatedAt, time.Minute)
+
+	_, _, err = LoadLegacy(ctx, filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
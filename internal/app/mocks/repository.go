@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/GTedya/shortener/internal/app/models"
 	gomock "github.com/golang/mock/gomock"
@@ -63,6 +64,36 @@ func (mr *MockRepositoryMockRecorder) Close(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRepository)(nil).Close), arg0)
 }
 
+// DeleteExpired mocks base method.
+func (m *MockRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired", ctx, now)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired.
+func (mr *MockRepositoryMockRecorder) DeleteExpired(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockRepository)(nil).DeleteExpired), ctx, now)
+}
+
+// DeleteExpiredURLs mocks base method.
+func (m *MockRepository) DeleteExpiredURLs(ctx context.Context, ids []string, now time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredURLs", ctx, ids, now)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredURLs indicates an expected call of DeleteExpiredURLs.
+func (mr *MockRepositoryMockRecorder) DeleteExpiredURLs(ctx, ids, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredURLs", reflect.TypeOf((*MockRepository)(nil).DeleteExpiredURLs), ctx, ids, now)
+}
+
 // DeleteUrls mocks base method.
 func (m *MockRepository) DeleteUrls(ctx context.Context, urls []models.ShortURL) error {
 	m.ctrl.T.Helper()
@@ -77,6 +108,36 @@ func (mr *MockRepositoryMockRecorder) DeleteUrls(ctx, urls interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUrls", reflect.TypeOf((*MockRepository)(nil).DeleteUrls), ctx, urls)
 }
 
+// GetAPIKey mocks base method.
+func (m *MockRepository) GetAPIKey(ctx context.Context, hash string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKey", ctx, hash)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKey indicates an expected call of GetAPIKey.
+func (mr *MockRepositoryMockRecorder) GetAPIKey(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKey", reflect.TypeOf((*MockRepository)(nil).GetAPIKey), ctx, hash)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockRepository) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", ctx)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockRepositoryMockRecorder) GetAPIKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockRepository)(nil).GetAPIKeys), ctx)
+}
+
 // GetByID mocks base method.
 func (m *MockRepository) GetByID(ctx context.Context, id string) (models.ShortURL, error) {
 	m.ctrl.T.Helper()
@@ -92,6 +153,66 @@ func (mr *MockRepositoryMockRecorder) GetByID(ctx, id interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockRepository)(nil).GetByID), ctx, id)
 }
 
+// GetClickStats mocks base method.
+func (m *MockRepository) GetClickStats(ctx context.Context, id string) (models.LinkStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClickStats", ctx, id)
+	ret0, _ := ret[0].(models.LinkStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetClickStats indicates an expected call of GetClickStats.
+func (mr *MockRepositoryMockRecorder) GetClickStats(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClickStats", reflect.TypeOf((*MockRepository)(nil).GetClickStats), ctx, id)
+}
+
+// GetClicks mocks base method.
+func (m *MockRepository) GetClicks(ctx context.Context, id string) ([]models.Click, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClicks", ctx, id)
+	ret0, _ := ret[0].([]models.Click)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetClicks indicates an expected call of GetClicks.
+func (mr *MockRepositoryMockRecorder) GetClicks(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClicks", reflect.TypeOf((*MockRepository)(nil).GetClicks), ctx, id)
+}
+
+// GetRevisions mocks base method.
+func (m *MockRepository) GetRevisions(ctx context.Context, id string) ([]models.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevisions", ctx, id)
+	ret0, _ := ret[0].([]models.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevisions indicates an expected call of GetRevisions.
+func (mr *MockRepositoryMockRecorder) GetRevisions(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevisions", reflect.TypeOf((*MockRepository)(nil).GetRevisions), ctx, id)
+}
+
+// GetUrls mocks base method.
+func (m *MockRepository) GetUrls(ctx context.Context, afterID string, limit int) ([]models.ShortURL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUrls", ctx, afterID, limit)
+	ret0, _ := ret[0].([]models.ShortURL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUrls indicates an expected call of GetUrls.
+func (mr *MockRepositoryMockRecorder) GetUrls(ctx, afterID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUrls", reflect.TypeOf((*MockRepository)(nil).GetUrls), ctx, afterID, limit)
+}
+
 // GetUsersAndUrlsCount mocks base method.
 func (m *MockRepository) GetUsersAndUrlsCount(ctx context.Context) (int, int, error) {
 	m.ctrl.T.Helper()
@@ -109,18 +230,62 @@ func (mr *MockRepositoryMockRecorder) GetUsersAndUrlsCount(ctx interface{}) *gom
 }
 
 // GetUsersUrls mocks base method.
-func (m *MockRepository) GetUsersUrls(ctx context.Context, userID string) ([]models.ShortURL, error) {
+func (m *MockRepository) GetUsersUrls(ctx context.Context, userID string, query models.URLQuery) ([]models.ShortURL, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUsersUrls", ctx, userID)
+	ret := m.ctrl.Call(m, "GetUsersUrls", ctx, userID, query)
 	ret0, _ := ret[0].([]models.ShortURL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUsersUrls indicates an expected call of GetUsersUrls.
-func (mr *MockRepositoryMockRecorder) GetUsersUrls(ctx, userID interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetUsersUrls(ctx, userID, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersUrls", reflect.TypeOf((*MockRepository)(nil).GetUsersUrls), ctx, userID, query)
+}
+
+// PurgeDeleted mocks base method.
+func (m *MockRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeleted", ctx, deletedBefore)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeleted indicates an expected call of PurgeDeleted.
+func (mr *MockRepositoryMockRecorder) PurgeDeleted(ctx, deletedBefore interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersUrls", reflect.TypeOf((*MockRepository)(nil).GetUsersUrls), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeleted", reflect.TypeOf((*MockRepository)(nil).PurgeDeleted), ctx, deletedBefore)
+}
+
+// RestoreUrls mocks base method.
+func (m *MockRepository) RestoreUrls(ctx context.Context, urls []models.ShortURL, deletedAfter time.Time) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUrls", ctx, urls, deletedAfter)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreUrls indicates an expected call of RestoreUrls.
+func (mr *MockRepositoryMockRecorder) RestoreUrls(ctx, urls, deletedAfter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUrls", reflect.TypeOf((*MockRepository)(nil).RestoreUrls), ctx, urls, deletedAfter)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockRepository) RevokeAPIKey(ctx context.Context, key models.APIKey, revokedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, key, revokedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockRepositoryMockRecorder) RevokeAPIKey(ctx, key, revokedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockRepository)(nil).RevokeAPIKey), ctx, key, revokedAt)
 }
 
 // Save mocks base method.
@@ -137,6 +302,20 @@ func (mr *MockRepositoryMockRecorder) Save(ctx, shortURL interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockRepository)(nil).Save), ctx, shortURL)
 }
 
+// SaveAPIKey mocks base method.
+func (m *MockRepository) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAPIKey", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAPIKey indicates an expected call of SaveAPIKey.
+func (mr *MockRepositoryMockRecorder) SaveAPIKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAPIKey", reflect.TypeOf((*MockRepository)(nil).SaveAPIKey), ctx, key)
+}
+
 // SaveBatch mocks base method.
 func (m *MockRepository) SaveBatch(ctx context.Context, batch []models.ShortURL) error {
 	m.ctrl.T.Helper()
@@ -151,6 +330,34 @@ func (mr *MockRepositoryMockRecorder) SaveBatch(ctx, batch interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBatch", reflect.TypeOf((*MockRepository)(nil).SaveBatch), ctx, batch)
 }
 
+// SaveClicks mocks base method.
+func (m *MockRepository) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveClicks", ctx, clicks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveClicks indicates an expected call of SaveClicks.
+func (mr *MockRepositoryMockRecorder) SaveClicks(ctx, clicks interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveClicks", reflect.TypeOf((*MockRepository)(nil).SaveClicks), ctx, clicks)
+}
+
+// SaveRevisions mocks base method.
+func (m *MockRepository) SaveRevisions(ctx context.Context, revisions []models.Revision) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRevisions", ctx, revisions)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveRevisions indicates an expected call of SaveRevisions.
+func (mr *MockRepositoryMockRecorder) SaveRevisions(ctx, revisions interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRevisions", reflect.TypeOf((*MockRepository)(nil).SaveRevisions), ctx, revisions)
+}
+
 // ShortenByURL mocks base method.
 func (m *MockRepository) ShortenByURL(ctx context.Context, url string) (models.ShortURL, error) {
 	m.ctrl.T.Helper()
@@ -165,3 +372,17 @@ func (mr *MockRepositoryMockRecorder) ShortenByURL(ctx, url interface{}) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenByURL", reflect.TypeOf((*MockRepository)(nil).ShortenByURL), ctx, url)
 }
+
+// UpdateURL mocks base method.
+func (m *MockRepository) UpdateURL(ctx context.Context, shortURL models.ShortURL, changedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateURL", ctx, shortURL, changedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateURL indicates an expected call of UpdateURL.
+func (mr *MockRepositoryMockRecorder) UpdateURL(ctx, shortURL, changedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateURL", reflect.TypeOf((*MockRepository)(nil).UpdateURL), ctx, shortURL, changedAt)
+}
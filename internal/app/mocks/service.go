@@ -90,6 +90,36 @@ func (mr *MockShortenerInterfaceMockRecorder) GenerateNewUserID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateNewUserID", reflect.TypeOf((*MockShortenerInterface)(nil).GenerateNewUserID))
 }
 
+// GetClickStats mocks base method.
+func (m *MockShortenerInterface) GetClickStats(ctx context.Context, id, userID string) (models.LinkStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClickStats", ctx, id, userID)
+	ret0, _ := ret[0].(models.LinkStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetClickStats indicates an expected call of GetClickStats.
+func (mr *MockShortenerInterfaceMockRecorder) GetClickStats(ctx, id, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClickStats", reflect.TypeOf((*MockShortenerInterface)(nil).GetClickStats), ctx, id, userID)
+}
+
+// GetRevisions mocks base method.
+func (m *MockShortenerInterface) GetRevisions(ctx context.Context, id, userID string) ([]models.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevisions", ctx, id, userID)
+	ret0, _ := ret[0].([]models.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevisions indicates an expected call of GetRevisions.
+func (mr *MockShortenerInterfaceMockRecorder) GetRevisions(ctx, id, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevisions", reflect.TypeOf((*MockShortenerInterface)(nil).GetRevisions), ctx, id, userID)
+}
+
 // GetStats mocks base method.
 func (m *MockShortenerInterface) GetStats(ctx context.Context) (models.Stats, error) {
 	m.ctrl.T.Helper()
@@ -106,18 +136,18 @@ func (mr *MockShortenerInterfaceMockRecorder) GetStats(ctx interface{}) *gomock.
 }
 
 // GetUrlsCreatedBy mocks base method.
-func (m *MockShortenerInterface) GetUrlsCreatedBy(ctx context.Context, userID string) ([]models.ShortURL, error) {
+func (m *MockShortenerInterface) GetUrlsCreatedBy(ctx context.Context, userID string, query models.URLQuery) (models.URLPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUrlsCreatedBy", ctx, userID)
-	ret0, _ := ret[0].([]models.ShortURL)
+	ret := m.ctrl.Call(m, "GetUrlsCreatedBy", ctx, userID, query)
+	ret0, _ := ret[0].(models.URLPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUrlsCreatedBy indicates an expected call of GetUrlsCreatedBy.
-func (mr *MockShortenerInterfaceMockRecorder) GetUrlsCreatedBy(ctx, userID interface{}) *gomock.Call {
+func (mr *MockShortenerInterfaceMockRecorder) GetUrlsCreatedBy(ctx, userID, query interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUrlsCreatedBy", reflect.TypeOf((*MockShortenerInterface)(nil).GetUrlsCreatedBy), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUrlsCreatedBy", reflect.TypeOf((*MockShortenerInterface)(nil).GetUrlsCreatedBy), ctx, userID, query)
 }
 
 // HealthCheck mocks base method.
@@ -134,19 +164,79 @@ func (mr *MockShortenerInterfaceMockRecorder) HealthCheck(ctx interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HealthCheck", reflect.TypeOf((*MockShortenerInterface)(nil).HealthCheck), ctx)
 }
 
+// IssueAPIKey mocks base method.
+func (m *MockShortenerInterface) IssueAPIKey(ctx context.Context, userID string) (models.APIKey, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssueAPIKey", ctx, userID)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// IssueAPIKey indicates an expected call of IssueAPIKey.
+func (mr *MockShortenerInterfaceMockRecorder) IssueAPIKey(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueAPIKey", reflect.TypeOf((*MockShortenerInterface)(nil).IssueAPIKey), ctx, userID)
+}
+
+// ResolveAPIKey mocks base method.
+func (m *MockShortenerInterface) ResolveAPIKey(ctx context.Context, key string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolveAPIKey", ctx, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolveAPIKey indicates an expected call of ResolveAPIKey.
+func (mr *MockShortenerInterfaceMockRecorder) ResolveAPIKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveAPIKey", reflect.TypeOf((*MockShortenerInterface)(nil).ResolveAPIKey), ctx, key)
+}
+
+// RestoreUrls mocks base method.
+func (m *MockShortenerInterface) RestoreUrls(ctx context.Context, ids []string, userID string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUrls", ctx, ids, userID)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreUrls indicates an expected call of RestoreUrls.
+func (mr *MockShortenerInterfaceMockRecorder) RestoreUrls(ctx, ids, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUrls", reflect.TypeOf((*MockShortenerInterface)(nil).RestoreUrls), ctx, ids, userID)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockShortenerInterface) RevokeAPIKey(ctx context.Context, id, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, id, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockShortenerInterfaceMockRecorder) RevokeAPIKey(ctx, id, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockShortenerInterface)(nil).RevokeAPIKey), ctx, id, userID)
+}
+
 // Shorten mocks base method.
-func (m *MockShortenerInterface) Shorten(ctx context.Context, url, userID string) (models.ShortURL, error) {
+func (m *MockShortenerInterface) Shorten(ctx context.Context, shortURL models.ShortURL) (models.ShortURL, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Shorten", ctx, url, userID)
+	ret := m.ctrl.Call(m, "Shorten", ctx, shortURL)
 	ret0, _ := ret[0].(models.ShortURL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Shorten indicates an expected call of Shorten.
-func (mr *MockShortenerInterfaceMockRecorder) Shorten(ctx, url, userID interface{}) *gomock.Call {
+func (mr *MockShortenerInterfaceMockRecorder) Shorten(ctx, shortURL interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shorten", reflect.TypeOf((*MockShortenerInterface)(nil).Shorten), ctx, url, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shorten", reflect.TypeOf((*MockShortenerInterface)(nil).Shorten), ctx, shortURL)
 }
 
 // ShortenBatch mocks base method.
@@ -163,3 +253,17 @@ func (mr *MockShortenerInterfaceMockRecorder) ShortenBatch(ctx, batch, userID in
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShortenBatch", reflect.TypeOf((*MockShortenerInterface)(nil).ShortenBatch), ctx, batch, userID)
 }
+
+// UpdateURL mocks base method.
+func (m *MockShortenerInterface) UpdateURL(ctx context.Context, shortURL models.ShortURL) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateURL", ctx, shortURL)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateURL indicates an expected call of UpdateURL.
+func (mr *MockShortenerInterfaceMockRecorder) UpdateURL(ctx, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateURL", reflect.TypeOf((*MockShortenerInterface)(nil).UpdateURL), ctx, shortURL)
+}
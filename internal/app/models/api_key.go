@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// APIKey is a key that lets server-to-server clients act on behalf of a user without the user cookie.
+// Only the hash of the key is stored, the key itself is shown to the user once when issued.
+type APIKey struct {
+	ID        string     `json:"id"`                   // public id of the key used to revoke it
+	UserID    string     `json:"user_id"`              // owner of the key, links created with the key belong to the owner
+	Hash      string     `json:"hash"`                 // hex encoded SHA-256 of the key
+	CreatedAt time.Time  `json:"created_at"`           // time when the key was issued
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // time when the key was revoked, nil if it is active
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Click is a single redirect through a short link.
+type Click struct {
+	ShortURL  string    `json:"id"`         // short id of the link
+	At        time.Time `json:"at"`         // time of the redirect
+	Referrer  string    `json:"referrer"`   // Referer header of the request
+	UserAgent string    `json:"user_agent"` // User-Agent header of the request
+	IPHash    string    `json:"ip_hash"`    // salted hash of client IP, used to count unique visitors
+}
+
+// LinkStats is click analytics of a single link.
+type LinkStats struct {
+	TotalClicks    int           `json:"total_clicks"`    // number of redirects
+	UniqueVisitors int           `json:"unique_visitors"` // number of distinct client IPs
+	Daily          []DailyClicks `json:"daily"`           // clicks per day in UTC, ordered by date
+}
+
+// DailyClicks is number of clicks in a day.
+type DailyClicks struct {
+	Date   string `json:"date"`   // date in YYYY-MM-DD format
+	Clicks int    `json:"clicks"` // number of redirects during the day
+}
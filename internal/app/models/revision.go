@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Revision is a previous destination of a short link saved when the link was edited.
+type Revision struct {
+	ShortURL    string    `json:"id"`         // short id of the link
+	OriginalURL string    `json:"url"`        // destination before the change
+	ChangedAt   time.Time `json:"changed_at"` // time when the destination was replaced
+}
@@ -1,9 +1,45 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidExpiration is returned for non-positive ttl, expiration time in the past
+// or when both ttl and expiration time are given.
+var ErrInvalidExpiration = errors.New("invalid expiration")
+
 // ShortURL is main entity for system.
 type ShortURL struct {
-	OriginalURL string `json:"url"`        // original URL that was shortened
-	ShortURL    string `json:"id"`         // unique ShortURL of the short URL.
-	CreatedByID string `json:"created_by"` // ShortURL of the user who created the short URL
-	IsDeleted   bool   `json:"is_deleted"` // is used to mark a record as deleted
+	OriginalURL string     `json:"url"`                  // original URL that was shortened
+	ShortURL    string     `json:"id"`                   // unique ShortURL of the short URL.
+	CreatedByID string     `json:"created_by"`           // ShortURL of the user who created the short URL
+	IsDeleted   bool       `json:"is_deleted"`           // is used to mark a record as deleted
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // time after which the link stops working, nil if never
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // time when the link was deleted, nil if it isn't deleted
+	CreatedAt   time.Time  `json:"created_at"`           // time when the link was created
+}
+
+// IsExpired reports whether the link has expired by now.
+func (u ShortURL) IsExpired(now time.Time) bool {
+	return u.ExpiresAt != nil && !now.Before(*u.ExpiresAt)
+}
+
+// ExpirationTime calculates link expiration time either from ttl or from absolute expiresAt.
+// Returns nil if neither is set, so the link never expires.
+func ExpirationTime(ttl time.Duration, expiresAt *time.Time, now time.Time) (*time.Time, error) {
+	switch {
+	case ttl != 0 && expiresAt != nil:
+		return nil, fmt.Errorf("%w: only one of ttl and expires_at is allowed", ErrInvalidExpiration)
+	case ttl < 0:
+		return nil, fmt.Errorf("%w: ttl must be positive", ErrInvalidExpiration)
+	case ttl > 0:
+		at := now.Add(ttl)
+		return &at, nil
+	case expiresAt != nil && !expiresAt.After(now):
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidExpiration)
+	default:
+		return expiresAt, nil
+	}
 }
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Page sizes of user urls listing.
+const (
+	DefaultPageSize = 100  // page size if the client didn't set limit
+	MaxPageSize     = 1000 // the largest allowed limit
+)
+
+// ErrInvalidCursor is returned for a cursor that wasn't issued by EncodeCursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// URLCursor is position in user urls ordered by creation time, the short id breaks ties.
+type URLCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// URLQuery selects a page of user urls.
+type URLQuery struct {
+	Limit    int        // max number of urls, 0 means no limit
+	After    *URLCursor // urls after the cursor in the chosen order, nil means from the start
+	Desc     bool       // newest urls first
+	Deleted  *bool      // only deleted (true) or only not deleted (false) urls, nil means all urls
+	Domain   string     // only urls with this destination host, case-insensitive
+	Contains string     // only urls with destination containing this substring, case-insensitive
+}
+
+// URLPage is a page of user urls.
+type URLPage struct {
+	URLs       []ShortURL
+	NextCursor string // cursor of the next page, empty if it is the last page
+}
+
+// Matches reports whether the url passes filters of the query. Cursor and limit are not checked.
+func (q URLQuery) Matches(u ShortURL) bool {
+	if q.Deleted != nil && u.IsDeleted != *q.Deleted {
+		return false
+	}
+	if q.Domain != "" && URLHost(u.OriginalURL) != strings.ToLower(q.Domain) {
+		return false
+	}
+	if q.Contains != "" && !strings.Contains(strings.ToLower(u.OriginalURL), strings.ToLower(q.Contains)) {
+		return false
+	}
+	return true
+}
+
+// CursorOf returns the cursor pointing at the url.
+func CursorOf(u ShortURL) URLCursor {
+	return URLCursor{CreatedAt: u.CreatedAt, ID: u.ShortURL}
+}
+
+// Less reports whether the url at c goes before the url at other in the ascending order.
+func (c URLCursor) Less(other URLCursor) bool {
+	if !c.CreatedAt.Equal(other.CreatedAt) {
+		return c.CreatedAt.Before(other.CreatedAt)
+	}
+	return c.ID < other.ID
+}
+
+// EncodeCursor encodes the cursor as an opaque URL-safe string.
+func EncodeCursor(c URLCursor) string {
+	data, _ := json.Marshal(c) //nolint:errchkjson // the struct is always marshalable
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor decodes the cursor encoded by EncodeCursor.
+func DecodeCursor(s string) (URLCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return URLCursor{}, ErrInvalidCursor
+	}
+	var c URLCursor
+	if err = json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return URLCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// URLHost returns lowercase host of the url without port, or an empty string if the url cannot be parsed.
+func URLHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
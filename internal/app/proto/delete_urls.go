@@ -9,7 +9,7 @@ import (
 
 // DeleteUrls deletes the URLs specified in the request for the given user.
 //
-// If the user_id in the request is empty, it returns an InvalidArgument error.
+// If the user_id in the request is empty and the user is not authenticated from metadata, it returns an InvalidArgument error.
 //
 // If the user_id cannot be decoded and decrypted, it returns an InvalidArgument error.
 //
@@ -23,13 +23,13 @@ import (
 //   - An empty message if successful.
 //   - An error if the user ID is invalid or cannot be processed.
 func (s *Server) DeleteUrls(ctx context.Context, r *DeleteUrlsRequest) (*Empty, error) {
-	if r.GetUserId() == "" {
+	if r.GetUserId() == "" && !isAuthenticated(ctx) {
 		return nil, status.Error(codes.InvalidArgument, `user_id required`) //nolint:wrapcheck // it`s already wrapped
 	}
 
-	userID, err := s.decodeAndDecrypt(r.GetUserId())
+	userID, err := s.resolveUserID(ctx, r.GetUserId())
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, `invalid user_id`) //nolint:wrapcheck // it`s already wrapped
+		return nil, err
 	}
 
 	s.service.DeleteUrls(ctx, r.GetUrlIds(), userID)
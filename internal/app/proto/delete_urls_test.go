@@ -2,7 +2,6 @@ package pb
 
 import (
 	"context"
-	"encoding/hex"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -20,10 +19,10 @@ func TestServer_DeleteUrls(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockService := mock_service.NewMockShortenerInterface(ctrl)
-	// Correcting the SecretKey length to 16 bytes (128-bit key)
 	conf := config.Config{SecretKey: "0123456789abcdef"}
 	s := &Server{
 		service: mockService,
+		tokens:  testKeyring(t, conf),
 		config:  conf,
 	}
 
@@ -53,14 +52,13 @@ func TestServer_DeleteUrls(t *testing.T) {
 
 	t.Run("successful deletion", func(t *testing.T) {
 		userID := "validUserId"
-		encryptedUserID, err := tokenutils.Encrypt(userID, s.config.SecretKey)
+		encryptedUserID, err := s.tokens.Issue(userID)
 		if err != nil {
 			t.Fatalf("Failed to encrypt user ID: %v", err)
 		}
-		encodedUserID := hex.EncodeToString([]byte(encryptedUserID))
 
 		req := &DeleteUrlsRequest{
-			UserId: encodedUserID,
+			UserId: encryptedUserID,
 			UrlIds: []string{"url1", "url2"},
 		}
 		mockService.EXPECT().DeleteUrls(gomock.Any(), req.GetUrlIds(), userID).Return().Times(1)
@@ -69,4 +67,13 @@ func TestServer_DeleteUrls(t *testing.T) {
 		assert.NotNil(t, resp)
 		assert.NoError(t, err)
 	})
+	t.Run("user authenticated from metadata", func(t *testing.T) {
+		ctx := tokenutils.WithUserID(context.Background(), "owner")
+		req := &DeleteUrlsRequest{UrlIds: []string{"url1"}}
+		mockService.EXPECT().DeleteUrls(gomock.Any(), req.GetUrlIds(), "owner").Return().Times(1)
+
+		resp, err := s.DeleteUrls(ctx, req)
+		assert.NotNil(t, resp)
+		assert.NoError(t, err)
+	})
 }
@@ -2,6 +2,7 @@ package pb
 
 import (
 	"context"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -13,7 +14,7 @@ import (
 //
 // If the service encounters an error while expanding the URL, it returns an Internal error.
 //
-// If the expanded URL is not found or is deleted, it returns a NotFound error.
+// If the expanded URL is not found, is deleted or has expired, it returns a NotFound error.
 //
 // Parameters:
 //   - ctx: The context for the request.
@@ -40,6 +41,10 @@ func (s *Server) Expand(ctx context.Context, r *ExpandRequest) (*ExpandResponse,
 		return nil, status.Error(codes.NotFound, "url is deleted") //nolint:wrapcheck // it`s already wrapped
 	}
 
+	if shortURL.IsExpired(time.Now()) {
+		return nil, status.Error(codes.NotFound, "url is expired") //nolint:wrapcheck // it`s already wrapped
+	}
+
 	return &ExpandResponse{
 		FullUrl: shortURL.OriginalURL,
 	}, nil
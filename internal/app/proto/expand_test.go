@@ -3,6 +3,7 @@ package pb
 import (
 	"context"
 	"testing"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -23,6 +24,7 @@ func TestServer_Expand(t *testing.T) {
 	conf := config.Config{SecretKey: "0123456789abcdef"}
 	s := &Server{
 		service: mockService,
+		tokens:  testKeyring(t, conf),
 		config:  conf,
 	}
 
@@ -74,4 +76,18 @@ func TestServer_Expand(t *testing.T) {
 		assert.Equal(t, codes.NotFound, status.Code(err))
 		assert.Equal(t, "url is deleted", status.Convert(err).Message())
 	})
+
+	t.Run("url is expired", func(t *testing.T) {
+		urlID := "expiredUrlID"
+		expiresAt := time.Now().Add(-time.Second)
+		shortURL := models.ShortURL{OriginalURL: "http://example.com", ExpiresAt: &expiresAt}
+
+		mockService.EXPECT().Expand(gomock.Any(), urlID).Return(shortURL, nil).Times(1)
+
+		req := &ExpandRequest{UrlId: urlID}
+		resp, err := s.Expand(context.Background(), req)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		assert.Equal(t, "url is expired", status.Convert(err).Message())
+	})
 }
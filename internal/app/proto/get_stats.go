@@ -0,0 +1,62 @@
+package pb
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GetStats returns the number of shortened URLs and users, like GET /api/internal/stats.
+//
+// The method is allowed only for clients whose address belongs to config.Config.TrustedSubnet.
+// Unlike HTTP, where the address is taken from the X-Real-IP header, the peer address of the connection is used.
+// If the subnet is not configured or the client is outside of it, it returns a PermissionDenied error.
+//
+// If the service fails to get the stats, it returns an Internal error.
+//
+// Parameters:
+//   - ctx: The context for the request.
+//
+// Returns:
+//   - The service stats.
+//   - An error if the client is not trusted or the stats cannot be retrieved.
+func (s *Server) GetStats(ctx context.Context, _ *Empty) (*GetStatsResponse, error) {
+	if !s.isTrustedPeer(ctx) {
+		return nil, status.Error(codes.PermissionDenied, "forbidden") //nolint:wrapcheck // it`s already wrapped
+	}
+
+	stats, err := s.service.GetStats(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error()) //nolint:wrapcheck // it`s already wrapped
+	}
+
+	return &GetStatsResponse{
+		Urls:  int64(stats.UrlsCount),
+		Users: int64(stats.UsersCount),
+	}, nil
+}
+
+// isTrustedPeer reports whether the client address belongs to the trusted subnet.
+func (s *Server) isTrustedPeer(ctx context.Context) bool {
+	if s.config.TrustedSubnet == "" {
+		return false
+	}
+	_, subnet, err := net.ParseCIDR(s.config.TrustedSubnet)
+	if err != nil {
+		return false
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return false
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && subnet.Contains(ip)
+}
@@ -0,0 +1,71 @@
+package pb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/GTedya/shortener/config"
+	mock_service "github.com/GTedya/shortener/internal/app/mocks"
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// peerContext returns the context of a call made from the address.
+func peerContext(addr string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 50000}})
+}
+
+func TestServer_GetStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mock_service.NewMockShortenerInterface(ctrl)
+	s := &Server{
+		service: mockService,
+		config:  config.Config{TrustedSubnet: "192.168.1.0/24"},
+	}
+
+	t.Run("trusted peer", func(t *testing.T) {
+		mockService.EXPECT().GetStats(gomock.Any()).Return(models.Stats{UrlsCount: 3, UsersCount: 2}, nil).Times(1)
+
+		resp, err := s.GetStats(peerContext("192.168.1.10"), &Empty{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), resp.GetUrls())
+		assert.Equal(t, int64(2), resp.GetUsers())
+	})
+
+	t.Run("untrusted peer", func(t *testing.T) {
+		resp, err := s.GetStats(peerContext("10.0.0.1"), &Empty{})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("no peer", func(t *testing.T) {
+		resp, err := s.GetStats(context.Background(), &Empty{})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("subnet not configured", func(t *testing.T) {
+		s := &Server{service: mockService}
+		resp, err := s.GetStats(peerContext("192.168.1.10"), &Empty{})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		mockService.EXPECT().GetStats(gomock.Any()).Return(models.Stats{}, errors.New("db error")).Times(1)
+
+		resp, err := s.GetStats(peerContext("192.168.1.10"), &Empty{})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+}
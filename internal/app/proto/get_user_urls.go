@@ -0,0 +1,88 @@
+package pb
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// GetUserUrls returns the page of URLs shortened by the user, like GET /api/user/urls.
+//
+// If the user_id in the request is empty and the user is not authenticated from metadata, it returns an InvalidArgument error.
+//
+// If the limit is out of range or the cursor is invalid, it returns an InvalidArgument error.
+//
+// If the user_id cannot be decoded and decrypted, it returns an InvalidArgument error.
+//
+// If the service fails to get the URLs, it returns an Internal error.
+//
+// Parameters:
+//   - ctx: The context for the request.
+//   - r: The request containing the user ID, page parameters and filters.
+//
+// Returns:
+//   - The page of user's URLs and the cursor of the next page, an empty list if the user has none.
+//   - An error if the user ID is invalid or the URLs cannot be retrieved.
+func (s *Server) GetUserUrls(ctx context.Context, r *GetUserUrlsRequest) (*GetUserUrlsResponse, error) {
+	if r.GetUserId() == "" && !isAuthenticated(ctx) {
+		return nil, status.Error(codes.InvalidArgument, `user_id required`) //nolint:wrapcheck // it`s already wrapped
+	}
+
+	userID, err := s.resolveUserID(ctx, r.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := urlQuery(r)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.service.GetUrlsCreatedBy(ctx, userID, query)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error()) //nolint:wrapcheck // it`s already wrapped
+	}
+
+	resp := &GetUserUrlsResponse{Urls: make([]*UserUrl, 0, len(page.URLs)), NextCursor: page.NextCursor}
+	for _, url := range page.URLs {
+		item := &UserUrl{
+			UrlId:       url.ShortURL,
+			ShortUrl:    s.service.FormatShortURL(url.ShortURL),
+			OriginalUrl: url.OriginalURL,
+			IsDeleted:   url.IsDeleted,
+			CreatedAt:   url.CreatedAt.Unix(),
+		}
+		if url.ExpiresAt != nil {
+			item.ExpiresAt = url.ExpiresAt.Unix()
+		}
+		resp.Urls = append(resp.Urls, item)
+	}
+	return resp, nil
+}
+
+// urlQuery converts page parameters and filters of the request to the repository query.
+func urlQuery(r *GetUserUrlsRequest) (models.URLQuery, error) {
+	if r.GetLimit() < 0 || r.GetLimit() > models.MaxPageSize {
+		return models.URLQuery{}, status.Errorf(codes.InvalidArgument, //nolint:wrapcheck // it`s already wrapped
+			"limit must be from 0 to %d", models.MaxPageSize)
+	}
+
+	query := models.URLQuery{
+		Limit:    int(r.GetLimit()),
+		Desc:     r.GetDesc(),
+		Deleted:  r.Deleted,
+		Domain:   r.GetDomain(),
+		Contains: r.GetContains(),
+	}
+	if r.GetCursor() != "" {
+		cursor, err := models.DecodeCursor(r.GetCursor())
+		if err != nil {
+			return models.URLQuery{}, status.Error(codes.InvalidArgument, err.Error()) //nolint:wrapcheck // it`s already wrapped
+		}
+		query.After = &cursor
+	}
+	return query, nil
+}
@@ -0,0 +1,125 @@
+package pb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GTedya/shortener/config"
+	mock_service "github.com/GTedya/shortener/internal/app/mocks"
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+func TestServer_GetUserUrls(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mock_service.NewMockShortenerInterface(ctrl)
+	conf := config.Config{SecretKey: "0123456789abcdef"}
+	s := &Server{
+		service: mockService,
+		tokens:  testKeyring(t, conf),
+		config:  conf,
+	}
+
+	t.Run("missing user_id", func(t *testing.T) {
+		resp, err := s.GetUserUrls(context.Background(), &GetUserUrlsRequest{})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, "user_id required", status.Convert(err).Message())
+	})
+
+	t.Run("invalid user_id", func(t *testing.T) {
+		resp, err := s.GetUserUrls(context.Background(), &GetUserUrlsRequest{UserId: "invalid"})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("successful listing", func(t *testing.T) {
+		token, err := s.tokens.Issue("user1")
+		require.NoError(t, err)
+		expiresAt := time.Unix(1700000000, 0)
+		urls := []models.ShortURL{
+			{ShortURL: "abc", OriginalURL: "http://example.com", CreatedByID: "user1"},
+			{ShortURL: "def", OriginalURL: "http://example.org", CreatedByID: "user1", IsDeleted: true, ExpiresAt: &expiresAt},
+		}
+
+		mockService.EXPECT().GetUrlsCreatedBy(gomock.Any(), "user1", models.URLQuery{}).
+			Return(models.URLPage{URLs: urls, NextCursor: "next"}, nil).Times(1)
+		mockService.EXPECT().FormatShortURL("abc").Return("http://localhost:8080/abc").Times(1)
+		mockService.EXPECT().FormatShortURL("def").Return("http://localhost:8080/def").Times(1)
+
+		resp, err := s.GetUserUrls(context.Background(), &GetUserUrlsRequest{UserId: token})
+		require.NoError(t, err)
+		require.Len(t, resp.GetUrls(), 2)
+		assert.Equal(t, "abc", resp.GetUrls()[0].GetUrlId())
+		assert.Equal(t, "http://localhost:8080/abc", resp.GetUrls()[0].GetShortUrl())
+		assert.Equal(t, "http://example.com", resp.GetUrls()[0].GetOriginalUrl())
+		assert.Zero(t, resp.GetUrls()[0].GetExpiresAt())
+		assert.True(t, resp.GetUrls()[1].GetIsDeleted())
+		assert.Equal(t, expiresAt.Unix(), resp.GetUrls()[1].GetExpiresAt())
+		assert.Equal(t, "next", resp.GetNextCursor())
+	})
+
+	t.Run("page parameters and filters", func(t *testing.T) {
+		ctx := tokenutils.WithUserID(context.Background(), "owner")
+		cursor := models.URLCursor{CreatedAt: time.Unix(1700000000, 0).UTC(), ID: "abc"}
+		deleted := true
+		query := models.URLQuery{
+			Limit:    10,
+			After:    &cursor,
+			Desc:     true,
+			Deleted:  &deleted,
+			Domain:   "example.com",
+			Contains: "path",
+		}
+		mockService.EXPECT().GetUrlsCreatedBy(gomock.Any(), "owner", query).Return(models.URLPage{}, nil).Times(1)
+
+		_, err := s.GetUserUrls(ctx, &GetUserUrlsRequest{
+			Limit:    10,
+			Cursor:   models.EncodeCursor(cursor),
+			Desc:     true,
+			Deleted:  &deleted,
+			Domain:   "example.com",
+			Contains: "path",
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid page parameters", func(t *testing.T) {
+		ctx := tokenutils.WithUserID(context.Background(), "owner")
+
+		_, err := s.GetUserUrls(ctx, &GetUserUrlsRequest{Limit: models.MaxPageSize + 1})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = s.GetUserUrls(ctx, &GetUserUrlsRequest{Cursor: "invalid"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("user authenticated from metadata", func(t *testing.T) {
+		ctx := tokenutils.WithUserID(context.Background(), "owner")
+		mockService.EXPECT().GetUrlsCreatedBy(gomock.Any(), "owner", models.URLQuery{}).Return(models.URLPage{}, nil).Times(1)
+
+		resp, err := s.GetUserUrls(ctx, &GetUserUrlsRequest{})
+		require.NoError(t, err)
+		assert.Empty(t, resp.GetUrls())
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		ctx := tokenutils.WithUserID(context.Background(), "owner")
+		mockService.EXPECT().GetUrlsCreatedBy(gomock.Any(), "owner", models.URLQuery{}).
+			Return(models.URLPage{}, errors.New("db error")).Times(1)
+
+		resp, err := s.GetUserUrls(ctx, &GetUserUrlsRequest{})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+}
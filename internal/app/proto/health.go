@@ -0,0 +1,76 @@
+package pb
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/GTedya/shortener/internal/app/service"
+)
+
+// healthWatchInterval is how often Watch checks the storage for status changes.
+const healthWatchInterval = 5 * time.Second
+
+// healthServer implements the standard grpc.health.v1 service.
+// The status is SERVING while service.ShortenerInterface.HealthCheck succeeds, like GET /ping.
+// Both the overall server status ("") and the Shortener service status are reported.
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	service  service.ShortenerInterface
+	interval time.Duration
+}
+
+// Check returns the current serving status, or a NotFound error for an unknown service.
+func (h *healthServer) Check(
+	ctx context.Context,
+	r *grpc_health_v1.HealthCheckRequest,
+) (*grpc_health_v1.HealthCheckResponse, error) {
+	if !isKnownService(r.GetService()) {
+		return nil, status.Error(codes.NotFound, "unknown service") //nolint:wrapcheck // it`s already wrapped
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: h.status(ctx)}, nil
+}
+
+// Watch sends the current serving status and then every its change until the client cancels the call.
+// For an unknown service SERVICE_UNKNOWN status is sent, as the protocol requires.
+func (h *healthServer) Watch(r *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	last := grpc_health_v1.HealthCheckResponse_UNKNOWN
+	for {
+		current := grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN
+		if isKnownService(r.GetService()) {
+			current = h.status(ctx)
+		}
+		if current != last {
+			if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: current}); err != nil {
+				return err //nolint:wrapcheck // it`s a status error of the stream
+			}
+			last = current
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err() //nolint:wrapcheck // it`s already wrapped
+		case <-ticker.C:
+		}
+	}
+}
+
+// status checks the storage and returns the corresponding serving status.
+func (h *healthServer) status(ctx context.Context) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	if err := h.service.HealthCheck(ctx); err != nil {
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	return grpc_health_v1.HealthCheckResponse_SERVING
+}
+
+// isKnownService reports whether the health status of the service is reported.
+func isKnownService(name string) bool {
+	return name == "" || name == Shortener_ServiceDesc.ServiceName
+}
@@ -0,0 +1,97 @@
+package pb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/GTedya/shortener/config"
+	mock_service "github.com/GTedya/shortener/internal/app/mocks"
+)
+
+func TestServer_health(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mock_service.NewMockShortenerInterface(ctrl)
+	conf := config.Config{SecretKey: "0123456789abcdef"}
+	s, err := NewGRPCServer(mockService, testKeyring(t, conf), conf, zap.S())
+	require.NoError(t, err)
+	client := grpc_health_v1.NewHealthClient(dialTestServer(t, s))
+
+	t.Run("serving", func(t *testing.T) {
+		mockService.EXPECT().HealthCheck(gomock.Any()).Return(nil).Times(1)
+
+		resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.GetStatus())
+	})
+
+	t.Run("storage is unavailable", func(t *testing.T) {
+		mockService.EXPECT().HealthCheck(gomock.Any()).Return(errors.New("db error")).Times(1)
+
+		resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{
+			Service: Shortener_ServiceDesc.ServiceName,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, resp.GetStatus())
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestHealthServer_Watch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mock_service.NewMockShortenerInterface(ctrl)
+	h := &healthServer{service: mockService, interval: time.Millisecond}
+
+	gomock.InOrder(
+		mockService.EXPECT().HealthCheck(gomock.Any()).Return(nil).Times(2),
+		mockService.EXPECT().HealthCheck(gomock.Any()).Return(errors.New("db error")).AnyTimes(),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &healthWatchStream{ctx: ctx, sent: make(chan grpc_health_v1.HealthCheckResponse_ServingStatus, 2)}
+	done := make(chan error, 1)
+	go func() {
+		done <- h.Watch(&grpc_health_v1.HealthCheckRequest{}, stream)
+	}()
+
+	// only changes of the status are sent
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, <-stream.sent)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, <-stream.sent)
+
+	cancel()
+	assert.Equal(t, codes.Canceled, status.Code(<-done))
+	assert.Empty(t, stream.sent)
+}
+
+// healthWatchStream collects statuses sent by healthServer.Watch.
+type healthWatchStream struct {
+	grpc_health_v1.Health_WatchServer
+	ctx  context.Context
+	sent chan grpc_health_v1.HealthCheckResponse_ServingStatus
+}
+
+func (s *healthWatchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *healthWatchStream) Send(resp *grpc_health_v1.HealthCheckResponse) error {
+	s.sent <- resp.GetStatus()
+	return nil
+}
@@ -0,0 +1,234 @@
+package pb
+
+import (
+	"context"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+// Metadata keys, gRPC metadata keys are lowercase.
+const (
+	authorizationMetadata = "authorization"             // API key as "Bearer <key>"
+	userTokenMetadata     = tokenutils.UserIDCookieName // user token, the same as the HTTP user cookie
+	requestIDMetadata     = "x-request-id"              // request ID, generated if the client didn't send it
+)
+
+// bearerPrefix is the authorization scheme of API keys.
+const bearerPrefix = "Bearer "
+
+// requestIDKey is the context key of the request ID.
+type requestIDKey struct{}
+
+// RequestIDFromContext returns ID of the request handled with ctx, or an empty string outside of a call.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDInterceptor takes the request ID from incoming metadata or generates a new one,
+// puts it into the context and sends it back in the response header.
+func requestIDInterceptor(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	ctx, id := withRequestID(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, id)) // fails only outside of a real call
+	return handler(ctx, req)
+}
+
+// requestIDStreamInterceptor is the stream version of requestIDInterceptor.
+func requestIDStreamInterceptor(
+	srv any,
+	ss grpc.ServerStream,
+	_ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx, id := withRequestID(ss.Context())
+	_ = ss.SetHeader(metadata.Pairs(requestIDMetadata, id))
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// withRequestID returns the context with the request ID from incoming metadata or a new one.
+func withRequestID(ctx context.Context) (context.Context, string) {
+	id, ok := metadataValue(ctx, requestIDMetadata)
+	if !ok {
+		id = uuid.NewString()
+	}
+	return context.WithValue(ctx, requestIDKey{}, id), id
+}
+
+// loggingInterceptor logs every call like middlewares.Middleware.LogHandle logs HTTP requests.
+func (s *Server) loggingInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	s.logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+// loggingStreamInterceptor is the stream version of loggingInterceptor.
+func (s *Server) loggingStreamInterceptor(
+	srv any,
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	s.logCall(ss.Context(), info.FullMethod, start, err)
+	return err
+}
+
+// logCall logs the finished call.
+func (s *Server) logCall(ctx context.Context, method string, start time.Time, err error) {
+	s.log.Infoln(
+		"method", method,
+		"code", status.Code(err),
+		"duration", time.Since(start),
+		"request_id", RequestIDFromContext(ctx),
+	)
+}
+
+// recoveryInterceptor converts a panic in the handler into Internal error instead of crashing the server.
+func (s *Server) recoveryInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = s.recovered(ctx, info.FullMethod, p)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// recoveryStreamInterceptor is the stream version of recoveryInterceptor.
+func (s *Server) recoveryStreamInterceptor(
+	srv any,
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = s.recovered(ss.Context(), info.FullMethod, p)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// recovered logs the recovered panic and returns the error sent to the client.
+func (s *Server) recovered(ctx context.Context, method string, p any) error {
+	s.log.Errorw("gRPC handler panic",
+		"method", method,
+		"panic", p,
+		"request_id", RequestIDFromContext(ctx),
+		"stack", string(debug.Stack()),
+	)
+	return status.Error(codes.Internal, "internal error") //nolint:wrapcheck // it`s already wrapped
+}
+
+// authInterceptor authenticates the user from metadata and puts the user ID into the context,
+// see tokenutils.UserIDFromContext. API key from "authorization: Bearer <key>" takes precedence
+// over the user token from "user-id". Calls without credentials are passed as is, so handlers
+// may still take the user token from the message or create a new user.
+func (s *Server) authInterceptor(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authStreamInterceptor is the stream version of authInterceptor.
+func (s *Server) authStreamInterceptor(
+	srv any,
+	ss grpc.ServerStream,
+	_ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate returns the context with ID of the user authenticated from metadata.
+// Users authenticated by API key are marked, see tokenutils.IsAPIKeyUser, so they aren't issued user tokens.
+// Returns Unauthenticated error for invalid API key or user token.
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	if key, ok := apiKeyFromMetadata(ctx); ok {
+		userID, err := s.service.ResolveAPIKey(ctx, key)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, `invalid api key`) //nolint:wrapcheck // it`s already wrapped
+		}
+		return tokenutils.WithAPIKeyUserID(ctx, userID), nil
+	}
+
+	if token, ok := metadataValue(ctx, userTokenMetadata); ok {
+		userID, _, err := s.tokens.Parse(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, `invalid user token`) //nolint:wrapcheck // it`s already wrapped
+		}
+		return tokenutils.WithUserID(ctx, userID), nil
+	}
+
+	return ctx, nil
+}
+
+// apiKeyFromMetadata returns API key from "authorization: Bearer <key>" incoming metadata.
+func apiKeyFromMetadata(ctx context.Context) (string, bool) {
+	value, ok := metadataValue(ctx, authorizationMetadata)
+	if !ok || len(value) <= len(bearerPrefix) || !strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(value[len(bearerPrefix):]), true
+}
+
+// metadataValue returns the first non-empty value of the incoming metadata key.
+func metadataValue(ctx context.Context, key string) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	for _, value := range md.Get(key) {
+		if value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// contextStream is a server stream with the context replaced by interceptor.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the replaced context.
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
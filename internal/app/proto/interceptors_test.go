@@ -0,0 +1,166 @@
+package pb
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/GTedya/shortener/config"
+	mock_service "github.com/GTedya/shortener/internal/app/mocks"
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+// startTestServer serves s in memory and returns a client connected to it.
+func startTestServer(t *testing.T, s *Server) ShortenerClient {
+	t.Helper()
+	return NewShortenerClient(dialTestServer(t, s))
+}
+
+// dialTestServer serves s in memory and returns a connection to it.
+func dialTestServer(t *testing.T, s *Server) *grpc.ClientConn {
+	t.Helper()
+
+	conn, err := dialBufconn(listenTestServer(t, s), insecure.NewCredentials())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// listenTestServer serves s in memory until the test ends.
+func listenTestServer(t *testing.T, s *Server) *bufconn.Listener {
+	t.Helper()
+
+	s.register()
+	listener := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = s.server.Serve(listener)
+	}()
+	t.Cleanup(s.server.Stop)
+	return listener
+}
+
+// dialBufconn returns a connection to the in-memory server.
+func dialBufconn(listener *bufconn.Listener, creds credentials.TransportCredentials) (*grpc.ClientConn, error) {
+	return grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(creds),
+	)
+}
+
+func TestServer_interceptors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mock_service.NewMockShortenerInterface(ctrl)
+	conf := config.Config{SecretKey: "0123456789abcdef"}
+	s, err := NewGRPCServer(mockService, testKeyring(t, conf), conf, zap.S())
+	require.NoError(t, err)
+	client := startTestServer(t, s)
+
+	token, err := s.tokens.Issue("owner")
+	require.NoError(t, err)
+	req := &DeleteUrlsRequest{UrlIds: []string{"url1"}}
+
+	t.Run("api key", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer key")
+		mockService.EXPECT().ResolveAPIKey(gomock.Any(), "key").Return("owner", nil).Times(1)
+		mockService.EXPECT().DeleteUrls(gomock.Any(), req.GetUrlIds(), "owner").Return().Times(1)
+
+		_, err := client.DeleteUrls(ctx, req)
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid api key", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer key")
+		mockService.EXPECT().ResolveAPIKey(gomock.Any(), "key").Return("", repository.ErrNotFound).Times(1)
+
+		_, err := client.DeleteUrls(ctx, req)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("api key gets no user token", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer key")
+		mockService.EXPECT().ResolveAPIKey(gomock.Any(), "key").Return("owner", nil).Times(2)
+		mockService.EXPECT().Shorten(gomock.Any(), models.ShortURL{OriginalURL: "https://example.com", CreatedByID: "owner"}).
+			Return(models.ShortURL{ShortURL: "short"}, nil).Times(1)
+		mockService.EXPECT().FormatShortURL("short").Return("http://localhost:8080/short").Times(1)
+
+		resp, err := client.Shorten(ctx, &ShortenRequest{Url: "https://example.com"})
+		require.NoError(t, err)
+		assert.Empty(t, resp.GetUserId())
+
+		stream, err := client.ShortenStream(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.CloseSend())
+		streamResp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Empty(t, streamResp.GetUserId())
+	})
+
+	t.Run("user token", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "user-id", token)
+		mockService.EXPECT().DeleteUrls(gomock.Any(), req.GetUrlIds(), "owner").Return().Times(1)
+
+		_, err := client.DeleteUrls(ctx, req)
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid user token", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "user-id", "invalid")
+
+		_, err := client.DeleteUrls(ctx, req)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("request id", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "request-1")
+		mockService.EXPECT().DeleteUrls(gomock.Any(), req.GetUrlIds(), "owner").
+			Do(func(ctx context.Context, _ []string, _ string) {
+				assert.Equal(t, "request-1", RequestIDFromContext(ctx))
+			}).Times(1)
+
+		var header metadata.MD
+		_, err := client.DeleteUrls(ctx, &DeleteUrlsRequest{UserId: token, UrlIds: req.GetUrlIds()}, grpc.Header(&header))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"request-1"}, header.Get("x-request-id"))
+	})
+
+	t.Run("generated request id", func(t *testing.T) {
+		mockService.EXPECT().DeleteUrls(gomock.Any(), req.GetUrlIds(), "owner").Return().Times(1)
+
+		var header metadata.MD
+		_, err := client.DeleteUrls(context.Background(), &DeleteUrlsRequest{UserId: token, UrlIds: req.GetUrlIds()},
+			grpc.Header(&header))
+		assert.NoError(t, err)
+		assert.Len(t, header.Get("x-request-id"), 1)
+	})
+
+	t.Run("panic recovery", func(t *testing.T) {
+		mockService.EXPECT().Expand(gomock.Any(), "url1").Do(func(context.Context, string) {
+			panic("boom")
+		}).Times(1)
+
+		_, err := client.Expand(context.Background(), &ExpandRequest{UrlId: "url1"})
+		assert.Equal(t, codes.Internal, status.Code(err))
+
+		// the server keeps working after the panic
+		mockService.EXPECT().DeleteUrls(gomock.Any(), req.GetUrlIds(), "owner").Return().Times(1)
+		_, err = client.DeleteUrls(context.Background(), &DeleteUrlsRequest{UserId: token, UrlIds: req.GetUrlIds()})
+		assert.NoError(t, err)
+	})
+}
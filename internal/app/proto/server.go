@@ -2,93 +2,242 @@
 package pb
 
 import (
-	"encoding/hex"
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net"
+	"os"
 
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 
 	"github.com/GTedya/shortener/config"
 	"github.com/GTedya/shortener/internal/app/service"
+	"github.com/GTedya/shortener/internal/app/tlsutils"
 	"github.com/GTedya/shortener/internal/app/tokenutils"
 )
 
+// ErrACMECertificate is returned by NewGRPCServer if ACME is enabled but the certificate files of the gRPC server
+// don't exist. ACME certificates are obtained for the HTTPS server only, so the gRPC server needs its own
+// certificate and doesn't generate a self-signed one in this mode.
+var ErrACMECertificate = errors.New("gRPC server needs certificate files when ACME is enabled")
+
 // Server represents the gRPC server for the URL shortener service.
 type Server struct {
 	UnimplementedShortenerServer
 	server  *grpc.Server
 	service service.ShortenerInterface
+	tokens  *tokenutils.Keyring
 	config  config.Config
+	log     *zap.SugaredLogger
+
+	certs      *tlsutils.CertReloader // certificate of TLS server, nil for plaintext server
+	reloadCtx  context.Context        // done when the server is stopped, stops certificate reloading
+	stopReload context.CancelFunc     // finishes reloadCtx
 }
 
 // NewGRPCServer creates a new instance of the gRPC server with the provided service and configuration.
 //
+// Every call passes through the interceptor chain: request ID propagation, logging,
+// panic recovery and authentication from metadata.
+//
+// If config.Config.EnableHTTPS is set, the server accepts only TLS connections with the certificate
+// from config.Config.TLSCertFile and config.Config.TLSKeyFile, the same as the HTTPS server uses.
+// A self-signed certificate is generated if the files don't exist, unless config.Config.ACMEDomains is set:
+// ACME certificates are used by the HTTPS server only, so the files must exist then. The certificate is reloaded
+// on SIGHUP and when the files change. If config.Config.GRPCClientCA is set as well, clients must
+// present a certificate signed by it (mutual TLS). TLS version and cipher suites are configured
+// like for the HTTPS server.
+//
 // Parameters:
 //   - service: The service implementing the ShortenerInterface.
+//   - tokens: The keys used to issue and verify user tokens, shared with the HTTP server.
 //   - config: The configuration for the server.
+//   - log: The logger for calls and recovered panics.
 //
 // Returns:
 //   - A pointer to the new Server instance.
-//   - An error if the server could not be created.
+//   - An error if the TLS certificate could not be loaded or the TLS settings are invalid,
+//     ErrACMECertificate if ACME is enabled and the certificate files don't exist.
 func NewGRPCServer(
 	service service.ShortenerInterface,
+	tokens *tokenutils.Keyring,
 	config config.Config,
+	log *zap.SugaredLogger,
 ) (*Server, error) {
-	s := grpc.NewServer()
-	return &Server{
-		server:  s,
+	s := &Server{
 		service: service,
+		tokens:  tokens,
 		config:  config,
-	}, nil
+		log:     log,
+	}
+	s.reloadCtx, s.stopReload = context.WithCancel(context.Background())
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			requestIDInterceptor,
+			s.loggingInterceptor,
+			s.recoveryInterceptor,
+			s.authInterceptor,
+		),
+		grpc.ChainStreamInterceptor(
+			requestIDStreamInterceptor,
+			s.loggingStreamInterceptor,
+			s.recoveryStreamInterceptor,
+			s.authStreamInterceptor,
+		),
+	}
+	if config.EnableHTTPS {
+		creds, err := s.serverCredentials()
+		if err != nil {
+			s.stopReload()
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	s.server = grpc.NewServer(opts...)
+	return s, nil
 }
 
-// Run starts the gRPC server on the specified address.
+// serverCredentials loads the certificate of the server and returns TLS credentials from the configuration.
+// A self-signed certificate is generated if the certificate files don't exist, unless ACME is enabled,
+// see ErrACMECertificate.
+func (s *Server) serverCredentials() (credentials.TransportCredentials, error) {
+	if s.config.ACMEDomains != "" {
+		for _, path := range []string{s.config.TLSCertFile, s.config.TLSKeyFile} {
+			if _, err := os.Stat(path); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrACMECertificate, err)
+			}
+		}
+	} else {
+		err := tlsutils.EnsureCertificate(s.config.TLSCertFile, s.config.TLSKeyFile, s.config.GRPCAddress)
+		if err != nil {
+			return nil, fmt.Errorf("gRPC certificate error: %w", err)
+		}
+	}
+
+	var err error
+	s.certs, err = tlsutils.NewCertReloader(s.config.TLSCertFile, s.config.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC certificate error: %w", err)
+	}
+
+	tlsConfig, err := tlsutils.ServerConfig(s.config, s.certs, s.config.GRPCClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC TLS config error: %w", err)
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// Run starts the gRPC server on the address from config.Config.GRPCAddress.
 //
-// Registers the ShortenerServer implementation and listens for incoming connections.
+// Registers the ShortenerServer implementation and the grpc.health.v1 service, starts certificate reloading
+// for TLS server and listens for incoming connections.
+// Blocks until the server is stopped.
 //
 // Returns:
+//   - nil after the server is stopped by Shutdown.
 //   - An error if the server could not start or encountered an issue while running.
 func (s *Server) Run() error {
-	RegisterShortenerServer(s.server, s)
+	s.register()
+	if s.certs != nil {
+		go s.certs.Watch(s.reloadCtx, s.config.TLSReload.Duration, s.log)
+	}
 
-	listen, err := net.Listen("tcp", "localhost:3200")
+	listen, err := net.Listen("tcp", s.config.GRPCAddress)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("gRPC listening error: %w", err)
 	}
 
 	return s.server.Serve(listen) //nolint:wrapcheck // it returns error if the server could not start
 }
 
-// Shutdown gracefully stops the gRPC server.
+// register registers the services served by the gRPC server.
+func (s *Server) register() {
+	RegisterShortenerServer(s.server, s)
+	grpc_health_v1.RegisterHealthServer(s.server, &healthServer{service: s.service, interval: healthWatchInterval})
+}
+
+// Shutdown gracefully stops the gRPC server waiting for pending RPCs to finish.
+// If ctx is done before that, the server is stopped immediately.
 //
 // Returns:
-//   - An error if the server could not be stopped gracefully.
-func (s *Server) Shutdown() error {
-	s.server.GracefulStop()
-	return nil
+//   - An error if the server could not be stopped gracefully in time.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopReload()
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return fmt.Errorf("gRPC server shutdown error: %w", ctx.Err())
+	}
 }
 
-// decodeAndDecrypt takes an encrypted and encoded hex string and returns the decoded and decrypted string.
+// resolveUserID returns ID of the user the request is made on behalf of.
+// The user authenticated by authInterceptor from metadata takes precedence over the user token from the request.
+//
+// Returns:
+//   - The user ID, or an empty string if the user is neither authenticated nor provided a token.
+//   - A gRPC status error with InvalidArgument code for invalid token.
+func (s *Server) resolveUserID(ctx context.Context, token string) (string, error) {
+	if userID, ok := tokenutils.UserIDFromContext(ctx); ok {
+		return userID, nil
+	}
+
+	userID, err := s.decodeAndDecrypt(token)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, `invalid user_id`) //nolint:wrapcheck // it`s already wrapped
+	}
+	return userID, nil
+}
+
+// userToken issues the user token returned to the caller. Users authenticated by API key get an empty token,
+// otherwise the key could be traded for a token that outlives revocation of the key.
+func (s *Server) userToken(ctx context.Context, userID string) (string, error) {
+	if tokenutils.IsAPIKeyUser(ctx) {
+		return "", nil
+	}
+	token, err := s.tokens.Issue(userID)
+	if err != nil {
+		return "", status.Error(codes.Internal, err.Error()) //nolint:wrapcheck // it`s already wrapped
+	}
+	return token, nil
+}
+
+// isAuthenticated reports whether the user is authenticated from metadata, so user_id may be omitted.
+func isAuthenticated(ctx context.Context) bool {
+	_, ok := tokenutils.UserIDFromContext(ctx)
+	return ok
+}
+
+// decodeAndDecrypt verifies the user token issued by tokenutils and returns the user ID from it.
 //
 // Parameters:
-//   - userID: The encrypted and encoded user ID.
+//   - userID: The user token, the same as the value of the HTTP user cookie.
+//     Tokens issued with any configured key are accepted.
 //
 // Returns:
-//   - The decoded and decrypted user ID as a string.
-//   - An error if the decoding or decryption fails.
+//   - The user ID as a string, or an empty string if no token was provided.
+//   - An error if the token is invalid or expired.
 func (s *Server) decodeAndDecrypt(userID string) (string, error) {
 	if userID == "" {
 		return "", nil
 	}
-	decodedEncryptedUserID, err := hex.DecodeString(userID)
-	if err != nil {
-		return "", fmt.Errorf("decoding error: %w", err)
-	}
 
-	decryptedUserID, err := tokenutils.Decrypt(string(decodedEncryptedUserID), s.config.SecretKey)
+	decryptedUserID, _, err := s.tokens.Parse(userID)
 	if err != nil {
-		return "", fmt.Errorf("decryption error: %w", err)
+		return "", fmt.Errorf("user token error: %w", err)
 	}
 
 	return decryptedUserID, nil
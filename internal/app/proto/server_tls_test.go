@@ -0,0 +1,190 @@
+package pb
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/GTedya/shortener/config"
+	mock_service "github.com/GTedya/shortener/internal/app/mocks"
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// testCA is a self-signed certificate authority issuing test certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &testCA{cert: cert, key: key, pool: x509.NewCertPool(), dir: t.TempDir()}
+	ca.pool.AddCert(cert)
+	writePEM(t, ca.file("ca.pem"), "CERTIFICATE", der)
+	return ca
+}
+
+// file returns path of the file in the directory of the CA.
+func (ca *testCA) file(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+// issue creates a certificate for localhost with the usage and writes it to name.pem and name-key.pem.
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	writePEM(t, ca.file(name+".pem"), "CERTIFICATE", der)
+	writePEM(t, ca.file(name+"-key.pem"), "EC PRIVATE KEY", keyDER)
+
+	cert, err := tls.LoadX509KeyPair(ca.file(name+".pem"), ca.file(name+"-key.pem"))
+	require.NoError(t, err)
+	return cert
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+}
+
+func TestServer_TLS(t *testing.T) {
+	ca := newTestCA(t)
+	ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	clientCert := ca.issue(t, "client", x509.ExtKeyUsageClientAuth)
+
+	// ping makes a call that reaches the service.
+	ping := func(t *testing.T, conf config.Config, creds credentials.TransportCredentials) error {
+		t.Helper()
+
+		ctrl := gomock.NewController(t)
+		mockService := mock_service.NewMockShortenerInterface(ctrl)
+		mockService.EXPECT().Expand(gomock.Any(), "url1").Return(models.ShortURL{OriginalURL: "http://example.com"}, nil).AnyTimes()
+
+		s, err := NewGRPCServer(mockService, testKeyring(t, conf), conf, zap.S())
+		require.NoError(t, err)
+		conn, err := dialBufconn(listenTestServer(t, s), creds)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err = NewShortenerClient(conn).Expand(ctx, &ExpandRequest{UrlId: "url1"})
+		return err
+	}
+
+	tlsConf := config.Config{
+		SecretKey:   "0123456789abcdef",
+		EnableHTTPS: true,
+		TLSCertFile: ca.file("server.pem"),
+		TLSKeyFile:  ca.file("server-key.pem"),
+	}
+	mtlsConf := tlsConf
+	mtlsConf.GRPCClientCA = ca.file("ca.pem")
+
+	clientTLS := func(certs ...tls.Certificate) credentials.TransportCredentials {
+		return credentials.NewTLS(&tls.Config{
+			RootCAs:      ca.pool,
+			ServerName:   "localhost",
+			Certificates: certs,
+			MinVersion:   tls.VersionTLS12,
+		})
+	}
+
+	t.Run("TLS", func(t *testing.T) {
+		assert.NoError(t, ping(t, tlsConf, clientTLS()))
+	})
+
+	t.Run("plaintext client to TLS server", func(t *testing.T) {
+		assert.Error(t, ping(t, tlsConf, insecure.NewCredentials()))
+	})
+
+	t.Run("mutual TLS", func(t *testing.T) {
+		assert.NoError(t, ping(t, mtlsConf, clientTLS(clientCert)))
+	})
+
+	t.Run("mutual TLS without client certificate", func(t *testing.T) {
+		assert.Error(t, ping(t, mtlsConf, clientTLS()))
+	})
+
+	t.Run("mutual TLS with untrusted client certificate", func(t *testing.T) {
+		other := newTestCA(t)
+		assert.Error(t, ping(t, mtlsConf, clientTLS(other.issue(t, "client", x509.ExtKeyUsageClientAuth))))
+	})
+
+	t.Run("missing client CA", func(t *testing.T) {
+		conf := mtlsConf
+		conf.GRPCClientCA = ca.file("missing.pem")
+		_, err := NewGRPCServer(nil, testKeyring(t, conf), conf, zap.S())
+		assert.Error(t, err)
+	})
+
+	t.Run("ACME with certificate files", func(t *testing.T) {
+		conf := tlsConf
+		conf.ACMEDomains = "example.com"
+		assert.NoError(t, ping(t, conf, clientTLS()))
+	})
+
+	t.Run("ACME without certificate files", func(t *testing.T) {
+		conf := tlsConf
+		conf.ACMEDomains = "example.com"
+		conf.TLSCertFile = filepath.Join(t.TempDir(), "cert.pem")
+		conf.TLSKeyFile = filepath.Join(t.TempDir(), "key.pem")
+		_, err := NewGRPCServer(nil, testKeyring(t, conf), conf, zap.S())
+		require.ErrorIs(t, err, ErrACMECertificate)
+		// no self-signed certificate is generated
+		assert.NoFileExists(t, conf.TLSCertFile)
+	})
+}
@@ -3,10 +3,13 @@ package pb
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/GTedya/shortener/internal/app/idgen"
 	"github.com/GTedya/shortener/internal/app/models"
 	"github.com/GTedya/shortener/internal/app/repository"
 )
@@ -19,10 +22,15 @@ import (
 //
 // If the user ID is empty after decryption, a new user ID is generated.
 //
-// Calls the Shorten method on the service with the URL and user ID.
+// Calls the Shorten method on the service with the URL, optional alias and user ID.
 //
 // If a duplicate URL is detected, it returns a response with the existing short URL.
 //
+// If the alias is invalid, it returns an InvalidArgument error, if it is already taken, an AlreadyExists error.
+//
+// The link expires after ttl seconds or at expires_at unix time if one of them is set,
+// an InvalidArgument error is returned for invalid expiration.
+//
 // If an error occurs during the shortening process, it returns an Internal error.
 //
 // Parameters:
@@ -30,39 +38,81 @@ import (
 //   - r: The request containing the full URL and the user ID.
 //
 // Returns:
-//   - A response containing the shortened URL and the user ID.
+//   - A response containing the shortened URL and the user ID, which is empty for users authenticated by API key.
 //   - An error if the URL is invalid, the user ID is invalid, or the shortening process fails.
 func (s *Server) Shorten(ctx context.Context, r *ShortenRequest) (*ShorteningResponse, error) {
 	if r.Url == "" {
 		return nil, status.Error(codes.InvalidArgument, `full_url required`) //nolint:wrapcheck // it`s already wrapped
 	}
 
-	userID, err := s.decodeAndDecrypt(r.UserId)
+	expiresAt, err := expirationTime(r.GetTtl(), r.GetExpiresAt())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error()) //nolint:wrapcheck // it`s already wrapped
+	}
+
+	userID, err := s.resolveUserID(ctx, r.UserId)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, `invalid user_id`) //nolint:wrapcheck // it`s already wrapped
+		return nil, err
 	}
 
 	if userID == "" {
 		userID = s.service.GenerateNewUserID()
 	}
 
-	shortURL, err := s.service.Shorten(ctx, r.Url, userID)
+	shortURL, err := s.service.Shorten(ctx, models.ShortURL{
+		OriginalURL: r.Url,
+		ShortURL:    r.Alias,
+		CreatedByID: userID,
+		ExpiresAt:   expiresAt,
+	})
 	if errors.Is(err, repository.ErrDuplicate) {
 		// we cannot return "conflict" status with response, response becomes nil for client
 		return s.newShorteningResponse(shortURL, ""), nil
 	}
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error()) //nolint:wrapcheck // it`s already wrapped
+		return nil, aliasError(err)
 	}
 
-	return s.newShorteningResponse(shortURL, userID), nil
+	token, err := s.userToken(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newShorteningResponse(shortURL, token), nil
+}
+
+// aliasError converts service error to gRPC status, recognizing custom alias errors.
+func aliasError(err error) error {
+	switch {
+	case errors.Is(err, idgen.ErrInvalidAlias), errors.Is(err, idgen.ErrReservedAlias):
+		return status.Error(codes.InvalidArgument, err.Error()) //nolint:wrapcheck // it`s already wrapped
+	case errors.Is(err, repository.ErrIDTaken):
+		return status.Error(codes.AlreadyExists, err.Error()) //nolint:wrapcheck // it`s already wrapped
+	default:
+		return status.Error(codes.Internal, err.Error()) //nolint:wrapcheck // it`s already wrapped
+	}
+}
+
+// expirationTime converts ttl in seconds and expiresAt unix time from request to link expiration time.
+func expirationTime(ttl int64, expiresAt int64) (*time.Time, error) {
+	var at *time.Time
+	if expiresAt != 0 {
+		t := time.Unix(expiresAt, 0)
+		at = &t
+	}
+
+	expiration, err := models.ExpirationTime(time.Duration(ttl)*time.Second, at, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("expiration error: %w", err)
+	}
+	return expiration, nil
 }
 
 // newShorteningResponse creates a new ShorteningResponse with the provided short URL and user ID.
 //
 // Parameters:
 //   - shortURL: The short URL generated.
-//   - userID: The user ID associated with the shortened URL.
+//   - userID: The user token to use in the next requests, empty if it must not be returned.
 //
 // Returns:
 //   - A ShorteningResponse containing the formatted short URL, user ID, and URL ID.
@@ -2,11 +2,13 @@ package pb
 
 import (
 	"context"
+	"errors"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
 )
 
 // ShortenBatch processes a batch of URLs and shortens them for the given user.
@@ -15,53 +17,135 @@ import (
 //
 // If the user ID is empty after decryption, a new user ID is generated.
 //
-// Each URL in the batch is validated, and an InvalidArgument error is returned if any URL is empty.
+// Every item of the response has the correlation_id of the request item, the short URL, its id
+// and the user token to use in the next requests, the token is empty for users authenticated by API key. Errors are reported per item with code and error fields
+// instead of failing the whole batch: an empty URL, invalid expiration or invalid alias results
+// in InvalidArgument code, taken alias in AlreadyExists code. An already shortened URL results in
+// AlreadyExists code as well, but the item contains the existing short URL.
 //
-// Calls the ShortenBatch method on the service with the batch of URLs and user ID.
+// Items with alias are saved with it as short id. Items may also set ttl or expires_at.
 //
-// If an error occurs during the batch shortening process, it returns an Internal error.
+// Calls the ShortenBatch method on the service with the valid items. If the batch cannot be saved,
+// the items are shortened one by one to find the failed ones.
 //
 // Parameters:
 //   - ctx: The context for the request.
 //   - r: The request containing the batch of URLs and the user ID.
 //
 // Returns:
-//   - A response containing the batch of shortened URLs.
-//   - An error if any URL is invalid, the user ID is invalid, or the batch shortening process fails.
+//   - A response containing the result of every item in the order of the request.
+//   - An error if the user ID is invalid or the request is canceled.
 func (s *Server) ShortenBatch(ctx context.Context, r *ShortenBatchRequest) (*ShortenBatchResponse, error) {
-	userID, err := s.decodeAndDecrypt(r.GetUserId())
+	userID, err := s.resolveUserID(ctx, r.GetUserId())
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, `invalid user_id`) //nolint:wrapcheck // it`s already wrapped
+		return nil, err
 	}
 
 	if userID == "" {
 		userID = s.service.GenerateNewUserID()
 	}
 
-	batch := make([]models.ShortURL, len(r.GetUrls()))
+	token, err := s.userToken(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
 
-	for i, url := range r.GetUrls() {
-		if url.OriginalUrl == "" {
-			return nil, status.Error(codes.InvalidArgument, `full_url required`) //nolint:wrapcheck // it`s already wrapped
-		}
-		batch[i] = models.ShortURL{
-			OriginalURL: url.OriginalUrl,
+	res, err := s.shortenItems(ctx, r.GetUrls(), userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range res {
+		item.UserId = token
+	}
+
+	return &ShortenBatchResponse{
+		Urls: res,
+	}, nil
+}
+
+// shortenItems shortens the items for the user and returns the result of every item.
+// Returns an error only if ctx is done.
+func (s *Server) shortenItems(
+	ctx context.Context,
+	items []*ShortenBatchItemRequest,
+	userID string,
+) ([]*ShortenBatchItemResponse, error) {
+	res := make([]*ShortenBatchItemResponse, len(items))
+	batch := make([]models.ShortURL, 0, len(items))
+	indexes := make([]int, 0, len(items)) // index of the item for every batch entry
+
+	for i, item := range items {
+		res[i] = &ShortenBatchItemResponse{CorrelationId: item.GetCorrelationId()}
+
+		shortURL, err := batchItem(item)
+		if err != nil {
+			setItemError(res[i], err)
+			continue
 		}
+		batch = append(batch, shortURL)
+		indexes = append(indexes, i)
+	}
+	if len(batch) == 0 {
+		return res, nil
 	}
 
-	shortURLBatches, err := s.service.ShortenBatch(ctx, batch, userID)
-	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error()) //nolint:wrapcheck // it`s already wrapped
+	// the service fills the batch, so keep the original entries for the one by one fallback
+	entries := append([]models.ShortURL(nil), batch...)
+	saved, err := s.service.ShortenBatch(ctx, batch, userID)
+	if err == nil {
+		for j, i := range indexes {
+			s.setItemResult(res[i], saved[j])
+		}
+		return res, nil
 	}
 
-	res := make([]*ShortenBatchItemResponse, len(shortURLBatches))
-	for i, shortURLBatch := range shortURLBatches {
-		res[i] = &ShortenBatchItemResponse{
-			ResultUrl: s.service.FormatShortURL(shortURLBatch.ShortURL),
+	// the batch is saved at once, so find out which items failed
+	for j, i := range indexes {
+		if err = ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err() //nolint:wrapcheck // it`s already wrapped
+		}
+
+		shortURL := entries[j]
+		shortURL.CreatedByID = userID
+		saved, err := s.service.Shorten(ctx, shortURL)
+		switch {
+		case errors.Is(err, repository.ErrDuplicate):
+			s.setItemResult(res[i], saved)
+			setItemError(res[i], status.Error(codes.AlreadyExists, "url is already shortened"))
+		case err != nil:
+			setItemError(res[i], aliasError(err))
+		default:
+			s.setItemResult(res[i], saved)
 		}
 	}
+	return res, nil
+}
 
-	return &ShortenBatchResponse{
-		Urls: res,
+// batchItem validates the batch item and converts it to the entry to save.
+func batchItem(item *ShortenBatchItemRequest) (models.ShortURL, error) {
+	if item.GetOriginalUrl() == "" {
+		return models.ShortURL{}, status.Error(codes.InvalidArgument, `full_url required`) //nolint:wrapcheck // it`s already wrapped
+	}
+	expiresAt, err := expirationTime(item.GetTtl(), item.GetExpiresAt())
+	if err != nil {
+		return models.ShortURL{}, status.Error(codes.InvalidArgument, err.Error()) //nolint:wrapcheck // it`s already wrapped
+	}
+	return models.ShortURL{
+		OriginalURL: item.GetOriginalUrl(),
+		ShortURL:    item.GetAlias(),
+		ExpiresAt:   expiresAt,
 	}, nil
 }
+
+// setItemResult fills the item with the saved short URL.
+func (s *Server) setItemResult(item *ShortenBatchItemResponse, shortURL models.ShortURL) {
+	item.ResultUrl = s.service.FormatShortURL(shortURL.ShortURL)
+	item.UrlId = shortURL.ShortURL
+}
+
+// setItemError fills the item with the code and message of the status error.
+func setItemError(item *ShortenBatchItemResponse, err error) {
+	st := status.Convert(err)
+	item.Code = int32(st.Code())
+	item.Error = st.Message()
+}
@@ -2,16 +2,24 @@ package pb
 
 import (
 	"context"
+	"errors"
+	"io"
+	"strconv"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/GTedya/shortener/config"
 	mock_service "github.com/GTedya/shortener/internal/app/mocks"
 	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/app/service"
 )
 
 func TestServer_ShortenBatch(t *testing.T) {
@@ -22,6 +30,7 @@ func TestServer_ShortenBatch(t *testing.T) {
 	conf := config.Config{SecretKey: "0123456789abcdef"}
 	s := &Server{
 		service: mockService,
+		tokens:  testKeyring(t, conf),
 		config:  conf,
 	}
 
@@ -35,8 +44,8 @@ func TestServer_ShortenBatch(t *testing.T) {
 
 	t.Run("successful batch shorten with new user_id", func(t *testing.T) {
 		urls := []*ShortenBatchItemRequest{
-			{OriginalUrl: "http://example1.com"},
-			{OriginalUrl: "http://example2.com"},
+			{CorrelationId: "1", OriginalUrl: "http://example1.com"},
+			{CorrelationId: "2", OriginalUrl: "http://example2.com"},
 		}
 		req := &ShortenBatchRequest{
 			Urls: urls,
@@ -64,5 +73,173 @@ func TestServer_ShortenBatch(t *testing.T) {
 		assert.Len(t, resp.Urls, len(urls))
 		assert.Equal(t, "http://localhost:8080/short1", resp.Urls[0].ResultUrl)
 		assert.Equal(t, "http://localhost:8080/short2", resp.Urls[1].ResultUrl)
+		for i, item := range resp.Urls {
+			assert.Equal(t, urls[i].CorrelationId, item.CorrelationId)
+			assert.Equal(t, "short"+urls[i].CorrelationId, item.UrlId)
+			assert.Equal(t, int32(codes.OK), item.Code)
+			assert.Empty(t, item.Error)
+
+			userID, _, err := s.tokens.Parse(item.UserId)
+			assert.NoError(t, err)
+			assert.Equal(t, newUserID, userID)
+		}
+	})
+
+	t.Run("per item errors", func(t *testing.T) {
+		token, err := s.tokens.Issue("user1")
+		require.NoError(t, err)
+		req := &ShortenBatchRequest{
+			UserId: token,
+			Urls: []*ShortenBatchItemRequest{
+				{CorrelationId: "empty"},
+				{CorrelationId: "new", OriginalUrl: "http://new.com"},
+				{CorrelationId: "duplicate", OriginalUrl: "http://old.com"},
+				{CorrelationId: "taken", OriginalUrl: "http://alias.com", Alias: "taken"},
+				{CorrelationId: "expired", OriginalUrl: "http://expired.com", Ttl: -1},
+			},
+		}
+
+		batch := []models.ShortURL{
+			{OriginalURL: "http://new.com"},
+			{OriginalURL: "http://old.com"},
+			{OriginalURL: "http://alias.com", ShortURL: "taken"},
+		}
+		mockService.EXPECT().ShortenBatch(gomock.Any(), batch, "user1").
+			Return(nil, repository.ErrIDTaken).Times(1)
+		mockService.EXPECT().Shorten(gomock.Any(), models.ShortURL{OriginalURL: "http://new.com", CreatedByID: "user1"}).
+			Return(models.ShortURL{OriginalURL: "http://new.com", ShortURL: "new"}, nil).Times(1)
+		mockService.EXPECT().Shorten(gomock.Any(), models.ShortURL{OriginalURL: "http://old.com", CreatedByID: "user1"}).
+			Return(models.ShortURL{OriginalURL: "http://old.com", ShortURL: "old"},
+				service.NewShorteningError(models.ShortURL{}, repository.ErrDuplicate)).Times(1)
+		mockService.EXPECT().Shorten(gomock.Any(),
+			models.ShortURL{OriginalURL: "http://alias.com", ShortURL: "taken", CreatedByID: "user1"}).
+			Return(models.ShortURL{}, repository.ErrIDTaken).Times(1)
+		mockService.EXPECT().FormatShortURL("new").Return("http://localhost:8080/new").Times(1)
+		mockService.EXPECT().FormatShortURL("old").Return("http://localhost:8080/old").Times(1)
+
+		resp, err := s.ShortenBatch(context.Background(), req)
+		require.NoError(t, err)
+		require.Len(t, resp.Urls, len(req.Urls))
+
+		results := make(map[string]*ShortenBatchItemResponse)
+		for i, item := range resp.Urls {
+			assert.Equal(t, req.Urls[i].CorrelationId, item.CorrelationId)
+			assert.NotEmpty(t, item.UserId)
+			results[item.CorrelationId] = item
+		}
+
+		assert.Equal(t, int32(codes.InvalidArgument), results["empty"].Code)
+		assert.Equal(t, "full_url required", results["empty"].Error)
+		assert.Equal(t, int32(codes.OK), results["new"].Code)
+		assert.Equal(t, "http://localhost:8080/new", results["new"].ResultUrl)
+		assert.Equal(t, int32(codes.AlreadyExists), results["duplicate"].Code)
+		assert.Equal(t, "http://localhost:8080/old", results["duplicate"].ResultUrl)
+		assert.Equal(t, "old", results["duplicate"].UrlId)
+		assert.Equal(t, int32(codes.AlreadyExists), results["taken"].Code)
+		assert.Empty(t, results["taken"].UrlId)
+		assert.Equal(t, int32(codes.InvalidArgument), results["expired"].Code)
 	})
 }
+
+func TestServer_ShortenStream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mock_service.NewMockShortenerInterface(ctrl)
+	conf := config.Config{SecretKey: "0123456789abcdef"}
+	s, err := NewGRPCServer(mockService, testKeyring(t, conf), conf, zap.S())
+	require.NoError(t, err)
+	client := startTestServer(t, s)
+
+	t.Run("chunked load", func(t *testing.T) {
+		const total = streamChunkSize + 10
+
+		mockService.EXPECT().GenerateNewUserID().Return("user1").Times(1)
+		mockService.EXPECT().ShortenBatch(gomock.Any(), gomock.Any(), "user1").
+			DoAndReturn(func(_ context.Context, batch []models.ShortURL, _ string) ([]models.ShortURL, error) {
+				for i := range batch {
+					batch[i].ShortURL = "id" + batch[i].OriginalURL
+				}
+				return batch, nil
+			}).Times(2)
+		mockService.EXPECT().FormatShortURL(gomock.Any()).Return("http://localhost:8080/id").Times(total)
+
+		stream, err := client.ShortenStream(context.Background())
+		require.NoError(t, err)
+		for i := 0; i < total; i++ {
+			require.NoError(t, stream.Send(&ShortenBatchItemRequest{
+				CorrelationId: strconv.Itoa(i),
+				OriginalUrl:   strconv.Itoa(i),
+			}))
+		}
+		require.NoError(t, stream.Send(&ShortenBatchItemRequest{CorrelationId: "empty"}))
+		require.NoError(t, stream.CloseSend())
+
+		// a response is streamed per chunk
+		responses := recvAll(t, stream)
+		require.Len(t, responses, 2)
+		assert.Equal(t, int64(streamChunkSize), responses[0].Saved)
+		assert.Zero(t, responses[0].Failed)
+		require.Len(t, responses[0].Urls, streamChunkSize)
+		assert.Equal(t, "id5", responses[0].Urls[5].UrlId)
+
+		last := responses[1]
+		assert.Equal(t, int64(10), last.Saved)
+		assert.Equal(t, int64(1), last.Failed)
+		require.Len(t, last.Urls, 11)
+		assert.Equal(t, "empty", last.Urls[10].CorrelationId)
+		assert.Equal(t, int32(codes.InvalidArgument), last.Urls[10].Code)
+
+		for _, resp := range responses {
+			userID, _, err := s.tokens.Parse(resp.UserId)
+			require.NoError(t, err)
+			assert.Equal(t, "user1", userID)
+		}
+	})
+
+	t.Run("empty load", func(t *testing.T) {
+		mockService.EXPECT().GenerateNewUserID().Return("user2").Times(1)
+
+		stream, err := client.ShortenStream(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, stream.CloseSend())
+
+		responses := recvAll(t, stream)
+		require.Len(t, responses, 1)
+		assert.Empty(t, responses[0].Urls)
+		assert.NotEmpty(t, responses[0].UserId)
+	})
+
+	t.Run("user from metadata", func(t *testing.T) {
+		token, err := s.tokens.Issue("owner")
+		require.NoError(t, err)
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "user-id", token)
+		mockService.EXPECT().ShortenBatch(gomock.Any(), gomock.Any(), "owner").
+			Return([]models.ShortURL{{ShortURL: "id"}}, nil).Times(1)
+		mockService.EXPECT().FormatShortURL("id").Return("http://localhost:8080/id").Times(1)
+
+		stream, err := client.ShortenStream(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&ShortenBatchItemRequest{OriginalUrl: "http://example.com"}))
+		require.NoError(t, stream.CloseSend())
+
+		responses := recvAll(t, stream)
+		require.Len(t, responses, 1)
+		assert.Equal(t, int64(1), responses[0].Saved)
+	})
+}
+
+// recvAll receives responses of the stream until it is closed by the server.
+func recvAll(t *testing.T, stream Shortener_ShortenStreamClient) []*ShortenStreamResponse {
+	t.Helper()
+
+	var responses []*ShortenStreamResponse
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return responses
+		}
+		require.NoError(t, err)
+		responses = append(responses, resp)
+	}
+}
@@ -0,0 +1,89 @@
+package pb
+
+import (
+	"errors"
+	"io"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/GTedya/shortener/internal/app/tokenutils"
+)
+
+// streamChunkSize is the number of streamed items shortened at once.
+const streamChunkSize = 1000
+
+// ShortenStream shortens URLs streamed by the client, it is intended for bulk loads too large for ShortenBatch.
+//
+// The items are the same as ShortenBatch items and are processed in chunks of streamChunkSize.
+// A response is streamed back for every chunk as soon as it is shortened, so neither the server nor the client
+// keeps the whole load in memory. The client should read responses while it sends items,
+// otherwise the stream stalls once flow control windows are full.
+//
+// Stream messages don't contain the user, so the URLs are created by the user authenticated from metadata,
+// or by a new user if the client sent no credentials.
+//
+// Errors are reported per item like in ShortenBatch. Every response contains the user token once
+// instead of in every item, and the number of saved and failed items of the chunk.
+// If the client sent no items, a single response with the user token is sent.
+// Users authenticated by API key get an empty user token.
+//
+// Parameters:
+//   - stream: The stream of the items to shorten and of the responses.
+//
+// Returns:
+//   - An error if the stream is broken or canceled.
+func (s *Server) ShortenStream(stream Shortener_ShortenStreamServer) error {
+	ctx := stream.Context()
+
+	userID, ok := tokenutils.UserIDFromContext(ctx)
+	if !ok {
+		userID = s.service.GenerateNewUserID()
+	}
+
+	token, err := s.userToken(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	sent := false
+	chunk := make([]*ShortenBatchItemRequest, 0, streamChunkSize)
+	flush := func() error {
+		res, err := s.shortenItems(ctx, chunk, userID)
+		if err != nil {
+			return err
+		}
+
+		resp := &ShortenStreamResponse{Urls: res, UserId: token}
+		for _, item := range res {
+			if item.GetCode() == int32(codes.OK) {
+				resp.Saved++
+			} else {
+				resp.Failed++
+			}
+		}
+		chunk = chunk[:0]
+		sent = true
+		return stream.Send(resp) //nolint:wrapcheck // it`s a status error of the stream
+	}
+
+	for {
+		item, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err //nolint:wrapcheck // it`s a status error of the stream
+		}
+
+		chunk = append(chunk, item)
+		if len(chunk) == streamChunkSize {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(chunk) > 0 || !sent {
+		return flush()
+	}
+	return nil
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/GTedya/shortener/config"
 	mock_service "github.com/GTedya/shortener/internal/app/mocks"
 	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+	"github.com/GTedya/shortener/internal/app/tokenutils"
 )
 
 func TestServer_Shorten(t *testing.T) {
@@ -20,9 +22,10 @@ func TestServer_Shorten(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockService := mock_service.NewMockShortenerInterface(ctrl)
-	conf := config.Config{SecretKey: "0123456789abcdef0123456789abcdef"} // 32-байтовый ключ для AES-256
+	conf := config.Config{SecretKey: "0123456789abcdef0123456789abcdef"}
 	s := &Server{
 		service: mockService,
+		tokens:  testKeyring(t, conf),
 		config:  conf,
 	}
 
@@ -48,7 +51,10 @@ func TestServer_Shorten(t *testing.T) {
 		newUserID := "newUserID"
 
 		mockService.EXPECT().GenerateNewUserID().Return(newUserID).Times(1)
-		mockService.EXPECT().Shorten(gomock.Any(), originalURL, newUserID).Return(models.ShortURL{
+		mockService.EXPECT().Shorten(gomock.Any(), models.ShortURL{
+			OriginalURL: originalURL,
+			CreatedByID: newUserID,
+		}).Return(models.ShortURL{
 			OriginalURL: originalURL,
 			ShortURL:    shortID,
 		}, nil).Times(1)
@@ -59,7 +65,45 @@ func TestServer_Shorten(t *testing.T) {
 		assert.NotNil(t, resp)
 		assert.NoError(t, err)
 		assert.Equal(t, "http://localhost:8080/"+shortID, resp.ResultUrl)
-		assert.Equal(t, newUserID, resp.UserId)
+		userID, _, err := s.tokens.Parse(resp.UserId)
+		assert.NoError(t, err)
+		assert.Equal(t, newUserID, userID)
 		assert.Equal(t, shortID, resp.UrlId)
 	})
+
+	t.Run("invalid expiration", func(t *testing.T) {
+		req := &ShortenRequest{Url: "http://example.com", Ttl: 60, ExpiresAt: 1}
+		resp, err := s.Shorten(context.Background(), req)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("taken alias", func(t *testing.T) {
+		originalURL := "http://example.com"
+		newUserID := "newUserID"
+
+		mockService.EXPECT().GenerateNewUserID().Return(newUserID).Times(1)
+		mockService.EXPECT().Shorten(gomock.Any(), models.ShortURL{
+			OriginalURL: originalURL,
+			ShortURL:    "q3-launch",
+			CreatedByID: newUserID,
+		}).
+			Return(models.ShortURL{}, repository.ErrIDTaken).Times(1)
+
+		req := &ShortenRequest{Url: originalURL, Alias: "q3-launch"}
+		resp, err := s.Shorten(context.Background(), req)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+}
+
+// testKeyring создает ключи токенов пользователей из тестовой конфигурации.
+func testKeyring(t *testing.T, conf config.Config) *tokenutils.Keyring {
+	t.Helper()
+
+	tokens, err := tokenutils.NewKeyring(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
 }
@@ -26,6 +26,11 @@ type ShortenerClient interface {
 	DeleteUrls(ctx context.Context, in *DeleteUrlsRequest, opts ...grpc.CallOption) (*Empty, error)
 	Expand(ctx context.Context, in *ExpandRequest, opts ...grpc.CallOption) (*ExpandResponse, error)
 	ShortenBatch(ctx context.Context, in *ShortenBatchRequest, opts ...grpc.CallOption) (*ShortenBatchResponse, error)
+	// bulk load, the user is taken from metadata or a new one is created, a response is streamed per chunk of items
+	ShortenStream(ctx context.Context, opts ...grpc.CallOption) (Shortener_ShortenStreamClient, error)
+	UpdateUrl(ctx context.Context, in *UpdateUrlRequest, opts ...grpc.CallOption) (*Empty, error)
+	GetUserUrls(ctx context.Context, in *GetUserUrlsRequest, opts ...grpc.CallOption) (*GetUserUrlsResponse, error)
+	GetStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetStatsResponse, error)
 }
 
 type shortenerClient struct {
@@ -72,6 +77,64 @@ func (c *shortenerClient) ShortenBatch(ctx context.Context, in *ShortenBatchRequ
 	return out, nil
 }
 
+func (c *shortenerClient) ShortenStream(ctx context.Context, opts ...grpc.CallOption) (Shortener_ShortenStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Shortener_ServiceDesc.Streams[0], "/shortener.Shortener/ShortenStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &shortenerShortenStreamClient{stream}
+	return x, nil
+}
+
+type Shortener_ShortenStreamClient interface {
+	Send(*ShortenBatchItemRequest) error
+	Recv() (*ShortenStreamResponse, error)
+	grpc.ClientStream
+}
+
+type shortenerShortenStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *shortenerShortenStreamClient) Send(m *ShortenBatchItemRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *shortenerShortenStreamClient) Recv() (*ShortenStreamResponse, error) {
+	m := new(ShortenStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *shortenerClient) UpdateUrl(ctx context.Context, in *UpdateUrlRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/shortener.Shortener/UpdateUrl", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) GetUserUrls(ctx context.Context, in *GetUserUrlsRequest, opts ...grpc.CallOption) (*GetUserUrlsResponse, error) {
+	out := new(GetUserUrlsResponse)
+	err := c.cc.Invoke(ctx, "/shortener.Shortener/GetUserUrls", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) GetStats(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	out := new(GetStatsResponse)
+	err := c.cc.Invoke(ctx, "/shortener.Shortener/GetStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShortenerServer is the server API for Shortener service.
 // All implementations must embed UnimplementedShortenerServer
 // for forward compatibility
@@ -80,6 +143,11 @@ type ShortenerServer interface {
 	DeleteUrls(context.Context, *DeleteUrlsRequest) (*Empty, error)
 	Expand(context.Context, *ExpandRequest) (*ExpandResponse, error)
 	ShortenBatch(context.Context, *ShortenBatchRequest) (*ShortenBatchResponse, error)
+	// bulk load, the user is taken from metadata or a new one is created, a response is streamed per chunk of items
+	ShortenStream(Shortener_ShortenStreamServer) error
+	UpdateUrl(context.Context, *UpdateUrlRequest) (*Empty, error)
+	GetUserUrls(context.Context, *GetUserUrlsRequest) (*GetUserUrlsResponse, error)
+	GetStats(context.Context, *Empty) (*GetStatsResponse, error)
 	mustEmbedUnimplementedShortenerServer()
 }
 
@@ -99,6 +167,18 @@ func (UnimplementedShortenerServer) Expand(context.Context, *ExpandRequest) (*Ex
 func (UnimplementedShortenerServer) ShortenBatch(context.Context, *ShortenBatchRequest) (*ShortenBatchResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ShortenBatch not implemented")
 }
+func (UnimplementedShortenerServer) ShortenStream(Shortener_ShortenStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ShortenStream not implemented")
+}
+func (UnimplementedShortenerServer) UpdateUrl(context.Context, *UpdateUrlRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateUrl not implemented")
+}
+func (UnimplementedShortenerServer) GetUserUrls(context.Context, *GetUserUrlsRequest) (*GetUserUrlsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserUrls not implemented")
+}
+func (UnimplementedShortenerServer) GetStats(context.Context, *Empty) (*GetStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
 func (UnimplementedShortenerServer) mustEmbedUnimplementedShortenerServer() {}
 
 // UnsafeShortenerServer may be embedded to opt out of forward compatibility for this service.
@@ -184,6 +264,86 @@ func _Shortener_ShortenBatch_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Shortener_ShortenStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ShortenerServer).ShortenStream(&shortenerShortenStreamServer{stream})
+}
+
+type Shortener_ShortenStreamServer interface {
+	Send(*ShortenStreamResponse) error
+	Recv() (*ShortenBatchItemRequest, error)
+	grpc.ServerStream
+}
+
+type shortenerShortenStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *shortenerShortenStreamServer) Send(m *ShortenStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *shortenerShortenStreamServer) Recv() (*ShortenBatchItemRequest, error) {
+	m := new(ShortenBatchItemRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Shortener_UpdateUrl_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateUrlRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).UpdateUrl(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/shortener.Shortener/UpdateUrl",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).UpdateUrl(ctx, req.(*UpdateUrlRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetUserUrls_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserUrlsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetUserUrls(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/shortener.Shortener/GetUserUrls",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetUserUrls(ctx, req.(*GetUserUrlsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/shortener.Shortener/GetStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetStats(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Shortener_ServiceDesc is the grpc.ServiceDesc for Shortener service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -207,7 +367,26 @@ var Shortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ShortenBatch",
 			Handler:    _Shortener_ShortenBatch_Handler,
 		},
+		{
+			MethodName: "UpdateUrl",
+			Handler:    _Shortener_UpdateUrl_Handler,
+		},
+		{
+			MethodName: "GetUserUrls",
+			Handler:    _Shortener_GetUserUrls_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _Shortener_GetStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ShortenStream",
+			Handler:       _Shortener_ShortenStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "shortener.proto",
 }
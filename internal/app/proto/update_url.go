@@ -0,0 +1,58 @@
+package pb
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+// UpdateUrl changes the destination of the short URL owned by the given user.
+//
+// If the url_id or url in the request is empty, or the user_id is empty and the user is not authenticated from metadata,
+// it returns an InvalidArgument error.
+//
+// If the user_id cannot be decoded and decrypted, it returns an InvalidArgument error.
+//
+// Ownership is checked the same way as in DeleteUrls: if the URL doesn't exist, is deleted
+// or was created by another user, it returns a NotFound error.
+//
+// If the new URL is already shortened, it returns an AlreadyExists error.
+//
+// Parameters:
+//   - ctx: The context for the request.
+//   - r: The request containing the user ID, the URL ID and the new destination.
+//
+// Returns:
+//   - An empty message if successful.
+//   - An error if the request is invalid or the URL cannot be updated.
+func (s *Server) UpdateUrl(ctx context.Context, r *UpdateUrlRequest) (*Empty, error) {
+	if (r.GetUserId() == "" && !isAuthenticated(ctx)) || r.GetUrlId() == "" || r.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, `user_id, url_id and url required`) //nolint:wrapcheck // it`s already wrapped
+	}
+
+	userID, err := s.resolveUserID(ctx, r.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.service.UpdateURL(ctx, models.ShortURL{
+		OriginalURL: r.GetUrl(),
+		ShortURL:    r.GetUrlId(),
+		CreatedByID: userID,
+	})
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return nil, status.Error(codes.NotFound, "url id is not found") //nolint:wrapcheck // it`s already wrapped
+	case errors.Is(err, repository.ErrDuplicate):
+		return nil, status.Error(codes.AlreadyExists, "url is already shortened") //nolint:wrapcheck // it`s already wrapped
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error()) //nolint:wrapcheck // it`s already wrapped
+	}
+
+	return &Empty{}, nil
+}
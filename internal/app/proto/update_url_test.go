@@ -0,0 +1,84 @@
+package pb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/GTedya/shortener/config"
+	mock_service "github.com/GTedya/shortener/internal/app/mocks"
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+func TestServer_UpdateUrl(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mock_service.NewMockShortenerInterface(ctrl)
+	conf := config.Config{SecretKey: "0123456789abcdef"}
+	s := &Server{
+		service: mockService,
+		tokens:  testKeyring(t, conf),
+		config:  conf,
+	}
+
+	userID := "validUserId"
+	encryptedUserID, err := s.tokens.Issue(userID)
+	if err != nil {
+		t.Fatalf("Failed to encrypt user ID: %v", err)
+	}
+
+	t.Run("missing fields", func(t *testing.T) {
+		resp, err := s.UpdateUrl(context.Background(), &UpdateUrlRequest{UserId: encryptedUserID, UrlId: "abc"})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("invalid user_id", func(t *testing.T) {
+		resp, err := s.UpdateUrl(context.Background(), &UpdateUrlRequest{
+			UserId: "invalidUserId",
+			UrlId:  "abc",
+			Url:    "https://example.com",
+		})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, "invalid user_id", status.Convert(err).Message())
+	})
+
+	tests := []struct {
+		name       string
+		serviceErr error
+		code       codes.Code
+	}{
+		{name: "successful update", code: codes.OK},
+		{name: "not owner", serviceErr: fmt.Errorf("wrapped: %w", repository.ErrNotFound), code: codes.NotFound},
+		{name: "duplicate url", serviceErr: repository.ErrDuplicate, code: codes.AlreadyExists},
+		{name: "storage error", serviceErr: fmt.Errorf("connection lost"), code: codes.Internal},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockService.EXPECT().UpdateURL(gomock.Any(), models.ShortURL{
+				OriginalURL: "https://example.com",
+				ShortURL:    "abc",
+				CreatedByID: userID,
+			}).Return(test.serviceErr).Times(1)
+
+			resp, err := s.UpdateUrl(context.Background(), &UpdateUrlRequest{
+				UserId: encryptedUserID,
+				UrlId:  "abc",
+				Url:    "https://example.com",
+			})
+			assert.Equal(t, test.code, status.Code(err))
+			if test.code == codes.OK {
+				assert.NotNil(t, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,798 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// boltOpenTimeout is how long NewBoltRepository waits for the database locked by another process.
+const boltOpenTimeout = time.Second
+
+// Buckets of the bolt database.
+var (
+	urlsBucket      = []byte("urls")           // urls by short id
+	urlsByURLBucket = []byte("urls_by_url")    // short id by original url, keeps original urls unique
+	urlsByUser      = []byte("urls_by_user")   // empty values by user id, creation time and short id, see userURLKey
+	expiresBucket   = []byte("urls_expires")   // empty values by expiration time and short id
+	deletedBucket   = []byte("urls_deleted")   // empty values by deletion time and short id of deleted urls
+	usersBucket     = []byte("users")          // number of urls by user prefix, see userPrefix
+	revisionsBucket = []byte("url_revisions")  // revisions by short id and sequence number
+	clicksBucket    = []byte("clicks")         // clicks by short id and sequence number
+	apiKeysBucket   = []byte("api_keys")       // api keys by key id
+	apiKeyHashes    = []byte("api_key_hashes") // key id by key hash
+)
+
+// boltBuckets are all buckets created by NewBoltRepository.
+var boltBuckets = [][]byte{
+	urlsBucket, urlsByURLBucket, urlsByUser, expiresBucket, deletedBucket, usersBucket,
+	revisionsBucket, clicksBucket, apiKeysBucket, apiKeyHashes,
+}
+
+// ErrBoltBucket is returned by BoltRepository.Check if the database misses a bucket.
+var ErrBoltBucket = errors.New("bolt bucket is missing")
+
+// BoltRepository is repository that uses embedded bolt database for storage.
+// It has the same semantics as PostgresRepo: short ids and original urls are unique, deleted urls
+// keep their original urls until they are purged, revisions are removed together with their urls.
+// Every url is indexed by its owner and creation time, so pages of user urls are read without scanning all urls.
+type BoltRepository struct {
+	db *bolt.DB // bolt database, safe for concurrent use
+}
+
+// NewBoltRepository opens bolt database at path, creates it and its buckets if they don't exist.
+func NewBoltRepository(path string) (*BoltRepository, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout}) //nolint:gomnd // permission
+	if err != nil {
+		return nil, fmt.Errorf("bolt opening error: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range boltBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("bucket %s creating error: %w", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+
+	return &BoltRepository{db: db}, nil
+}
+
+// timeKey encodes time so that byte order of the keys is the order of times: seconds with the sign bit flipped
+// and nanoseconds, so all times are encoded unlike Unix nanoseconds, which overflow after 2262.
+func timeKey(t time.Time) []byte {
+	key := binary.BigEndian.AppendUint64(nil, uint64(t.Unix())^(1<<63)) //nolint:gosec // sign bit is flipped on purpose
+	return binary.BigEndian.AppendUint32(key, uint32(t.Nanosecond()))   //nolint:gosec // nanoseconds fit uint32
+}
+
+// timeIDKey is the key of url with given id in time indexes.
+func timeIDKey(t time.Time, id string) []byte {
+	return append(timeKey(t), id...)
+}
+
+// userPrefix is the prefix of keys of urls created by the user in urlsByUser.
+// User ids are UUIDs, so they can't contain the separator.
+func userPrefix(userID string) []byte {
+	return append([]byte(userID), 0)
+}
+
+// userURLKey is the key of the url in urlsByUser: user id, creation time and short id,
+// so urls of the user are ordered by creation time and short id like Postgres orders them.
+func userURLKey(userID string, createdAt time.Time, id string) []byte {
+	return append(userPrefix(userID), timeIDKey(createdAt, id)...)
+}
+
+// sequenceKey is the key of a revision or a click of the url with given id.
+func sequenceKey(id string, sequence uint64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte(id), 0), sequence)
+}
+
+// getURL returns the url with given id.
+func getURL(tx *bolt.Tx, id string) (models.ShortURL, bool, error) {
+	data := tx.Bucket(urlsBucket).Get([]byte(id))
+	if data == nil {
+		return models.ShortURL{}, false, nil
+	}
+	var url models.ShortURL
+	if err := json.Unmarshal(data, &url); err != nil {
+		return models.ShortURL{}, false, ErrDecoding
+	}
+	return url, true, nil
+}
+
+// putURL saves the new url and its indexes. Returns ErrIDTaken or ErrDuplicate if the short id
+// or the original url is already used.
+func putURL(tx *bolt.Tx, url models.ShortURL) error {
+	if tx.Bucket(urlsBucket).Get([]byte(url.ShortURL)) != nil {
+		return ErrIDTaken
+	}
+	if tx.Bucket(urlsByURLBucket).Get([]byte(url.OriginalURL)) != nil {
+		return ErrDuplicate
+	}
+	if err := tx.Bucket(urlsByURLBucket).Put([]byte(url.OriginalURL), []byte(url.ShortURL)); err != nil {
+		return fmt.Errorf("url index error: %w", err)
+	}
+	if err := tx.Bucket(urlsByUser).Put(userURLKey(url.CreatedByID, url.CreatedAt, url.ShortURL), nil); err != nil {
+		return fmt.Errorf("user index error: %w", err)
+	}
+	if err := addUserURLs(tx, url.CreatedByID, 1); err != nil {
+		return err
+	}
+	return writeURL(tx, models.ShortURL{}, url)
+}
+
+// writeURL saves the changed url and updates its expiration and deletion indexes.
+func writeURL(tx *bolt.Tx, previous, url models.ShortURL) error {
+	if previous.ExpiresAt != nil {
+		if err := tx.Bucket(expiresBucket).Delete(timeIDKey(*previous.ExpiresAt, previous.ShortURL)); err != nil {
+			return fmt.Errorf("expiration index error: %w", err)
+		}
+	}
+	if url.ExpiresAt != nil {
+		if err := tx.Bucket(expiresBucket).Put(timeIDKey(*url.ExpiresAt, url.ShortURL), nil); err != nil {
+			return fmt.Errorf("expiration index error: %w", err)
+		}
+	}
+	if previous.IsDeleted && previous.DeletedAt != nil {
+		if err := tx.Bucket(deletedBucket).Delete(timeIDKey(*previous.DeletedAt, previous.ShortURL)); err != nil {
+			return fmt.Errorf("deletion index error: %w", err)
+		}
+	}
+	if url.IsDeleted && url.DeletedAt != nil {
+		if err := tx.Bucket(deletedBucket).Put(timeIDKey(*url.DeletedAt, url.ShortURL), nil); err != nil {
+			return fmt.Errorf("deletion index error: %w", err)
+		}
+	}
+
+	data, err := json.Marshal(url)
+	if err != nil {
+		return fmt.Errorf("marshalling error: %w", err)
+	}
+	if err = tx.Bucket(urlsBucket).Put([]byte(url.ShortURL), data); err != nil {
+		return fmt.Errorf("url writing error: %w", err)
+	}
+	return nil
+}
+
+// removeURL permanently removes the url, its indexes, revisions and clicks.
+func removeURL(tx *bolt.Tx, url models.ShortURL) error {
+	// clearing expiration and deletion removes the url from their indexes
+	cleared := url
+	cleared.ExpiresAt, cleared.IsDeleted, cleared.DeletedAt = nil, false, nil
+	if err := writeURL(tx, url, cleared); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(urlsBucket).Delete([]byte(url.ShortURL)); err != nil {
+		return fmt.Errorf("url removing error: %w", err)
+	}
+	if err := tx.Bucket(urlsByURLBucket).Delete([]byte(url.OriginalURL)); err != nil {
+		return fmt.Errorf("url index error: %w", err)
+	}
+	if err := tx.Bucket(urlsByUser).Delete(userURLKey(url.CreatedByID, url.CreatedAt, url.ShortURL)); err != nil {
+		return fmt.Errorf("user index error: %w", err)
+	}
+	if err := addUserURLs(tx, url.CreatedByID, -1); err != nil {
+		return err
+	}
+	if err := deletePrefix(tx.Bucket(revisionsBucket), append([]byte(url.ShortURL), 0)); err != nil {
+		return err
+	}
+	return deletePrefix(tx.Bucket(clicksBucket), append([]byte(url.ShortURL), 0))
+}
+
+// addUserURLs changes number of urls created by the user, users without urls are removed.
+// Users are keyed by userPrefix, so urls without user are counted like Postgres counts them.
+func addUserURLs(tx *bolt.Tx, userID string, delta int64) error {
+	bucket, key := tx.Bucket(usersBucket), userPrefix(userID)
+	var count int64
+	if data := bucket.Get(key); data != nil {
+		count = int64(binary.BigEndian.Uint64(data)) //nolint:gosec // count is never negative
+	}
+
+	count += delta
+	if count <= 0 {
+		if err := bucket.Delete(key); err != nil {
+			return fmt.Errorf("users updating error: %w", err)
+		}
+		return nil
+	}
+	if err := bucket.Put(key, binary.BigEndian.AppendUint64(nil, uint64(count))); err != nil {
+		return fmt.Errorf("users updating error: %w", err)
+	}
+	return nil
+}
+
+// deletePrefix deletes all keys of the bucket with the prefix.
+func deletePrefix(bucket *bolt.Bucket, prefix []byte) error {
+	var keys [][]byte
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, bytes.Clone(k))
+	}
+	for _, key := range keys {
+		if err := bucket.Delete(key); err != nil {
+			return fmt.Errorf("key deleting error: %w", err)
+		}
+	}
+	return nil
+}
+
+// idsBefore returns short ids from the time index with times before t, or not after t if inclusive is set.
+func idsBefore(bucket *bolt.Bucket, t time.Time, inclusive bool) []string {
+	limit := timeKey(t)
+	var ids []string
+	c := bucket.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		cmp := bytes.Compare(k[:len(limit)], limit)
+		if cmp > 0 || cmp == 0 && !inclusive {
+			break
+		}
+		ids = append(ids, string(k[len(limit):]))
+	}
+	return ids
+}
+
+// Save saves the url. Returns ErrIDTaken if the short id is used and ErrDuplicate if the original url is shortened.
+func (repo *BoltRepository) Save(_ context.Context, shortURL models.ShortURL) error {
+	return repo.update(func(tx *bolt.Tx) error {
+		return putURL(tx, withCreatedAt(shortURL))
+	})
+}
+
+// SaveBatch saves the urls in a single transaction, so either all or none of them are saved.
+func (repo *BoltRepository) SaveBatch(_ context.Context, batch []models.ShortURL) error {
+	return repo.update(func(tx *bolt.Tx) error {
+		for _, shortURL := range batch {
+			if err := putURL(tx, withCreatedAt(shortURL)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetByID gets url by id.
+func (repo *BoltRepository) GetByID(_ context.Context, id string) (models.ShortURL, error) {
+	var url models.ShortURL
+	err := repo.view(func(tx *bolt.Tx) error {
+		found, ok, err := getURL(tx, id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("can't find full url by id: %w", ErrNotFound)
+		}
+		url = found
+		return nil
+	})
+	return url, err
+}
+
+// ShortenByURL gets url by original url.
+func (repo *BoltRepository) ShortenByURL(_ context.Context, originalURL string) (models.ShortURL, error) {
+	var url models.ShortURL
+	err := repo.view(func(tx *bolt.Tx) error {
+		id := tx.Bucket(urlsByURLBucket).Get([]byte(originalURL))
+		if id == nil {
+			return fmt.Errorf("can't find shortened URL by original URL: %w", ErrNotFound)
+		}
+		found, ok, err := getURL(tx, string(id))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("can't find shortened URL by original URL: %w", ErrNotFound)
+		}
+		url = found
+		return nil
+	})
+	return url, err
+}
+
+// GetUsersUrls returns the page of urls created by a user.
+// Urls are read from the user index starting at the cursor, so only the page and filtered out urls are read.
+func (repo *BoltRepository) GetUsersUrls(
+	_ context.Context,
+	userID string,
+	query models.URLQuery,
+) ([]models.ShortURL, error) {
+	var URLs []models.ShortURL
+	err := repo.view(func(tx *bolt.Tx) error {
+		prefix := userPrefix(userID)
+		c := tx.Bucket(urlsByUser).Cursor()
+		k, step := userURLsStart(c, userID, query)
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, _ = step() {
+			id := string(k[len(prefix)+len(timeKey(time.Time{})):])
+			url, ok, err := getURL(tx, id)
+			if err != nil {
+				return err
+			}
+			if !ok || !query.Matches(url) {
+				continue
+			}
+			URLs = append(URLs, url)
+			if query.Limit > 0 && len(URLs) == query.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return URLs, nil
+}
+
+// userURLsStart positions the cursor at the first url of the page in the order of the query.
+// Returns its key and the function moving the cursor to the next url.
+func userURLsStart(c *bolt.Cursor, userID string, query models.URLQuery) ([]byte, func() ([]byte, []byte)) {
+	if !query.Desc {
+		if query.After == nil {
+			k, _ := c.Seek(userPrefix(userID))
+			return k, c.Next
+		}
+		after := userURLKey(userID, query.After.CreatedAt, query.After.ID)
+		k, _ := c.Seek(after)
+		if bytes.Equal(k, after) {
+			k, _ = c.Next()
+		}
+		return k, c.Next
+	}
+
+	// the last key before the cursor or before keys of the next user
+	before := append([]byte(userID), 1)
+	if query.After != nil {
+		before = userURLKey(userID, query.After.CreatedAt, query.After.ID)
+	}
+	k, _ := c.Seek(before)
+	if k == nil {
+		k, _ = c.Last()
+	} else {
+		k, _ = c.Prev()
+	}
+	return k, c.Prev
+}
+
+// GetUrls returns up to limit urls, including deleted ones, with short ids after afterID ordered by short id.
+// Limit 0 means no limit.
+// Urls are stored by short id, so they are read from afterID without sorting.
+func (repo *BoltRepository) GetUrls(_ context.Context, afterID string, limit int) ([]models.ShortURL, error) {
+	var URLs []models.ShortURL
+	err := repo.view(func(tx *bolt.Tx) error {
+		c := tx.Bucket(urlsBucket).Cursor()
+		k, v := c.Seek([]byte(afterID))
+		if k != nil && string(k) == afterID {
+			k, v = c.Next()
+		}
+		for ; k != nil && (limit <= 0 || len(URLs) < limit); k, v = c.Next() {
+			var url models.ShortURL
+			if err := json.Unmarshal(v, &url); err != nil {
+				return ErrDecoding
+			}
+			URLs = append(URLs, url)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return URLs, nil
+}
+
+// Close closes the database.
+func (repo *BoltRepository) Close(_ context.Context) error {
+	if err := repo.db.Close(); err != nil {
+		return fmt.Errorf("bolt closing error: %w", err)
+	}
+	return nil
+}
+
+// Check checks if the database is open and has all buckets.
+func (repo *BoltRepository) Check(_ context.Context) error {
+	return repo.view(func(tx *bolt.Tx) error {
+		for _, name := range boltBuckets {
+			if tx.Bucket(name) == nil {
+				return fmt.Errorf("%w: %s", ErrBoltBucket, name)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteUrls marks urls owned by CreatedByID as deleted and remembers time of deletion.
+func (repo *BoltRepository) DeleteUrls(_ context.Context, urls []models.ShortURL) error {
+	now := time.Now()
+	return repo.update(func(tx *bolt.Tx) error {
+		for _, urlToDelete := range urls {
+			url, ok, err := getURL(tx, urlToDelete.ShortURL)
+			if err != nil {
+				return err
+			}
+			if !ok || url.CreatedByID != urlToDelete.CreatedByID || url.IsDeleted {
+				continue
+			}
+
+			deleted := url
+			deleted.IsDeleted = true
+			deleted.DeletedAt = &now
+			if err = writeURL(tx, url, deleted); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetUsersAndUrlsCount returns number of users that created urls and number of urls, deleted urls are counted
+// until they are purged.
+func (repo *BoltRepository) GetUsersAndUrlsCount(_ context.Context) (int, int, error) {
+	var users, urls int
+	err := repo.view(func(tx *bolt.Tx) error {
+		users = tx.Bucket(usersBucket).Stats().KeyN
+		urls = tx.Bucket(urlsBucket).Stats().KeyN
+		return nil
+	})
+	return users, urls, err
+}
+
+// DeleteExpired permanently removes urls that have expired by now together with their revisions and clicks.
+// Returns number of removed urls.
+func (repo *BoltRepository) DeleteExpired(_ context.Context, now time.Time) (int, error) {
+	return repo.removeBefore(expiresBucket, now, true)
+}
+
+// DeleteExpiredURLs permanently removes the urls with given ids that have expired by now together with their
+// revisions and clicks. Other urls are kept. Returns number of removed urls.
+func (repo *BoltRepository) DeleteExpiredURLs(_ context.Context, ids []string, now time.Time) (int, error) {
+	removed := 0
+	err := repo.update(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			url, ok, err := getURL(tx, id)
+			if err != nil {
+				return err
+			}
+			if !ok || !url.IsExpired(now) {
+				continue
+			}
+			if err = removeURL(tx, url); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// PurgeDeleted permanently removes urls deleted before deletedBefore together with their revisions and clicks.
+// Returns number of removed urls.
+func (repo *BoltRepository) PurgeDeleted(_ context.Context, deletedBefore time.Time) (int, error) {
+	return repo.removeBefore(deletedBucket, deletedBefore, false)
+}
+
+// removeBefore permanently removes urls from the time index with times before t,
+// or not after t if inclusive is set.
+func (repo *BoltRepository) removeBefore(index []byte, t time.Time, inclusive bool) (int, error) {
+	removed := 0
+	err := repo.update(func(tx *bolt.Tx) error {
+		for _, id := range idsBefore(tx.Bucket(index), t, inclusive) {
+			url, ok, err := getURL(tx, id)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if err = removeURL(tx, url); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// SaveClicks saves clicks in a single transaction. Clicks of urls removed before they are saved are dropped.
+func (repo *BoltRepository) SaveClicks(_ context.Context, clicks []models.Click) error {
+	return repo.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(clicksBucket)
+		for _, click := range clicks {
+			if tx.Bucket(urlsBucket).Get([]byte(click.ShortURL)) == nil {
+				continue
+			}
+			data, err := json.Marshal(click)
+			if err != nil {
+				return fmt.Errorf("marshalling error: %w", err)
+			}
+			sequence, err := bucket.NextSequence()
+			if err != nil {
+				return fmt.Errorf("sequence error: %w", err)
+			}
+			if err = bucket.Put(sequenceKey(click.ShortURL, sequence), data); err != nil {
+				return fmt.Errorf("click writing error: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetClickStats returns click analytics of the url with given id.
+func (repo *BoltRepository) GetClickStats(ctx context.Context, id string) (models.LinkStats, error) {
+	clicks, err := repo.GetClicks(ctx, id)
+	if err != nil {
+		return models.LinkStats{}, err
+	}
+	return aggregateClicks(clicks), nil
+}
+
+// GetClicks returns clicks of the url with given id in order they were saved.
+func (repo *BoltRepository) GetClicks(_ context.Context, id string) ([]models.Click, error) {
+	clicks := make([]models.Click, 0)
+	err := repo.view(func(tx *bolt.Tx) error {
+		prefix := append([]byte(id), 0)
+		c := tx.Bucket(clicksBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var click models.Click
+			if err := json.Unmarshal(v, &click); err != nil {
+				return ErrDecoding
+			}
+			clicks = append(clicks, click)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return clicks, nil
+}
+
+// UpdateURL changes destination of the url owned by shortURL.CreatedByID.
+// The previous destination is saved to revisions in the same transaction.
+func (repo *BoltRepository) UpdateURL(_ context.Context, shortURL models.ShortURL, changedAt time.Time) error {
+	return repo.update(func(tx *bolt.Tx) error {
+		url, ok, err := getURL(tx, shortURL.ShortURL)
+		if err != nil {
+			return err
+		}
+		if !ok || url.IsDeleted || url.CreatedByID != shortURL.CreatedByID {
+			return ErrNotFound
+		}
+		if url.OriginalURL == shortURL.OriginalURL {
+			return nil
+		}
+
+		byURL := tx.Bucket(urlsByURLBucket)
+		if byURL.Get([]byte(shortURL.OriginalURL)) != nil {
+			return ErrDuplicate
+		}
+		if err = byURL.Delete([]byte(url.OriginalURL)); err != nil {
+			return fmt.Errorf("url index error: %w", err)
+		}
+		if err = byURL.Put([]byte(shortURL.OriginalURL), []byte(url.ShortURL)); err != nil {
+			return fmt.Errorf("url index error: %w", err)
+		}
+
+		if err = putRevision(tx, models.Revision{
+			ShortURL:    url.ShortURL,
+			OriginalURL: url.OriginalURL,
+			ChangedAt:   changedAt,
+		}); err != nil {
+			return err
+		}
+
+		changed := url
+		changed.OriginalURL = shortURL.OriginalURL
+		return writeURL(tx, url, changed)
+	})
+}
+
+// putRevision saves the revision after previous revisions of its url.
+func putRevision(tx *bolt.Tx, revision models.Revision) error {
+	bucket := tx.Bucket(revisionsBucket)
+	data, err := json.Marshal(revision)
+	if err != nil {
+		return fmt.Errorf("marshalling error: %w", err)
+	}
+	sequence, err := bucket.NextSequence()
+	if err != nil {
+		return fmt.Errorf("sequence error: %w", err)
+	}
+	if err = bucket.Put(sequenceKey(revision.ShortURL, sequence), data); err != nil {
+		return fmt.Errorf("revision writing error: %w", err)
+	}
+	return nil
+}
+
+// SaveRevisions saves revisions in a single transaction. Revisions of urls removed before they are saved are dropped.
+func (repo *BoltRepository) SaveRevisions(_ context.Context, revisions []models.Revision) error {
+	return repo.update(func(tx *bolt.Tx) error {
+		for _, revision := range revisions {
+			if tx.Bucket(urlsBucket).Get([]byte(revision.ShortURL)) == nil {
+				continue
+			}
+			if err := putRevision(tx, revision); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetRevisions returns previous destinations of the url with given id, oldest first.
+func (repo *BoltRepository) GetRevisions(_ context.Context, id string) ([]models.Revision, error) {
+	revisions := make([]models.Revision, 0)
+	err := repo.view(func(tx *bolt.Tx) error {
+		prefix := append([]byte(id), 0)
+		c := tx.Bucket(revisionsBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var revision models.Revision
+			if err := json.Unmarshal(v, &revision); err != nil {
+				return ErrDecoding
+			}
+			revisions = append(revisions, revision)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// RestoreUrls undeletes given urls owned by CreatedByID that were deleted not earlier than deletedAfter.
+// Returns ids of restored urls.
+func (repo *BoltRepository) RestoreUrls(
+	_ context.Context,
+	urls []models.ShortURL,
+	deletedAfter time.Time,
+) ([]string, error) {
+	restored := make([]string, 0, len(urls))
+	err := repo.update(func(tx *bolt.Tx) error {
+		for _, urlToRestore := range urls {
+			url, ok, err := getURL(tx, urlToRestore.ShortURL)
+			if err != nil {
+				return err
+			}
+			if !ok || url.CreatedByID != urlToRestore.CreatedByID || !isRestorable(url, deletedAfter) {
+				continue
+			}
+
+			undeleted := url
+			undeleted.IsDeleted = false
+			undeleted.DeletedAt = nil
+			if err = writeURL(tx, url, undeleted); err != nil {
+				return err
+			}
+			restored = append(restored, url.ShortURL)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// SaveAPIKey saves api key. Returns ErrIDTaken if the key id or hash is already used.
+func (repo *BoltRepository) SaveAPIKey(_ context.Context, key models.APIKey) error {
+	return repo.update(func(tx *bolt.Tx) error {
+		keys, hashes := tx.Bucket(apiKeysBucket), tx.Bucket(apiKeyHashes)
+		if keys.Get([]byte(key.ID)) != nil || hashes.Get([]byte(key.Hash)) != nil {
+			return ErrIDTaken
+		}
+		if err := hashes.Put([]byte(key.Hash), []byte(key.ID)); err != nil {
+			return fmt.Errorf("api key index error: %w", err)
+		}
+		return putAPIKey(tx, key)
+	})
+}
+
+// GetAPIKey returns active api key with given hash.
+func (repo *BoltRepository) GetAPIKey(_ context.Context, hash string) (models.APIKey, error) {
+	var key models.APIKey
+	err := repo.view(func(tx *bolt.Tx) error {
+		found, err := getAPIKey(tx, tx.Bucket(apiKeyHashes).Get([]byte(hash)))
+		if err != nil {
+			return err
+		}
+		if found.RevokedAt != nil {
+			return fmt.Errorf("can't find api key: %w", ErrNotFound)
+		}
+		key = found
+		return nil
+	})
+	return key, err
+}
+
+// RevokeAPIKey revokes active api key with key.ID owned by key.UserID.
+// Returns ErrNotFound for missing, revoked or someone else's keys.
+func (repo *BoltRepository) RevokeAPIKey(_ context.Context, key models.APIKey, revokedAt time.Time) error {
+	return repo.update(func(tx *bolt.Tx) error {
+		found, err := getAPIKey(tx, []byte(key.ID))
+		if err != nil {
+			return err
+		}
+		if found.UserID != key.UserID || found.RevokedAt != nil {
+			return ErrNotFound
+		}
+		found.RevokedAt = &revokedAt
+		return putAPIKey(tx, found)
+	})
+}
+
+// GetAPIKeys returns all api keys, including revoked ones, ordered by id.
+func (repo *BoltRepository) GetAPIKeys(_ context.Context) ([]models.APIKey, error) {
+	keys := make([]models.APIKey, 0)
+	err := repo.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(apiKeysBucket).ForEach(func(_, v []byte) error {
+			var key models.APIKey
+			if err := json.Unmarshal(v, &key); err != nil {
+				return ErrDecoding
+			}
+			keys = append(keys, key)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// getAPIKey returns api key with given id.
+func getAPIKey(tx *bolt.Tx, id []byte) (models.APIKey, error) {
+	if id == nil {
+		return models.APIKey{}, fmt.Errorf("can't find api key: %w", ErrNotFound)
+	}
+	data := tx.Bucket(apiKeysBucket).Get(id)
+	if data == nil {
+		return models.APIKey{}, fmt.Errorf("can't find api key: %w", ErrNotFound)
+	}
+	var key models.APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return models.APIKey{}, ErrDecoding
+	}
+	return key, nil
+}
+
+// putAPIKey saves api key by its id.
+func putAPIKey(tx *bolt.Tx, key models.APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("marshalling error: %w", err)
+	}
+	if err = tx.Bucket(apiKeysBucket).Put([]byte(key.ID), data); err != nil {
+		return fmt.Errorf("api key writing error: %w", err)
+	}
+	return nil
+}
+
+// view runs read-only transaction. Errors of fn are returned as is, so ErrNotFound etc. can be checked.
+func (repo *BoltRepository) view(fn func(tx *bolt.Tx) error) error {
+	return repo.db.View(fn) //nolint:wrapcheck // errors of fn are already wrapped
+}
+
+// update runs read-write transaction, which is rolled back if fn fails.
+func (repo *BoltRepository) update(fn func(tx *bolt.Tx) error) error {
+	return repo.db.Update(fn) //nolint:wrapcheck // errors of fn are already wrapped
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// openBoltRepo opens the bolt repository at path.
+func openBoltRepo(t *testing.T, path string) *BoltRepository {
+	t.Helper()
+
+	repo, err := NewBoltRepository(path)
+	require.NoError(t, err)
+	return repo
+}
+
+func TestBoltRepository_reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.db")
+	expired := time.Now().Add(-time.Minute)
+
+	repo := openBoltRepo(t, path)
+	require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner"}))
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "b", OriginalURL: "https://b.example", CreatedByID: "owner"},
+		{ShortURL: "c", OriginalURL: "https://c.example", CreatedByID: "other"},
+		{ShortURL: "d", OriginalURL: "https://d.example", CreatedByID: "other", ExpiresAt: &expired},
+	}))
+	assert.ErrorIs(t, repo.Save(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://x.example"}), ErrIDTaken)
+	assert.ErrorIs(t, repo.Save(ctx, models.ShortURL{ShortURL: "x", OriginalURL: "https://a.example"}), ErrDuplicate)
+
+	require.NoError(t, repo.UpdateURL(ctx,
+		models.ShortURL{ShortURL: "a", OriginalURL: "https://new.example", CreatedByID: "owner"}, time.Now()))
+	assert.ErrorIs(t, repo.UpdateURL(ctx,
+		models.ShortURL{ShortURL: "b", OriginalURL: "https://new.example", CreatedByID: "owner"}, time.Now()), ErrDuplicate)
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "b", CreatedByID: "owner"}}))
+	deleted, err := repo.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	require.NoError(t, repo.Close(ctx))
+
+	repo = openBoltRepo(t, path)
+	defer repo.Close(ctx)
+	require.NoError(t, repo.Check(ctx))
+
+	url, err := repo.GetByID(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://new.example", url.OriginalURL)
+	assert.False(t, url.CreatedAt.IsZero())
+
+	url, err = repo.ShortenByURL(ctx, "https://new.example")
+	require.NoError(t, err)
+	assert.Equal(t, "a", url.ShortURL)
+	_, err = repo.ShortenByURL(ctx, "https://a.example")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	revisions, err := repo.GetRevisions(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, "https://a.example", revisions[0].OriginalURL)
+
+	url, err = repo.GetByID(ctx, "b")
+	require.NoError(t, err)
+	assert.True(t, url.IsDeleted)
+	// the deleted url still holds its original url
+	assert.ErrorIs(t, repo.Save(ctx, models.ShortURL{ShortURL: "x", OriginalURL: "https://b.example"}), ErrDuplicate)
+
+	_, err = repo.GetByID(ctx, "d")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	users, count, err := repo.GetUsersAndUrlsCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, users)
+	assert.Equal(t, 3, count)
+}
+
+func TestTimeKey(t *testing.T) {
+	now := time.Now()
+	times := []time.Time{
+		time.Date(1600, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Unix(-1, 999),
+		time.Unix(0, 0),
+		now,
+		now.Add(time.Nanosecond),
+		time.Date(2300, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for i := 1; i < len(times); i++ {
+		assert.Negative(t, bytes.Compare(timeKey(times[i-1]), timeKey(times[i])), times[i])
+	}
+}
+
+func TestBoltRepository_DeleteExpired_distantFuture(t *testing.T) {
+	ctx := context.Background()
+	repo := openBoltRepo(t, filepath.Join(t.TempDir(), "urls.db"))
+	defer repo.Close(ctx)
+
+	expiresAt := time.Date(2300, time.January, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://a.example", ExpiresAt: &expiresAt}))
+
+	removed, err := repo.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+	_, err = repo.GetByID(ctx, "a")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"sort"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// dateLayout is format of dates in per-day click series.
+const dateLayout = "2006-01-02"
+
+// aggregateClicks calculates link analytics from raw clicks.
+// It is used by repositories that can't aggregate clicks in storage.
+func aggregateClicks(clicks []models.Click) models.LinkStats {
+	visitors := make(map[string]bool)
+	perDay := make(map[string]int)
+	for _, click := range clicks {
+		visitors[click.IPHash] = true
+		perDay[click.At.UTC().Format(dateLayout)]++
+	}
+
+	stats := models.LinkStats{
+		TotalClicks:    len(clicks),
+		UniqueVisitors: len(visitors),
+		Daily:          make([]models.DailyClicks, 0, len(perDay)),
+	}
+	for date, count := range perDay {
+		stats.Daily = append(stats.Daily, models.DailyClicks{Date: date, Clicks: count})
+	}
+	sort.Slice(stats.Daily, func(i, j int) bool {
+		return stats.Daily[i].Date < stats.Daily[j].Date
+	})
+
+	return stats
+}
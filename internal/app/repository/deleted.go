@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// isRestorable reports whether deleted url can be restored: it was deleted not earlier than deletedAfter.
+// Urls deleted before deletion time was tracked are restorable as long as they aren't purged.
+func isRestorable(shortURL models.ShortURL, deletedAfter time.Time) bool {
+	if !shortURL.IsDeleted {
+		return false
+	}
+	return shortURL.DeletedAt == nil || !shortURL.DeletedAt.Before(deletedAfter)
+}
+
+// isPurgeable reports whether url was deleted before deletedBefore and must be removed permanently.
+func isPurgeable(shortURL models.ShortURL, deletedBefore time.Time) bool {
+	return shortURL.IsDeleted && shortURL.DeletedAt != nil && shortURL.DeletedAt.Before(deletedBefore)
+}
@@ -0,0 +1,376 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// ErrUnknownSyncPolicy is returned by NewFileRepository for unsupported FileConfig.Sync.
+var ErrUnknownSyncPolicy = errors.New("unknown sync policy")
+
+// SyncPolicy defines when records appended to the urls log are synced to disk.
+type SyncPolicy string
+
+// Supported sync policies.
+const (
+	SyncAlways   SyncPolicy = "always"   // every write is synced before it returns
+	SyncInterval SyncPolicy = "interval" // writes are synced in the background every FileConfig.SyncInterval
+	SyncNever    SyncPolicy = "never"    // syncing is left to the operating system
+)
+
+// Defaults of FileConfig.
+const (
+	defaultSyncInterval = time.Second
+	// compactMinStale is the number of stale records below which the log isn't compacted in the background.
+	compactMinStale = 100
+)
+
+// compactFileSuffix is appended to storage file path to get path of the log being compacted.
+const compactFileSuffix = ".compact"
+
+// opDelete is the op of a tombstone record.
+const opDelete = "del"
+
+// FileConfig contains settings of the urls log of FileRepository.
+type FileConfig struct {
+	Sync            SyncPolicy    // when appended records are synced to disk, SyncInterval if empty
+	SyncInterval    time.Duration // period of syncing with SyncInterval policy, a second if zero
+	CompactInterval time.Duration // period of checking whether the log needs compaction, 0 disables it
+}
+
+// fileRecord is a line of the urls log.
+// A record without op is the whole state of the url and replaces its previous records,
+// so the urls written as plain JSON objects are read as is. A tombstone record removes the url.
+type fileRecord struct {
+	Op string `json:"op,omitempty"`
+	models.ShortURL
+}
+
+// fileTombstone is a tombstone record of the url with given id.
+type fileTombstone struct {
+	Op string `json:"op"`
+	ID string `json:"id"`
+}
+
+// fileIndex is the current state of the urls log kept in memory.
+type fileIndex struct {
+	byID    map[string]models.ShortURL     // urls by short id
+	byURL   map[string]string              // short id of the first url shortening the original url
+	byUser  map[string]map[string]struct{} // short ids of urls created by the user
+	records int                            // number of records in the log, including stale ones
+}
+
+// newFileIndex creates an empty index.
+func newFileIndex() *fileIndex {
+	return &fileIndex{
+		byID:   make(map[string]models.ShortURL),
+		byURL:  make(map[string]string),
+		byUser: make(map[string]map[string]struct{}),
+	}
+}
+
+// put adds the url to the index or replaces its previous state.
+func (idx *fileIndex) put(url models.ShortURL) {
+	idx.remove(url.ShortURL)
+
+	idx.byID[url.ShortURL] = url
+	if _, ok := idx.byURL[url.OriginalURL]; !ok {
+		idx.byURL[url.OriginalURL] = url.ShortURL
+	}
+	ids, ok := idx.byUser[url.CreatedByID]
+	if !ok {
+		ids = make(map[string]struct{})
+		idx.byUser[url.CreatedByID] = ids
+	}
+	ids[url.ShortURL] = struct{}{}
+}
+
+// remove removes the url with given id from the index.
+func (idx *fileIndex) remove(id string) {
+	url, ok := idx.byID[id]
+	if !ok {
+		return
+	}
+
+	delete(idx.byID, id)
+	if idx.byURL[url.OriginalURL] == id {
+		delete(idx.byURL, url.OriginalURL)
+	}
+	if ids := idx.byUser[url.CreatedByID]; ids != nil {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(idx.byUser, url.CreatedByID)
+		}
+	}
+}
+
+// apply applies the record read from the log.
+func (idx *fileIndex) apply(record fileRecord) {
+	idx.records++
+	if record.Op == opDelete {
+		idx.remove(record.ShortURL.ShortURL)
+		return
+	}
+	idx.put(record.ShortURL)
+}
+
+// stale returns number of records in the log that don't hold the current state of any url.
+func (idx *fileIndex) stale() int {
+	return idx.records - len(idx.byID)
+}
+
+// sorted returns all urls ordered by creation time.
+func (idx *fileIndex) sorted() []models.ShortURL {
+	urls := make([]models.ShortURL, 0, len(idx.byID))
+	for _, url := range idx.byID {
+		urls = append(urls, url)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		return models.CursorOf(urls[i]).Less(models.CursorOf(urls[j]))
+	})
+	return urls
+}
+
+// appendURLs appends the urls to the log and puts them to the index. The caller must hold the write lock.
+func (repo *FileRepository) appendURLs(urls ...models.ShortURL) error {
+	records := make([]any, 0, len(urls))
+	for _, url := range urls {
+		records = append(records, url)
+	}
+	if err := repo.appendRecords(records...); err != nil {
+		return err
+	}
+
+	for _, url := range urls {
+		repo.index.put(url)
+	}
+	return nil
+}
+
+// appendTombstones appends tombstones of the urls to the log and removes them from the index.
+// The caller must hold the write lock.
+func (repo *FileRepository) appendTombstones(ids ...string) error {
+	records := make([]any, 0, len(ids))
+	for _, id := range ids {
+		records = append(records, fileTombstone{Op: opDelete, ID: id})
+	}
+	if err := repo.appendRecords(records...); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		repo.index.remove(id)
+	}
+	return nil
+}
+
+// appendRecords writes the records to the end of the log with a single write and syncs it according
+// to the sync policy. A failed write is cut off the log. The caller must hold the write lock.
+func (repo *FileRepository) appendRecords(records ...any) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	var tail int64
+	for _, record := range records {
+		line, err := encodeRecord(record)
+		if err != nil {
+			return err
+		}
+		tail = int64(buf.Len())
+		buf.Write(line)
+	}
+
+	if _, err := repo.file.Write(buf.Bytes()); err != nil {
+		// a part of the records may be written, it is cut off, so the next records aren't appended to it
+		if truncErr := repo.file.Truncate(repo.size); truncErr != nil {
+			return errors.Join(fmt.Errorf("log writing error: %w", err), fmt.Errorf("log truncate error: %w", truncErr))
+		}
+		return fmt.Errorf("log writing error: %w", err)
+	}
+	repo.index.records += len(records)
+	repo.tail = repo.size + tail
+	repo.size += int64(buf.Len())
+
+	if repo.sync == SyncAlways {
+		if err := repo.file.Sync(); err != nil {
+			return fmt.Errorf("log sync error: %w", err)
+		}
+		return nil
+	}
+	repo.dirty = true
+	return nil
+}
+
+// Compact rewrites the log keeping only the current state of every url.
+// The compacted log is written to a temporary file, synced and renamed over the log,
+// so a crash leaves either the old or the new log.
+func (repo *FileRepository) Compact(_ context.Context) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	return repo.compact()
+}
+
+// compact implements Compact. The caller must hold the write lock.
+func (repo *FileRepository) compact() error {
+	tmpPath := repo.path + compactFileSuffix
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0600) //nolint:gomnd // permission
+	if err != nil {
+		return fmt.Errorf("compacted log creating error: %w", err)
+	}
+
+	urls := repo.index.sorted()
+	size, tail, err := writeCompacted(tmp, urls)
+	if err != nil {
+		return errors.Join(err, tmp.Close(), os.Remove(tmpPath))
+	}
+	if err = os.Rename(tmpPath, repo.path); err != nil {
+		return errors.Join(fmt.Errorf("compacted log renaming error: %w", err), tmp.Close(), os.Remove(tmpPath))
+	}
+
+	// the opened temporary file is the log now
+	old := repo.file
+	repo.file = tmp
+	repo.index.records = len(urls)
+	repo.size, repo.tail = size, tail
+	repo.dirty = false
+
+	if err = syncDir(filepath.Dir(repo.path)); err != nil {
+		return errors.Join(err, old.Close())
+	}
+	if err = old.Close(); err != nil {
+		return fmt.Errorf("old log closing error: %w", err)
+	}
+	return nil
+}
+
+// writeCompacted writes the urls to the file and syncs it.
+// Returns size of the written log and offset of its last record.
+func writeCompacted(file *os.File, urls []models.ShortURL) (int64, int64, error) {
+	writer := bufio.NewWriter(file)
+	var size, tail int64
+	for _, url := range urls {
+		line, err := encodeRecord(url)
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, err = writer.Write(line); err != nil {
+			return 0, 0, fmt.Errorf("writer error: %w", err)
+		}
+		tail = size
+		size += int64(len(line))
+	}
+	if err := writer.Flush(); err != nil {
+		return 0, 0, fmt.Errorf("flush error: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return 0, 0, fmt.Errorf("compacted log sync error: %w", err)
+	}
+	return size, tail, nil
+}
+
+// syncDir syncs the directory, so renaming of a file in it survives a crash.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("directory opening error: %w", err)
+	}
+	if err = dir.Sync(); err != nil {
+		return errors.Join(fmt.Errorf("directory sync error: %w", err), dir.Close())
+	}
+	if err = dir.Close(); err != nil {
+		return fmt.Errorf("directory closing error: %w", err)
+	}
+	return nil
+}
+
+// syncLog syncs the records appended since the last sync.
+// The error is kept until the next successful sync and reported by Check.
+func (repo *FileRepository) syncLog() error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if !repo.dirty {
+		return nil
+	}
+	if err := repo.file.Sync(); err != nil {
+		repo.maintainErr = fmt.Errorf("log sync error: %w", err)
+		return repo.maintainErr
+	}
+	repo.dirty = false
+	repo.maintainErr = nil
+	return nil
+}
+
+// compactIfNeeded compacts the log if most of its records are stale.
+// The error is kept until the next successful compaction and reported by Check.
+func (repo *FileRepository) compactIfNeeded() error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	stale := repo.index.stale()
+	if stale < compactMinStale || stale < len(repo.index.byID) {
+		return nil
+	}
+	if err := repo.compact(); err != nil {
+		repo.maintainErr = fmt.Errorf("log compaction error: %w", err)
+		return repo.maintainErr
+	}
+	repo.maintainErr = nil
+	return nil
+}
+
+// maintain syncs and compacts the log in the background until ctx is done.
+// Failed syncing and compaction are retried on the next tick.
+func (repo *FileRepository) maintain(ctx context.Context, conf FileConfig) {
+	defer close(repo.done)
+
+	var syncTick, compactTick <-chan time.Time
+	if repo.sync == SyncInterval {
+		ticker := time.NewTicker(conf.SyncInterval)
+		defer ticker.Stop()
+		syncTick = ticker.C
+	}
+	if conf.CompactInterval > 0 {
+		ticker := time.NewTicker(conf.CompactInterval)
+		defer ticker.Stop()
+		compactTick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-syncTick:
+			_ = repo.syncLog()
+		case <-compactTick:
+			_ = repo.compactIfNeeded()
+		}
+	}
+}
+
+// withFileDefaults returns conf with defaults instead of zero values.
+func withFileDefaults(conf FileConfig) (FileConfig, error) {
+	switch conf.Sync {
+	case "":
+		conf.Sync = SyncInterval
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return conf, fmt.Errorf("%w: %q", ErrUnknownSyncPolicy, conf.Sync)
+	}
+	if conf.SyncInterval <= 0 {
+		conf.SyncInterval = defaultSyncInterval
+	}
+	return conf, nil
+}
@@ -0,0 +1,333 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// ErrChecksum is returned for a log record which doesn't match its checksum.
+var ErrChecksum = errors.New("record checksum mismatch")
+
+// ErrIntegrity is returned by FileRepository.Check if the urls log is damaged or can't be synced.
+var ErrIntegrity = errors.New("file storage integrity error")
+
+// quarantineFileSuffix is appended to storage file path to get path of the file of corrupt records.
+const quarantineFileSuffix = ".quarantine"
+
+// checksumSize is the length of hex encoded record checksum.
+const checksumSize = 8
+
+// crcTable is the table of record checksums.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// RecoveryReport describes what was repaired when the urls log and the files of clicks, revisions
+// and api keys were opened.
+type RecoveryReport struct {
+	Truncated   int64 // size of the torn writes cut from the end of the files
+	Quarantined int   // number of corrupt records moved to the quarantine files, including the torn writes
+}
+
+// add adds counts of the other report.
+func (r *RecoveryReport) add(other RecoveryReport) {
+	r.Truncated += other.Truncated
+	r.Quarantined += other.Quarantined
+}
+
+// quarantineRecord is a line of the quarantine file.
+type quarantineRecord struct {
+	Offset        int64     `json:"offset"`         // offset of the record in the log
+	Reason        string    `json:"reason"`         // why the record was quarantined
+	Record        []byte    `json:"record"`         // the record as it was in the log
+	QuarantinedAt time.Time `json:"quarantined_at"` // time of recovery
+}
+
+// encodeRecord returns the log line of the record: CRC-32C checksum of JSON encoded record in hex,
+// a space and the JSON itself.
+func encodeRecord(record any) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling error: %w", err)
+	}
+
+	line := make([]byte, checksumSize+1, checksumSize+1+len(data)+1)
+	hex.Encode(line, binary.BigEndian.AppendUint32(nil, crc32.Checksum(data, crcTable)))
+	line[checksumSize] = ' '
+	line = append(line, data...)
+	return append(line, '\n'), nil
+}
+
+// decodeRecord decodes the log line encoded by encodeRecord.
+func decodeRecord(line []byte) (fileRecord, error) {
+	var record fileRecord
+	if err := decodeLine(line, &record); err != nil {
+		return fileRecord{}, err
+	}
+	return record, nil
+}
+
+// decodeLine checks the checksum of the line encoded by encodeRecord and unmarshals its JSON into v.
+// Lines written before checksums were added are plain JSON objects and are decoded without checking.
+func decodeLine(line []byte, v any) error {
+	line = bytes.TrimRight(line, "\r\n")
+
+	if len(line) > 0 && line[0] == '{' {
+		if err := json.Unmarshal(line, v); err != nil {
+			return ErrDecoding
+		}
+		return nil
+	}
+
+	if len(line) <= checksumSize || line[checksumSize] != ' ' {
+		return ErrDecoding
+	}
+	checksum := make([]byte, crc32.Size)
+	if _, err := hex.Decode(checksum, line[:checksumSize]); err != nil {
+		return ErrDecoding
+	}
+	data := line[checksumSize+1:]
+	if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(checksum) {
+		return ErrChecksum
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrDecoding
+	}
+	return nil
+}
+
+// logState is the urls log read at startup.
+type logState struct {
+	index  *fileIndex
+	size   int64 // size of the log after recovery
+	tail   int64 // offset of the last record
+	report RecoveryReport
+	// corrupt records are still in the log, so it must be compacted
+	compact bool
+}
+
+// recoverLog reads the whole log, builds its index and repairs the log.
+// A torn write at the end of the log, which has no trailing newline, is kept if it is a valid record,
+// otherwise it is cut off. The torn write and corrupt records are saved to the quarantine file at quarantinePath.
+func recoverLog(file *os.File, quarantinePath string) (logState, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return logState{}, ErrFileSeek
+	}
+
+	state := logState{index: newFileIndex()}
+	var quarantined []quarantineRecord
+	quarantine := func(offset int64, reason string, line []byte) {
+		quarantined = append(quarantined, newQuarantineRecord(offset, reason, line))
+	}
+
+	var offset int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return logState{}, fmt.Errorf("log reading error: %w", err)
+		}
+		torn := errors.Is(err, io.EOF) && len(line) > 0
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			record, errDecode := decodeRecord(line)
+			switch {
+			case errDecode == nil:
+				state.index.apply(record)
+				state.tail = offset
+			case torn:
+				quarantine(offset, "torn write: "+errDecode.Error(), line)
+				state.report.Truncated = int64(len(line))
+			default:
+				quarantine(offset, errDecode.Error(), line)
+				state.compact = true
+			}
+		}
+
+		offset += int64(len(line))
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	state.size = offset
+	state.report.Quarantined = len(quarantined)
+
+	if err := saveQuarantined(quarantinePath, quarantined); err != nil {
+		return logState{}, err
+	}
+	if err := repairTail(file, &state); err != nil {
+		return logState{}, err
+	}
+	return state, nil
+}
+
+// recoverFile repairs the file of records at path, like clicks, the way recoverLog repairs the urls log.
+// Corrupt records and the torn write at the end of the file are saved to the quarantine file at quarantinePath
+// and removed from the file, which is rewritten, see rewriteFile. A valid last record without trailing newline
+// is completed. Returns the file to use, the rewritten one replaces the given file, which is closed.
+func recoverFile(file *os.File, path, quarantinePath string) (*os.File, RecoveryReport, error) {
+	var report RecoveryReport
+	var quarantined []quarantineRecord
+	var valid bytes.Buffer
+	rewrite := false
+
+	var offset int64
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, math.MaxInt64))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, RecoveryReport{}, fmt.Errorf("file reading error: %w", err)
+		}
+		torn := errors.Is(err, io.EOF) && len(line) > 0
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record json.RawMessage
+			errDecode := decodeLine(line, &record)
+			switch {
+			case errDecode == nil:
+				valid.Write(bytes.TrimRight(line, "\r\n"))
+				valid.WriteByte('\n')
+				rewrite = rewrite || torn
+			case torn:
+				quarantined = append(quarantined, newQuarantineRecord(offset, "torn write: "+errDecode.Error(), line))
+				report.Truncated = int64(len(line))
+				rewrite = true
+			default:
+				quarantined = append(quarantined, newQuarantineRecord(offset, errDecode.Error(), line))
+				rewrite = true
+			}
+		}
+
+		offset += int64(len(line))
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	report.Quarantined = len(quarantined)
+
+	if err := saveQuarantined(quarantinePath, quarantined); err != nil {
+		return nil, RecoveryReport{}, err
+	}
+	if !rewrite {
+		return file, report, nil
+	}
+
+	rewritten, err := rewriteFile(path, valid.Bytes())
+	if err != nil {
+		return nil, RecoveryReport{}, err
+	}
+	if err = file.Close(); err != nil {
+		return nil, RecoveryReport{}, errors.Join(fmt.Errorf("damaged file closing error: %w", err), rewritten.Close())
+	}
+	return rewritten, report, nil
+}
+
+// newQuarantineRecord returns the quarantine file line of the corrupt record at offset.
+func newQuarantineRecord(offset int64, reason string, line []byte) quarantineRecord {
+	return quarantineRecord{
+		Offset:        offset,
+		Reason:        reason,
+		Record:        bytes.Clone(line),
+		QuarantinedAt: time.Now(),
+	}
+}
+
+// repairTail cuts the torn write off or completes the valid one with a newline.
+func repairTail(file *os.File, state *logState) error {
+	if state.report.Truncated > 0 {
+		state.size -= state.report.Truncated
+		if err := file.Truncate(state.size); err != nil {
+			return fmt.Errorf("torn write truncating error: %w", err)
+		}
+		if err := file.Sync(); err != nil {
+			return fmt.Errorf("log sync error: %w", err)
+		}
+		return nil
+	}
+
+	if state.size == 0 {
+		return nil
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, state.size-1); err != nil {
+		return fmt.Errorf("log reading error: %w", err)
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	if _, err := file.Write([]byte{'\n'}); err != nil {
+		return fmt.Errorf("log writing error: %w", err)
+	}
+	state.size++
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("log sync error: %w", err)
+	}
+	return nil
+}
+
+// saveQuarantined appends corrupt records to the quarantine file and syncs it,
+// so they aren't lost when the log is repaired.
+func saveQuarantined(path string, records []quarantineRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("marshalling error: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600) //nolint:gomnd // permission
+	if err != nil {
+		return fmt.Errorf("quarantine file opening error: %w", err)
+	}
+	if _, err = file.Write(buf.Bytes()); err != nil {
+		return errors.Join(fmt.Errorf("quarantine file writing error: %w", err), file.Close())
+	}
+	if err = file.Sync(); err != nil {
+		return errors.Join(fmt.Errorf("quarantine file sync error: %w", err), file.Close())
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("quarantine file close error: %w", err)
+	}
+	return nil
+}
+
+// verify checks that the log has the size of written records and its last record is valid.
+// The caller must hold the lock.
+func (repo *FileRepository) verify() error {
+	info, err := repo.file.Stat()
+	if err != nil {
+		return fmt.Errorf("file stat error: %w", err)
+	}
+	if info.Size() != repo.size {
+		return fmt.Errorf("%w: log size is %d, expected %d", ErrIntegrity, info.Size(), repo.size)
+	}
+
+	if repo.size > repo.tail {
+		line := make([]byte, repo.size-repo.tail)
+		if _, err = repo.file.ReadAt(line, repo.tail); err != nil {
+			return fmt.Errorf("log reading error: %w", err)
+		}
+		if _, err = decodeRecord(line); err != nil {
+			return fmt.Errorf("%w: last record: %w", ErrIntegrity, err)
+		}
+	}
+
+	if repo.maintainErr != nil {
+		return fmt.Errorf("%w: %w", ErrIntegrity, repo.maintainErr)
+	}
+	return nil
+}
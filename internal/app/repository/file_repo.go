@@ -4,12 +4,14 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/GTedya/shortener/internal/app/models"
 )
@@ -17,297 +19,783 @@ import (
 var ErrDecoding = errors.New("decoding error")
 var ErrFileSeek = errors.New("file seek error")
 
+// clicksFileSuffix is appended to storage file path to get path of the clicks file.
+const clicksFileSuffix = ".clicks"
+
+// revisionsFileSuffix is appended to storage file path to get path of the revisions file.
+const revisionsFileSuffix = ".revisions"
+
+// rewriteFileSuffix is appended to path of a file to get path of its rewritten copy, see rewriteFile.
+const rewriteFileSuffix = ".tmp"
+
+// apiKeysFileSuffix is appended to storage file path to get path of the api keys file.
+const apiKeysFileSuffix = ".keys"
+
 // FileRepository is repository that uses files for storage.
+//
+// Urls are stored in an append-only log, see fileRecord: every change appends the new state of the url,
+// permanently removed urls get a tombstone. Every record has a checksum, see encodeRecord.
+// The log is read once at startup into the index, which serves all lookups, and is compacted
+// in the background when most of its records are stale.
 type FileRepository struct {
-	file   *os.File      // file that we will be writing to
-	writer *bufio.Writer // buffered writer that will write to the file
-	mutex  sync.RWMutex  // mutex that will be used to synchronize access to the file
+	path        string             // path of the urls log
+	file        *os.File           // urls log opened for appending
+	index       *fileIndex         // current state of the urls log, guarded by mutex
+	sync        SyncPolicy         // when appended records are synced to disk
+	dirty       bool               // there are appended records that aren't synced yet, guarded by mutex
+	size        int64              // size of the urls log written by the repository, guarded by mutex
+	tail        int64              // offset of the last record in the urls log, guarded by mutex
+	recovery    RecoveryReport     // what was repaired when the files were opened
+	maintainErr error              // error of the last background syncing or compaction, guarded by mutex
+	mutex       sync.RWMutex       // mutex that will be used to synchronize access to the files and the index
+	stop        context.CancelFunc // stops background syncing and compaction
+	done        chan struct{}      // closed when background syncing and compaction is stopped
+	clicksFile  *os.File           // append-only file of clicks, one record per line, see encodeRecord
+	clicksMutex sync.RWMutex       // mutex that will be used to synchronize access to the clicks file, taken after mutex
+	// file of previous url destinations, one record per line, guarded by mutex
+	revisionsFile *os.File
+	// append-only file of api keys, one record per line, the last line of a key holds its current state,
+	// guarded by mutex
+	apiKeysFile *os.File
+	apiKeys     map[string]models.APIKey // api keys read from the api keys file by id, guarded by mutex
+	apiKeyIDs   map[string]string        // ids of api keys by hash, guarded by mutex
 }
 
 // NewFileRepository creates new file repository. Creates file at filePath if it doesn't exist.
-// It opens a file, creates a buffered writer, and returns a pointer to a FileRepository.
-func NewFileRepository(filePath string) (*FileRepository, error) {
+// It opens the urls log, builds its index and starts background syncing and compaction
+// according to conf, which are stopped by Close.
+// Damaged log is repaired: a torn write at its end is cut off, corrupt records are moved to the file
+// with ".quarantine" suffix and skipped, see RecoveryReport.
+// Clicks, revisions and api keys are stored next to it in the files with ".clicks", ".revisions"
+// and ".keys" suffixes, which have checksummed records too and are repaired the same way.
+func NewFileRepository(filePath string, conf FileConfig) (*FileRepository, error) {
+	conf, err := withFileDefaults(conf)
+	if err != nil {
+		return nil, err
+	}
+
 	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600) //nolint:gomnd // permission
 	if err != nil {
 		return nil, fmt.Errorf("file opening error: %w", err)
 	}
 
-	return &FileRepository{
-		mutex:  sync.RWMutex{},
-		file:   file,
-		writer: bufio.NewWriter(file),
-	}, nil
-}
+	state, err := recoverLog(file, filePath+quarantineFileSuffix)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("log recovery error: %w", err), file.Close())
+	}
 
-// SaveBatch saves multiple urls.
-// Checks if the urls are unique and then saving them.
-func (repo *FileRepository) SaveBatch(ctx context.Context, batch []models.ShortURL) error {
-	for _, shortURL := range batch {
-		_, err := repo.GetByID(ctx, shortURL.ShortURL)
-		if err == nil {
-			return ErrDuplicate
+	clicksFile, err := openRecordsFile(filePath+clicksFileSuffix, &state.report)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("clicks file opening error: %w", err), file.Close())
+	}
+
+	revisionsFile, err := openRecordsFile(filePath+revisionsFileSuffix, &state.report)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("revisions file opening error: %w", err), file.Close(), clicksFile.Close())
+	}
+
+	apiKeysFile, err := openRecordsFile(filePath+apiKeysFileSuffix, &state.report)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("api keys file opening error: %w", err),
+			file.Close(), clicksFile.Close(), revisionsFile.Close())
+	}
+
+	apiKeys, apiKeyIDs, err := readAPIKeys(apiKeysFile)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("api keys reading error: %w", err),
+			file.Close(), clicksFile.Close(), revisionsFile.Close(), apiKeysFile.Close())
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	repo := &FileRepository{
+		mutex:         sync.RWMutex{},
+		path:          filePath,
+		file:          file,
+		index:         state.index,
+		sync:          conf.Sync,
+		size:          state.size,
+		tail:          state.tail,
+		recovery:      state.report,
+		stop:          stop,
+		done:          make(chan struct{}),
+		clicksFile:    clicksFile,
+		revisionsFile: revisionsFile,
+		apiKeysFile:   apiKeysFile,
+		apiKeys:       apiKeys,
+		apiKeyIDs:     apiKeyIDs,
+	}
+
+	// quarantined records are removed from the log, so they aren't quarantined again
+	if state.compact {
+		if err = repo.compact(); err != nil {
+			stop()
+			return nil, errors.Join(fmt.Errorf("log compaction error: %w", err), repo.closeFiles())
 		}
 	}
 
+	go repo.maintain(ctx, conf)
+	return repo, nil
+}
+
+// openRecordsFile opens the file of records at path for appending, creating it if needed, repairs it,
+// see recoverFile, and adds what was repaired to the report.
+func openRecordsFile(path string, report *RecoveryReport) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600) //nolint:gomnd // permission
+	if err != nil {
+		return nil, fmt.Errorf("file opening error: %w", err)
+	}
+
+	repaired, recovered, err := recoverFile(file, path, path+quarantineFileSuffix)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("file recovery error: %w", err), file.Close())
+	}
+	report.add(recovered)
+	return repaired, nil
+}
+
+// Recovery returns what was repaired when the urls log and the files of clicks, revisions and api keys were opened.
+func (repo *FileRepository) Recovery() RecoveryReport {
+	return repo.recovery
+}
+
+// SaveBatch saves multiple urls.
+// Checks if the urls are unique and then appending them to the log with a single write.
+func (repo *FileRepository) SaveBatch(_ context.Context, batch []models.ShortURL) error {
 	repo.mutex.Lock()
 	defer repo.mutex.Unlock()
 
+	urls := make([]models.ShortURL, 0, len(batch))
+	ids := make(map[string]struct{}, len(batch))
+	originals := make(map[string]struct{}, len(batch))
 	for _, shortURL := range batch {
-		data, err := json.Marshal(shortURL)
-		if err != nil {
-			return fmt.Errorf("unmarshalling error: %w", err)
+		if err := repo.checkUnique(shortURL); err != nil {
+			return err
 		}
-
-		if _, errWrite := repo.writer.Write(data); errWrite != nil {
-			return fmt.Errorf("writer error: %w", errWrite)
+		if _, ok := ids[shortURL.ShortURL]; ok {
+			return ErrIDTaken
 		}
-
-		if errWriteByte := repo.writer.WriteByte('\n'); errWriteByte != nil {
-			return fmt.Errorf("byte writing error: %w", err)
+		if _, ok := originals[shortURL.OriginalURL]; ok {
+			return ErrDuplicate
 		}
+		ids[shortURL.ShortURL] = struct{}{}
+		originals[shortURL.OriginalURL] = struct{}{}
+		urls = append(urls, withCreatedAt(shortURL))
 	}
-	if err := repo.writer.Flush(); err != nil {
-		return fmt.Errorf("flush error: %w", err)
+
+	return repo.appendURLs(urls...)
+}
+
+// Save checks if the url is unique and then appending it to the log.
+func (repo *FileRepository) Save(_ context.Context, shortURL models.ShortURL) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if err := repo.checkUnique(shortURL); err != nil {
+		return err
 	}
 
-	return nil
+	return repo.appendURLs(withCreatedAt(shortURL))
 }
 
-// Save checks if the url is unique and then saving it to the file.
-func (repo *FileRepository) Save(ctx context.Context, shortURL models.ShortURL) error {
-	_, err := repo.GetByID(ctx, shortURL.ShortURL)
-	if err == nil {
+// checkUnique returns ErrIDTaken if the short id is used and ErrDuplicate if the original url is shortened.
+// Deleted urls keep their original urls until they are purged. The caller must hold the lock.
+func (repo *FileRepository) checkUnique(shortURL models.ShortURL) error {
+	if _, ok := repo.index.byID[shortURL.ShortURL]; ok {
+		return ErrIDTaken
+	}
+	if _, ok := repo.index.byURL[shortURL.OriginalURL]; ok {
 		return ErrDuplicate
 	}
+	return nil
+}
 
-	data, err := json.Marshal(shortURL)
-	if err != nil {
-		return fmt.Errorf("marshalling error: %w", err)
+// GetByID gets a URL by its ID.
+func (repo *FileRepository) GetByID(_ context.Context, id string) (models.ShortURL, error) {
+	repo.mutex.RLock()
+	url, ok := repo.index.byID[id]
+	repo.mutex.RUnlock()
+
+	if !ok {
+		return models.ShortURL{}, fmt.Errorf("can't find full URL by id: %w", ErrNotFound)
 	}
+	return url, nil
+}
 
-	repo.mutex.Lock()
-	defer repo.mutex.Unlock()
+// ShortenByURL gets a shortened URL by its original URL.
+func (repo *FileRepository) ShortenByURL(_ context.Context, originalURL string) (models.ShortURL, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
 
-	if _, errWrite := repo.writer.Write(data); errWrite != nil {
-		return fmt.Errorf("writer error: %w", errWrite)
+	id, ok := repo.index.byURL[originalURL]
+	if !ok {
+		return models.ShortURL{}, fmt.Errorf("can't find shortened URL by original URL: %w", ErrNotFound)
 	}
+	return repo.index.byID[id], nil
+}
 
-	if errWriteByte := repo.writer.WriteByte('\n'); errWriteByte != nil {
-		return fmt.Errorf("byte writing error: %w", err)
+// GetUsersUrls returns the page of urls that were created by user with id userID.
+func (repo *FileRepository) GetUsersUrls(
+	_ context.Context,
+	userID string,
+	query models.URLQuery,
+) ([]models.ShortURL, error) {
+	repo.mutex.RLock()
+	var URLs []models.ShortURL
+	for id := range repo.index.byUser[userID] {
+		if url := repo.index.byID[id]; query.Matches(url) {
+			URLs = append(URLs, url)
+		}
 	}
+	repo.mutex.RUnlock()
+
+	return pageOf(URLs, query), nil
+}
+
+// GetUrls returns up to limit urls, including deleted ones, with short ids after afterID ordered by short id.
+// Limit 0 means no limit.
+func (repo *FileRepository) GetUrls(_ context.Context, afterID string, limit int) ([]models.ShortURL, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return urlsAfter(repo.index.byID, afterID, limit), nil
+}
 
-	if errFlush := repo.writer.Flush(); errFlush != nil {
-		return fmt.Errorf("flush error: %w", errFlush)
+// Close stops background syncing and compaction, syncs the log and closes files.
+func (repo *FileRepository) Close(_ context.Context) error {
+	repo.stop()
+	<-repo.done
+
+	if err := repo.syncLog(); err != nil {
+		return errors.Join(err, repo.closeFiles())
 	}
+	return repo.closeFiles()
+}
 
+// closeFiles closes files.
+func (repo *FileRepository) closeFiles() error {
+	if err := repo.file.Close(); err != nil {
+		return fmt.Errorf("file close error: %w", err)
+	}
+	if err := repo.clicksFile.Close(); err != nil {
+		return fmt.Errorf("clicks file close error: %w", err)
+	}
+	if err := repo.revisionsFile.Close(); err != nil {
+		return fmt.Errorf("revisions file close error: %w", err)
+	}
+	if err := repo.apiKeysFile.Close(); err != nil {
+		return fmt.Errorf("api keys file close error: %w", err)
+	}
 	return nil
 }
 
-// readEntries scans the file and applies a callback to each decoded entry.
-func (repo *FileRepository) readEntries(callback func(entry models.ShortURL) (bool, error)) error {
+// Check checks integrity of the urls log: it must have the size of the records written by the repository,
+// its last record must match the checksum and background syncing and compaction must succeed.
+// Returns ErrIntegrity otherwise.
+func (repo *FileRepository) Check(_ context.Context) error {
 	repo.mutex.RLock()
 	defer repo.mutex.RUnlock()
 
-	if _, err := repo.file.Seek(0, io.SeekStart); err != nil {
-		return ErrFileSeek
+	return repo.verify()
+}
+
+// DeleteUrls marks all given urls as deleted and remembers time of deletion.
+// Deleted urls are appended to the log, so they can be restored until they are purged.
+func (repo *FileRepository) DeleteUrls(_ context.Context, urls []models.ShortURL) error {
+	now := time.Now()
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	changed := make([]models.ShortURL, 0, len(urls))
+	for _, urlToDelete := range urls {
+		foundURL, ok := repo.index.byID[urlToDelete.ShortURL]
+		if ok && foundURL.CreatedByID == urlToDelete.CreatedByID && !foundURL.IsDeleted {
+			foundURL.IsDeleted = true
+			foundURL.DeletedAt = &now
+			changed = append(changed, foundURL)
+		}
 	}
 
-	scanner := bufio.NewScanner(repo.file)
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		var entry models.ShortURL
-		if err := json.NewDecoder(bytes.NewReader(line)).Decode(&entry); err != nil {
-			return ErrDecoding
+	return repo.appendURLs(changed...)
+}
+
+// GetUsersAndUrlsCount returns number of users that created urls and number of urls, deleted urls are counted
+// until they are purged.
+func (repo *FileRepository) GetUsersAndUrlsCount(_ context.Context) (int, int, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return len(repo.index.byUser), len(repo.index.byID), nil
+}
+
+// DeleteExpired appends tombstones of urls that have expired by now and removes their revisions and clicks.
+// Returns number of removed urls.
+func (repo *FileRepository) DeleteExpired(_ context.Context, now time.Time) (int, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	var expired []string
+	for id, url := range repo.index.byID {
+		if url.IsExpired(now) {
+			expired = append(expired, id)
 		}
-		stop, err := callback(entry)
-		if err != nil {
-			return fmt.Errorf("callback error: %w", err)
+	}
+	return repo.removeUrls(expired)
+}
+
+// DeleteExpiredURLs appends tombstones of the urls with given ids that have expired by now and removes their
+// revisions and clicks. Other urls are kept. Returns number of removed urls.
+func (repo *FileRepository) DeleteExpiredURLs(_ context.Context, ids []string, now time.Time) (int, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	var expired []string
+	for _, id := range ids {
+		if url, ok := repo.index.byID[id]; ok && url.IsExpired(now) {
+			expired = append(expired, id)
 		}
-		if stop {
-			break
+	}
+	return repo.removeUrls(expired)
+}
+
+// removeUrls appends tombstones of the urls and removes their revisions and clicks.
+// The caller must hold the write lock.
+func (repo *FileRepository) removeUrls(ids []string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if err := repo.appendTombstones(ids...); err != nil {
+		return 0, err
+	}
+	if err := repo.pruneRevisions(); err != nil {
+		return 0, fmt.Errorf("revisions pruning error: %w", err)
+	}
+	if err := repo.pruneClicks(); err != nil {
+		return 0, fmt.Errorf("clicks pruning error: %w", err)
+	}
+	return len(ids), nil
+}
+
+// SaveClicks appends clicks to the clicks file. Clicks of urls removed before they are saved are dropped.
+func (repo *FileRepository) SaveClicks(_ context.Context, clicks []models.Click) error {
+	// the urls lock is held until clicks are written, so they aren't written after their urls are removed
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	records := make([]any, 0, len(clicks))
+	for _, click := range clicks {
+		if _, ok := repo.index.byID[click.ShortURL]; ok {
+			records = append(records, click)
 		}
 	}
 
+	repo.clicksMutex.Lock()
+	defer repo.clicksMutex.Unlock()
+
+	if err := appendFile(repo.clicksFile, records...); err != nil {
+		return fmt.Errorf("clicks writing error: %w", err)
+	}
 	return nil
 }
 
-// GetByID gets a URL by its ID.
-func (repo *FileRepository) GetByID(_ context.Context, id string) (models.ShortURL, error) {
-	var result models.ShortURL
-	err := repo.readEntries(func(entry models.ShortURL) (bool, error) {
-		if entry.ShortURL == id {
-			result = entry
-			return true, nil
+// GetClickStats scans the clicks file and returns click analytics of the url with given id.
+func (repo *FileRepository) GetClickStats(ctx context.Context, id string) (models.LinkStats, error) {
+	clicks, err := repo.GetClicks(ctx, id)
+	if err != nil {
+		return models.LinkStats{}, err
+	}
+	return aggregateClicks(clicks), nil
+}
+
+// GetClicks scans the clicks file and returns clicks of the url with given id in order they were saved.
+func (repo *FileRepository) GetClicks(_ context.Context, id string) ([]models.Click, error) {
+	repo.clicksMutex.RLock()
+	defer repo.clicksMutex.RUnlock()
+
+	clicks := make([]models.Click, 0)
+	err := readLines(repo.clicksFile, func(line []byte) error {
+		var click models.Click
+		if err := decodeLine(line, &click); err != nil {
+			return err
 		}
-		return false, nil
+		if click.ShortURL == id {
+			clicks = append(clicks, click)
+		}
+		return nil
 	})
 	if err != nil {
-		return models.ShortURL{}, err
-	}
-	if result.ShortURL == "" {
-		return models.ShortURL{}, errors.New("can't find full URL by id")
+		return nil, err
 	}
-	return result, nil
+	return clicks, nil
 }
 
-// ShortenByURL gets a shortened URL by its original URL.
-func (repo *FileRepository) ShortenByURL(_ context.Context, originalURL string) (models.ShortURL, error) {
-	var result models.ShortURL
-	err := repo.readEntries(func(entry models.ShortURL) (bool, error) {
-		if entry.OriginalURL == originalURL {
-			result = entry
-			return true, nil
-		}
-		return false, nil
+// UpdateURL changes destination of the url owned by shortURL.CreatedByID, appends it to the log
+// and appends the previous destination to the revisions file. If the url can't be appended to the log,
+// the revision is cut off the revisions file, so it doesn't report a change that never happened.
+func (repo *FileRepository) UpdateURL(_ context.Context, shortURL models.ShortURL, changedAt time.Time) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	foundURL, ok := repo.index.byID[shortURL.ShortURL]
+	if !ok || foundURL.IsDeleted || foundURL.CreatedByID != shortURL.CreatedByID {
+		return ErrNotFound
+	}
+	if foundURL.OriginalURL == shortURL.OriginalURL {
+		return nil
+	}
+	if id, ok := repo.index.byURL[shortURL.OriginalURL]; ok && id != shortURL.ShortURL {
+		return ErrDuplicate
+	}
+
+	info, err := repo.revisionsFile.Stat()
+	if err != nil {
+		return fmt.Errorf("revisions file stat error: %w", err)
+	}
+	err = appendFile(repo.revisionsFile, models.Revision{
+		ShortURL:    foundURL.ShortURL,
+		OriginalURL: foundURL.OriginalURL,
+		ChangedAt:   changedAt,
 	})
 	if err != nil {
-		return models.ShortURL{}, err
+		return fmt.Errorf("revisions writing error: %w", err)
 	}
-	if result.ShortURL == "" {
-		return models.ShortURL{}, errors.New("can't find shortened URL by original URL")
+
+	foundURL.OriginalURL = shortURL.OriginalURL
+	if err = repo.appendURLs(foundURL); err != nil {
+		if truncErr := repo.revisionsFile.Truncate(info.Size()); truncErr != nil {
+			return errors.Join(err, fmt.Errorf("revisions truncate error: %w", truncErr))
+		}
+		return err
 	}
-	return result, nil
+	return nil
 }
 
-// GetUsersUrls reads the file line by line and returning all the urls that were created by user with id userID.
-func (repo *FileRepository) GetUsersUrls(_ context.Context, userID string) ([]models.ShortURL, error) {
+// GetRevisions scans the revisions file and returns previous destinations of the url with given id, oldest first.
+func (repo *FileRepository) GetRevisions(_ context.Context, id string) ([]models.Revision, error) {
 	repo.mutex.RLock()
 	defer repo.mutex.RUnlock()
 
-	if _, err := repo.file.Seek(0, io.SeekStart); err != nil {
-		return nil, ErrFileSeek
+	revisions, err := repo.readRevisions()
+	if err != nil {
+		return nil, err
 	}
 
-	var entry models.ShortURL
-	var URLs []models.ShortURL
+	result := make([]models.Revision, 0)
+	for _, revision := range revisions {
+		if revision.ShortURL == id {
+			result = append(result, revision)
+		}
+	}
+	return result, nil
+}
 
-	scanner := bufio.NewScanner(repo.file)
+// SaveRevisions appends revisions to the revisions file. Revisions of urls removed before they are saved are dropped.
+func (repo *FileRepository) SaveRevisions(_ context.Context, revisions []models.Revision) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
 
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if err := json.NewDecoder(bytes.NewReader(line)).Decode(&entry); err != nil {
-			return nil, ErrDecoding
-		}
-		if entry.CreatedByID == userID {
-			URLs = append(URLs, entry)
+	records := make([]any, 0, len(revisions))
+	for _, revision := range revisions {
+		if _, ok := repo.index.byID[revision.ShortURL]; ok {
+			records = append(records, revision)
 		}
 	}
 
-	return URLs, nil
+	if err := appendFile(repo.revisionsFile, records...); err != nil {
+		return fmt.Errorf("revisions writing error: %w", err)
+	}
+	return nil
+}
+
+// readRevisions reads all revisions from the revisions file.
+func (repo *FileRepository) readRevisions() ([]models.Revision, error) {
+	var revisions []models.Revision
+	err := readLines(repo.revisionsFile, func(line []byte) error {
+		var revision models.Revision
+		if err := decodeLine(line, &revision); err != nil {
+			return err
+		}
+		revisions = append(revisions, revision)
+		return nil
+	})
+	return revisions, err
 }
 
-// Close closes file.
-func (repo *FileRepository) Close(_ context.Context) error {
-	if err := repo.file.Close(); err != nil {
-		return fmt.Errorf("file close error: %w", err)
+// pruneRevisions rewrites the revisions file keeping revisions of existing urls only.
+// The caller must hold the write lock.
+func (repo *FileRepository) pruneRevisions() error {
+	file, err := pruneLines(repo.revisionsFile, repo.path+revisionsFileSuffix, repo.index.byID)
+	if err != nil || file == nil {
+		return err
+	}
+	old := repo.revisionsFile
+	repo.revisionsFile = file
+	if err = old.Close(); err != nil {
+		return fmt.Errorf("old revisions file closing error: %w", err)
 	}
 	return nil
 }
 
-// Check checks if file is ok.
-func (repo *FileRepository) Check(_ context.Context) error {
-	if _, err := repo.file.Stat(); err != nil {
-		return fmt.Errorf("file stat error: %w", err)
+// pruneClicks rewrites the clicks file keeping clicks of existing urls only.
+// The caller must hold the write lock.
+func (repo *FileRepository) pruneClicks() error {
+	repo.clicksMutex.Lock()
+	defer repo.clicksMutex.Unlock()
+
+	file, err := pruneLines(repo.clicksFile, repo.path+clicksFileSuffix, repo.index.byID)
+	if err != nil || file == nil {
+		return err
+	}
+	old := repo.clicksFile
+	repo.clicksFile = file
+	if err = old.Close(); err != nil {
+		return fmt.Errorf("old clicks file closing error: %w", err)
 	}
 	return nil
 }
 
-// DeleteUrls deletes all given urls.
-func (repo *FileRepository) DeleteUrls(_ context.Context, urls []models.ShortURL) error {
-	repo.mutex.Lock()
-	defer repo.mutex.Unlock()
+// pruneLines rewrites the file at path, see rewriteFile, keeping the lines of existing urls only.
+// Lines are records with short id of the url in the id field, like clicks and revisions.
+// Returns the rewritten file or nil if all lines are kept.
+func pruneLines(file *os.File, path string, existingURLs map[string]models.ShortURL) (*os.File, error) {
+	var buf bytes.Buffer
+	pruned := false
+	err := readLines(file, func(line []byte) error {
+		var record struct {
+			ID string `json:"id"`
+		}
+		if err := decodeLine(line, &record); err != nil {
+			return err
+		}
+		if _, ok := existingURLs[record.ID]; !ok {
+			pruned = true
+			return nil
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+		return nil
+	})
+	if err != nil || !pruned {
+		return nil, err
+	}
+	return rewriteFile(path, buf.Bytes())
+}
 
-	existingURLs, err := repo.readFileToMap()
-	if err != nil {
-		return fmt.Errorf("readFileToMap error: %w", err)
+// appendFile writes the records encoded by encodeRecord to the end of the file with a single write.
+// A failed write is cut off the file, so the next records aren't appended to a torn one.
+// The caller must hold the lock guarding the file.
+func appendFile(file *os.File, records ...any) error {
+	if len(records) == 0 {
+		return nil
 	}
 
-	// mark deleted urls in memory
-	for _, urlToDelete := range urls {
-		foundURL, ok := existingURLs[urlToDelete.ShortURL]
-		if ok && foundURL.CreatedByID == urlToDelete.CreatedByID {
-			foundURL.IsDeleted = true
-			existingURLs[urlToDelete.ShortURL] = foundURL
+	var buf bytes.Buffer
+	for _, record := range records {
+		line, err := encodeRecord(record)
+		if err != nil {
+			return err
 		}
+		buf.Write(line)
 	}
 
-	// write back in memory map to file
-	err = repo.writeMapToFile(existingURLs)
+	info, err := file.Stat()
 	if err != nil {
-		return fmt.Errorf("writeMapToFile error: %w", err)
+		return fmt.Errorf("file stat error: %w", err)
+	}
+	if _, err = file.Write(buf.Bytes()); err != nil {
+		if truncErr := file.Truncate(info.Size()); truncErr != nil {
+			return errors.Join(fmt.Errorf("file writing error: %w", err), fmt.Errorf("file truncate error: %w", truncErr))
+		}
+		return fmt.Errorf("file writing error: %w", err)
 	}
+	return nil
+}
 
+// readLines calls fn for every line of the file. The file is read with ReadAt, so concurrent readers
+// don't move the offset of each other.
+func readLines(file *os.File, fn func(line []byte) error) error {
+	scanner := bufio.NewScanner(io.NewSectionReader(file, 0, math.MaxInt64))
+	for scanner.Scan() {
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("scanner error: %w", err)
+	}
 	return nil
 }
 
-// readFileToMap reads the file and returns a map of all the urls in the file.
-func (repo *FileRepository) readFileToMap() (map[string]models.ShortURL, error) {
-	if _, err := repo.file.Seek(0, io.SeekStart); err != nil {
-		return nil, ErrFileSeek
+// rewriteFile writes data to a temporary file, syncs it and renames it over the file at path,
+// so a crash leaves either the old or the new file. Returns the new file opened for appending.
+func rewriteFile(path string, data []byte) (*os.File, error) {
+	tmpPath := path + rewriteFileSuffix
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0600) //nolint:gomnd // permission
+	if err != nil {
+		return nil, fmt.Errorf("temporary file creating error: %w", err)
+	}
+	if _, err = tmp.Write(data); err != nil {
+		return nil, errors.Join(fmt.Errorf("temporary file writing error: %w", err), tmp.Close(), os.Remove(tmpPath))
+	}
+	if err = tmp.Sync(); err != nil {
+		return nil, errors.Join(fmt.Errorf("temporary file sync error: %w", err), tmp.Close(), os.Remove(tmpPath))
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return nil, errors.Join(fmt.Errorf("temporary file renaming error: %w", err), tmp.Close(), os.Remove(tmpPath))
+	}
+	if err = syncDir(filepath.Dir(path)); err != nil {
+		return nil, errors.Join(err, tmp.Close())
+	}
+	return tmp, nil
+}
+
+// RestoreUrls undeletes given urls owned by CreatedByID that were deleted not earlier than deletedAfter
+// and appends them to the log. Returns ids of restored urls.
+func (repo *FileRepository) RestoreUrls(
+	_ context.Context,
+	urls []models.ShortURL,
+	deletedAfter time.Time,
+) ([]string, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	changed := make([]models.ShortURL, 0, len(urls))
+	restored := make([]string, 0, len(urls))
+	for _, urlToRestore := range urls {
+		foundURL, ok := repo.index.byID[urlToRestore.ShortURL]
+		if ok && foundURL.CreatedByID == urlToRestore.CreatedByID && isRestorable(foundURL, deletedAfter) {
+			foundURL.IsDeleted = false
+			foundURL.DeletedAt = nil
+			changed = append(changed, foundURL)
+			restored = append(restored, foundURL.ShortURL)
+		}
+	}
+
+	if err := repo.appendURLs(changed...); err != nil {
+		return nil, err
 	}
-	var entry models.ShortURL
-	existingURLs := make(map[string]models.ShortURL)
+	return restored, nil
+}
 
-	scanner := bufio.NewScanner(repo.file)
+// PurgeDeleted appends tombstones of urls deleted before deletedBefore and removes their revisions and clicks.
+// Urls deleted before deletion time was tracked get current time as deletion time,
+// so they are purged after the next grace period. Returns number of removed urls.
+func (repo *FileRepository) PurgeDeleted(_ context.Context, deletedBefore time.Time) (int, error) {
+	now := time.Now()
 
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if err := json.NewDecoder(bytes.NewReader(line)).Decode(&entry); err != nil {
-			return nil, ErrDecoding
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	var purged []string
+	var changed []models.ShortURL
+	for id, url := range repo.index.byID {
+		switch {
+		case isPurgeable(url, deletedBefore):
+			purged = append(purged, id)
+		case url.IsDeleted && url.DeletedAt == nil:
+			url.DeletedAt = &now
+			changed = append(changed, url)
 		}
-		existingURLs[entry.ShortURL] = entry
 	}
-	return existingURLs, nil
+
+	if err := repo.appendURLs(changed...); err != nil {
+		return 0, err
+	}
+	if len(purged) == 0 {
+		return 0, nil
+	}
+	if err := repo.appendTombstones(purged...); err != nil {
+		return 0, err
+	}
+	if err := repo.pruneRevisions(); err != nil {
+		return 0, fmt.Errorf("revisions pruning error: %w", err)
+	}
+	if err := repo.pruneClicks(); err != nil {
+		return 0, fmt.Errorf("clicks pruning error: %w", err)
+	}
+	return len(purged), nil
 }
 
-// writeMapToFile writes the map to the file.
-func (repo *FileRepository) writeMapToFile(existingURLs map[string]models.ShortURL) error {
-	if err := repo.file.Truncate(0); err != nil {
-		return fmt.Errorf("file truncate error: %w", err)
+// SaveAPIKey appends api key to the api keys file. Returns ErrIDTaken if the key id or hash is already used.
+func (repo *FileRepository) SaveAPIKey(_ context.Context, key models.APIKey) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if _, ok := repo.apiKeys[key.ID]; ok {
+		return ErrIDTaken
 	}
-	if _, err := repo.file.Seek(0, 0); err != nil {
-		return ErrFileSeek
+	if _, ok := repo.apiKeyIDs[key.Hash]; ok {
+		return ErrIDTaken
 	}
 
-	for _, url := range existingURLs {
-		data, err := json.Marshal(url)
-		if err != nil {
-			return fmt.Errorf("marshalling error: %w", err)
-		}
+	return repo.appendAPIKey(key)
+}
 
-		if _, errWrite := repo.writer.Write(data); errWrite != nil {
-			return fmt.Errorf("writer error: %w", errWrite)
-		}
+// GetAPIKey returns active api key with given hash.
+func (repo *FileRepository) GetAPIKey(_ context.Context, hash string) (models.APIKey, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
 
-		if errWriteByte := repo.writer.WriteByte('\n'); errWriteByte != nil {
-			return fmt.Errorf("byte writing error: %w", err)
-		}
+	key, ok := repo.apiKeys[repo.apiKeyIDs[hash]]
+	if !ok || key.RevokedAt != nil {
+		return models.APIKey{}, fmt.Errorf("can't find api key: %w", ErrNotFound)
 	}
-	if err := repo.writer.Flush(); err != nil {
-		return fmt.Errorf("flush error: %w", err)
+	return key, nil
+}
+
+// RevokeAPIKey revokes active api key with key.ID owned by key.UserID and appends its revoked state
+// to the api keys file. Returns ErrNotFound for missing, revoked or someone else's keys.
+func (repo *FileRepository) RevokeAPIKey(_ context.Context, key models.APIKey, revokedAt time.Time) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	existing, ok := repo.apiKeys[key.ID]
+	if !ok || existing.UserID != key.UserID || existing.RevokedAt != nil {
+		return ErrNotFound
 	}
-	return nil
+
+	existing.RevokedAt = &revokedAt
+	return repo.appendAPIKey(existing)
 }
 
-func (repo *FileRepository) GetUsersAndUrlsCount(_ context.Context) (int, int, error) {
-	if _, err := repo.file.Seek(0, io.SeekStart); err != nil {
-		return 0, 0, ErrFileSeek
+// GetAPIKeys returns all api keys, including revoked ones, ordered by id.
+func (repo *FileRepository) GetAPIKeys(_ context.Context) ([]models.APIKey, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return sortedAPIKeys(repo.apiKeys), nil
+}
+
+// appendAPIKey appends the state of the api key to the api keys file and keeps it in memory.
+// The caller must hold the write lock.
+func (repo *FileRepository) appendAPIKey(key models.APIKey) error {
+	if err := appendFile(repo.apiKeysFile, key); err != nil {
+		return fmt.Errorf("api keys writing error: %w", err)
 	}
 
-	uniqueUsersIds := make(map[string]bool)
-	urlsCount := 0
+	repo.apiKeys[key.ID] = key
+	repo.apiKeyIDs[key.Hash] = key.ID
+	return nil
+}
 
-	var entry models.ShortURL
-	scanner := bufio.NewScanner(repo.file)
+// readAPIKeys reads the api keys file. Returns api keys by id and their ids by hash,
+// the last line of a key replaces its previous lines.
+func readAPIKeys(file *os.File) (map[string]models.APIKey, map[string]string, error) {
+	keys := make(map[string]models.APIKey)
+	ids := make(map[string]string)
 
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if err := json.NewDecoder(bytes.NewReader(line)).Decode(&entry); err != nil {
-			return 0, 0, ErrDecoding
+	err := readLines(file, func(line []byte) error {
+		var key models.APIKey
+		if err := decodeLine(line, &key); err != nil {
+			return err
 		}
-		urlsCount++
-		uniqueUsersIds[entry.CreatedByID] = true
+		keys[key.ID] = key
+		ids[key.Hash] = key.ID
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-
-	return len(uniqueUsersIds), urlsCount, nil
+	return keys, ids, nil
 }
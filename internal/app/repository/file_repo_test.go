@@ -0,0 +1,438 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// openFileRepo opens the file repository at path with background syncing and compaction disabled.
+func openFileRepo(t *testing.T, path string) *FileRepository {
+	t.Helper()
+
+	repo, err := NewFileRepository(path, FileConfig{Sync: SyncAlways})
+	require.NoError(t, err)
+	return repo
+}
+
+// countLines returns number of lines in the file.
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+func TestFileRepository_reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+	expired := time.Now().Add(-time.Minute)
+
+	repo := openFileRepo(t, path)
+	require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner"}))
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "b", OriginalURL: "https://b.example", CreatedByID: "owner"},
+		{ShortURL: "c", OriginalURL: "https://c.example", CreatedByID: "other"},
+		{ShortURL: "d", OriginalURL: "https://d.example", CreatedByID: "other", ExpiresAt: &expired},
+	}))
+	assert.ErrorIs(t, repo.Save(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://x.example"}), ErrIDTaken)
+
+	require.NoError(t, repo.UpdateURL(ctx,
+		models.ShortURL{ShortURL: "a", OriginalURL: "https://new.example", CreatedByID: "owner"}, time.Now()))
+	assert.ErrorIs(t, repo.UpdateURL(ctx,
+		models.ShortURL{ShortURL: "b", OriginalURL: "https://new.example", CreatedByID: "owner"}, time.Now()), ErrDuplicate)
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "b", CreatedByID: "owner"}}))
+	deleted, err := repo.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	require.NoError(t, repo.Close(ctx))
+
+	repo = openFileRepo(t, path)
+	defer repo.Close(ctx)
+
+	url, err := repo.GetByID(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://new.example", url.OriginalURL)
+	assert.False(t, url.CreatedAt.IsZero())
+
+	url, err = repo.ShortenByURL(ctx, "https://new.example")
+	require.NoError(t, err)
+	assert.Equal(t, "a", url.ShortURL)
+	_, err = repo.ShortenByURL(ctx, "https://a.example")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	url, err = repo.GetByID(ctx, "b")
+	require.NoError(t, err)
+	assert.True(t, url.IsDeleted)
+
+	_, err = repo.GetByID(ctx, "d")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	urls, err := repo.GetUsersUrls(ctx, "owner", models.URLQuery{})
+	require.NoError(t, err)
+	assert.Len(t, urls, 2)
+
+	users, count, err := repo.GetUsersAndUrlsCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, users)
+	assert.Equal(t, 3, count)
+}
+
+func TestFileRepository_Compact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	repo := openFileRepo(t, path)
+	require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner"}))
+	require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: "b", OriginalURL: "https://b.example", CreatedByID: "owner"}))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "a", CreatedByID: "owner"}}))
+		_, err := repo.RestoreUrls(ctx, []models.ShortURL{{ShortURL: "a", CreatedByID: "owner"}}, time.Time{})
+		require.NoError(t, err)
+	}
+	purged, err := repo.PurgeDeleted(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	require.Equal(t, 22, countLines(t, path))
+
+	require.NoError(t, repo.Compact(ctx))
+	assert.Equal(t, 2, countLines(t, path))
+	assert.NoFileExists(t, path+compactFileSuffix)
+
+	// the compacted log is appended to after compaction
+	require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: "c", OriginalURL: "https://c.example", CreatedByID: "owner"}))
+	require.NoError(t, repo.Close(ctx))
+	assert.Equal(t, 3, countLines(t, path))
+
+	repo = openFileRepo(t, path)
+	defer repo.Close(ctx)
+
+	urls, err := repo.GetUsersUrls(ctx, "owner", models.URLQuery{})
+	require.NoError(t, err)
+	require.Len(t, urls, 3)
+	assert.False(t, urls[0].IsDeleted)
+}
+
+func TestFileRepository_legacyFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+	legacy := `{"url":"https://a.example","id":"a","created_by":"owner","is_deleted":false}
+{"url":"https://b.example","id":"b","created_by":"owner","is_deleted":true}
+`
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0600))
+
+	repo := openFileRepo(t, path)
+	defer repo.Close(ctx)
+
+	url, err := repo.GetByID(ctx, "b")
+	require.NoError(t, err)
+	assert.True(t, url.IsDeleted)
+
+	url, err = repo.ShortenByURL(ctx, "https://a.example")
+	require.NoError(t, err)
+	assert.Equal(t, "a", url.ShortURL)
+}
+
+func TestNewFileRepository_unknownSyncPolicy(t *testing.T) {
+	_, err := NewFileRepository(filepath.Join(t.TempDir(), "urls.json"), FileConfig{Sync: "sometimes"})
+	assert.ErrorIs(t, err, ErrUnknownSyncPolicy)
+}
+
+// appendToFile appends data to the file at path like a crashed write would.
+func appendToFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = file.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+func TestFileRepository_recovery(t *testing.T) {
+	ctx := context.Background()
+
+	// saveURLs creates the log with two urls.
+	saveURLs := func(t *testing.T) string {
+		path := filepath.Join(t.TempDir(), "urls.json")
+		repo := openFileRepo(t, path)
+		require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+			{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner"},
+			{ShortURL: "b", OriginalURL: "https://b.example", CreatedByID: "owner"},
+		}))
+		require.NoError(t, repo.Close(ctx))
+		return path
+	}
+
+	t.Run("torn write is cut off", func(t *testing.T) {
+		path := saveURLs(t)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		appendToFile(t, path, []byte(`00000000 {"url":"https://c.exa`))
+
+		repo := openFileRepo(t, path)
+		defer repo.Close(ctx)
+
+		assert.Equal(t, RecoveryReport{Truncated: 30, Quarantined: 1}, repo.Recovery())
+		truncated, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, info.Size(), truncated.Size())
+		assert.Equal(t, 1, countLines(t, path+quarantineFileSuffix))
+		assert.NoError(t, repo.Check(ctx))
+
+		require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: "c", OriginalURL: "https://c.example"}))
+		assert.NoError(t, repo.Check(ctx))
+	})
+
+	t.Run("valid torn write is kept", func(t *testing.T) {
+		path := saveURLs(t)
+		line, err := encodeRecord(models.ShortURL{ShortURL: "c", OriginalURL: "https://c.example"})
+		require.NoError(t, err)
+		appendToFile(t, path, line[:len(line)-1])
+
+		repo := openFileRepo(t, path)
+		defer repo.Close(ctx)
+
+		assert.Zero(t, repo.Recovery())
+		_, err = repo.GetByID(ctx, "c")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, countLines(t, path))
+		assert.NoError(t, repo.Check(ctx))
+	})
+
+	t.Run("corrupt record is quarantined", func(t *testing.T) {
+		path := saveURLs(t)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		data[bytes.Index(data, []byte("a.example"))] = 'x' // the checksum doesn't match anymore
+		require.NoError(t, os.WriteFile(path, data, 0600))
+
+		repo := openFileRepo(t, path)
+		assert.Equal(t, RecoveryReport{Quarantined: 1}, repo.Recovery())
+		_, err = repo.GetByID(ctx, "a")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = repo.GetByID(ctx, "b")
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Check(ctx))
+		require.NoError(t, repo.Close(ctx))
+
+		// the corrupt record was removed from the log
+		repo = openFileRepo(t, path)
+		defer repo.Close(ctx)
+		assert.Zero(t, repo.Recovery())
+		assert.Equal(t, 1, countLines(t, path))
+		assert.Equal(t, 1, countLines(t, path+quarantineFileSuffix))
+	})
+
+	t.Run("check detects damage", func(t *testing.T) {
+		path := saveURLs(t)
+		repo := openFileRepo(t, path)
+		defer repo.Close(ctx)
+		require.NoError(t, repo.Check(ctx))
+
+		appendToFile(t, path, []byte("garbage\n"))
+		assert.ErrorIs(t, repo.Check(ctx), ErrIntegrity)
+	})
+}
+
+func TestFileRepository_UpdateURL_failedAppend(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+	repo := openFileRepo(t, path)
+	defer repo.Close(ctx)
+	require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner"}))
+
+	// the log can't be written, like on a full disk
+	log := repo.file
+	readOnly, err := os.Open(path)
+	require.NoError(t, err)
+	repo.file = readOnly
+	err = repo.UpdateURL(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://b.example", CreatedByID: "owner"}, time.Now())
+	repo.file = log
+	require.NoError(t, readOnly.Close())
+	require.Error(t, err)
+
+	revisions, err := repo.GetRevisions(ctx, "a")
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+	url, err := repo.GetByID(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example", url.OriginalURL)
+}
+
+func TestFileRepository_recoveryOfRecordFiles(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+	hash := strings.Repeat("a", 64)
+
+	repo := openFileRepo(t, path)
+	require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner"}))
+	require.NoError(t, repo.SaveClicks(ctx, []models.Click{{ShortURL: "a", IPHash: "1"}, {ShortURL: "a", IPHash: "2"}}))
+	require.NoError(t, repo.UpdateURL(ctx,
+		models.ShortURL{ShortURL: "a", OriginalURL: "https://b.example", CreatedByID: "owner"}, time.Now()))
+	require.NoError(t, repo.SaveAPIKey(ctx, models.APIKey{ID: "key", UserID: "owner", Hash: hash}))
+	require.NoError(t, repo.Close(ctx))
+
+	// every file ends with a torn write, the clicks file has a corrupt record too
+	torn := []byte(`00000000 {"id":"a","at":"20`)
+	for _, suffix := range []string{clicksFileSuffix, revisionsFileSuffix, apiKeysFileSuffix} {
+		appendToFile(t, path+suffix, torn)
+	}
+	data, err := os.ReadFile(path + clicksFileSuffix)
+	require.NoError(t, err)
+	data[bytes.Index(data, []byte(`"1"`))+1] = '3' // the checksum of the first click doesn't match anymore
+	require.NoError(t, os.WriteFile(path+clicksFileSuffix, data, 0600))
+
+	repo = openFileRepo(t, path)
+	assert.Equal(t, RecoveryReport{Truncated: 3 * int64(len(torn)), Quarantined: 4}, repo.Recovery())
+	assert.Equal(t, 2, countLines(t, path+clicksFileSuffix+quarantineFileSuffix))
+
+	clicks, err := repo.GetClicks(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, clicks, 1)
+	assert.Equal(t, "2", clicks[0].IPHash)
+	revisions, err := repo.GetRevisions(ctx, "a")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 1)
+	_, err = repo.GetAPIKey(ctx, hash)
+	assert.NoError(t, err)
+
+	// records are appended after the repaired ones
+	require.NoError(t, repo.SaveClicks(ctx, []models.Click{{ShortURL: "a", IPHash: "4"}}))
+	require.NoError(t, repo.Close(ctx))
+
+	repo = openFileRepo(t, path)
+	defer repo.Close(ctx)
+	assert.Zero(t, repo.Recovery())
+	clicks, err = repo.GetClicks(ctx, "a")
+	require.NoError(t, err)
+	assert.Len(t, clicks, 2)
+}
+
+func TestFileRepository_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	repo := openFileRepo(t, path)
+	require.NoError(t, repo.SaveAPIKey(ctx, models.APIKey{ID: "1", UserID: "owner", Hash: "hash1"}))
+	require.NoError(t, repo.SaveAPIKey(ctx, models.APIKey{ID: "2", UserID: "owner", Hash: "hash2"}))
+	require.NoError(t, repo.RevokeAPIKey(ctx, models.APIKey{ID: "1", UserID: "owner"}, time.Now()))
+
+	// concurrent lookups don't interfere with each other
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, err := repo.GetAPIKey(ctx, "hash2")
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, repo.Close(ctx))
+
+	repo = openFileRepo(t, path)
+	defer repo.Close(ctx)
+
+	_, err := repo.GetAPIKey(ctx, "hash1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	key, err := repo.GetAPIKey(ctx, "hash2")
+	require.NoError(t, err)
+	assert.Equal(t, "owner", key.UserID)
+	assert.ErrorIs(t, repo.SaveAPIKey(ctx, models.APIKey{ID: "3", UserID: "owner", Hash: "hash1"}), ErrIDTaken)
+}
+
+func TestFileRepository_GetRevisions(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	repo := openFileRepo(t, path)
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner"},
+		{ShortURL: "b", OriginalURL: "https://b.example", CreatedByID: "owner"},
+	}))
+	require.NoError(t, repo.UpdateURL(ctx,
+		models.ShortURL{ShortURL: "a", OriginalURL: "https://a2.example", CreatedByID: "owner"}, time.Now()))
+	require.NoError(t, repo.UpdateURL(ctx,
+		models.ShortURL{ShortURL: "b", OriginalURL: "https://b2.example", CreatedByID: "owner"}, time.Now()))
+
+	// concurrent reads don't interfere with each other
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				revisions, err := repo.GetRevisions(ctx, "a")
+				assert.NoError(t, err)
+				assert.Len(t, revisions, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "b", CreatedByID: "owner"}}))
+	purged, err := repo.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.NoFileExists(t, path+revisionsFileSuffix+rewriteFileSuffix)
+	require.NoError(t, repo.Close(ctx))
+
+	repo = openFileRepo(t, path)
+	defer repo.Close(ctx)
+
+	revisions, err := repo.GetRevisions(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, "https://a.example", revisions[0].OriginalURL)
+	assert.Equal(t, 1, countLines(t, path+revisionsFileSuffix))
+}
+
+func TestFileRepository_GetClickStats(t *testing.T) {
+	ctx := context.Background()
+	repo := openFileRepo(t, filepath.Join(t.TempDir(), "urls.json"))
+	defer repo.Close(ctx)
+
+	require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://a.example"}))
+	require.NoError(t, repo.SaveClicks(ctx, []models.Click{
+		{ShortURL: "a", At: time.Now(), IPHash: "1"},
+		{ShortURL: "a", At: time.Now(), IPHash: "2"},
+	}))
+
+	// concurrent reads don't interfere with each other
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				stats, err := repo.GetClickStats(ctx, "a")
+				assert.NoError(t, err)
+				assert.Equal(t, 2, stats.TotalClicks)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// LoadFileSnapshot reads the urls log at filePath and the files of clicks, revisions and api keys next to it
+// into memory, e.g. to migrate them. Unlike NewFileRepository it doesn't change the files: they are opened
+// read-only, damaged records are skipped instead of being quarantined and the log isn't compacted.
+// Every file is read once, so clicks and revisions of a url are looked up without scanning the files again.
+// Returns the repository and number of skipped damaged records.
+func LoadFileSnapshot(filePath string) (*InMemoryRepository, int, error) {
+	index := newFileIndex()
+	skipped, err := readSnapshotFile(filePath, false, func(line []byte) error {
+		record, err := decodeRecord(line)
+		if err != nil {
+			return err
+		}
+		index.apply(record)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("log reading error: %w", err)
+	}
+
+	repo := NewInMemoryRepository()
+	for _, url := range index.byID {
+		repo.put(url)
+	}
+
+	n, err := readSnapshotFile(filePath+clicksFileSuffix, true, func(line []byte) error {
+		var click models.Click
+		if err := decodeLine(line, &click); err != nil {
+			return err
+		}
+		// clicks of removed urls may stay in the file until it is pruned
+		if _, ok := repo.storage[click.ShortURL]; ok {
+			repo.clicks[click.ShortURL] = append(repo.clicks[click.ShortURL], click)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("clicks file reading error: %w", err)
+	}
+	skipped += n
+
+	n, err = readSnapshotFile(filePath+revisionsFileSuffix, true, func(line []byte) error {
+		var revision models.Revision
+		if err := decodeLine(line, &revision); err != nil {
+			return err
+		}
+		if _, ok := repo.storage[revision.ShortURL]; ok {
+			repo.revisions[revision.ShortURL] = append(repo.revisions[revision.ShortURL], revision)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("revisions file reading error: %w", err)
+	}
+	skipped += n
+
+	n, err = readSnapshotFile(filePath+apiKeysFileSuffix, true, func(line []byte) error {
+		var key models.APIKey
+		if err := decodeLine(line, &key); err != nil {
+			return err
+		}
+		// the last line of a key holds its current state
+		repo.apiKeys[key.ID] = key
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("api keys file reading error: %w", err)
+	}
+	skipped += n
+
+	return repo, skipped, nil
+}
+
+// readSnapshotFile opens the file at path read-only and calls decode for every non-empty line.
+// Lines decode fails for are skipped and counted. Missing file is treated as empty if it is optional.
+// Returns number of skipped lines.
+func readSnapshotFile(path string, optional bool, decode func(line []byte) error) (int, error) {
+	file, err := os.Open(path)
+	if optional && errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("file opening error: %w", err)
+	}
+
+	skipped := 0
+	err = readLines(file, func(line []byte) error {
+		if len(bytes.TrimSpace(line)) == 0 {
+			return nil
+		}
+		if decode(line) != nil {
+			skipped++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Join(err, file.Close())
+	}
+	if err = file.Close(); err != nil {
+		return 0, fmt.Errorf("file closing error: %w", err)
+	}
+	return skipped, nil
+}
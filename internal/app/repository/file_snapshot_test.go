@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+func TestLoadFileSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	repo := openFileRepo(t, path)
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner"},
+		{ShortURL: "b", OriginalURL: "https://b.example", CreatedByID: "owner"},
+	}))
+	require.NoError(t, repo.SaveClicks(ctx, []models.Click{{ShortURL: "a", IPHash: "1"}, {ShortURL: "b", IPHash: "2"}}))
+	require.NoError(t, repo.UpdateURL(ctx,
+		models.ShortURL{ShortURL: "a", OriginalURL: "https://x.example", CreatedByID: "owner"}, time.Now()))
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "b", CreatedByID: "owner"}}))
+	require.NoError(t, repo.SaveAPIKey(ctx, models.APIKey{ID: "key", UserID: "owner", Hash: "hash"}))
+	require.NoError(t, repo.RevokeAPIKey(ctx, models.APIKey{ID: "key", UserID: "owner"}, time.Now()))
+	require.NoError(t, repo.Close(ctx))
+
+	// damaged records are skipped, but the files aren't repaired
+	torn := []byte(`00000000 {"id":"a","at":"20`)
+	files := make(map[string][]byte)
+	for _, suffix := range []string{"", clicksFileSuffix, revisionsFileSuffix, apiKeysFileSuffix} {
+		appendToFile(t, path+suffix, torn)
+		data, err := os.ReadFile(path + suffix)
+		require.NoError(t, err)
+		files[path+suffix] = data
+	}
+
+	snapshot, skipped, err := LoadFileSnapshot(path)
+	require.NoError(t, err)
+	assert.Equal(t, 4, skipped)
+
+	for name, data := range files {
+		got, err := os.ReadFile(name)
+		require.NoError(t, err)
+		assert.Equal(t, data, got, name)
+		assert.NoFileExists(t, name+quarantineFileSuffix)
+	}
+
+	url, err := snapshot.GetByID(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://x.example", url.OriginalURL)
+	url, err = snapshot.GetByID(ctx, "b")
+	require.NoError(t, err)
+	assert.True(t, url.IsDeleted)
+
+	clicks, err := snapshot.GetClicks(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, clicks, 1)
+	assert.Equal(t, "1", clicks[0].IPHash)
+	revisions, err := snapshot.GetRevisions(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, "https://a.example", revisions[0].OriginalURL)
+	keys, err := snapshot.GetAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].RevokedAt)
+
+	t.Run("missing log", func(t *testing.T) {
+		_, _, err := LoadFileSnapshot(filepath.Join(t.TempDir(), "missing.json"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -2,41 +2,59 @@ package repository
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/GTedya/shortener/internal/app/models"
 )
 
 // InMemoryRepository is repository that uses memory for storage.
 type InMemoryRepository struct {
-	storage map[string]models.ShortURL // map that will store urls
-	mutex   sync.RWMutex               // read-write mutex that will be used to synchronize access to the storage map
+	storage   map[string]models.ShortURL   // map that will store urls
+	originals map[string]string            // short ids by original url, keeps original urls unique
+	clicks    map[string][]models.Click    // clicks grouped by short url id
+	revisions map[string][]models.Revision // previous destinations grouped by short url id
+	apiKeys   map[string]models.APIKey     // api keys by key id
+	mutex     sync.RWMutex                 // read-write mutex that will be used to synchronize access to the storage map
 }
 
 // NewInMemoryRepository creates a new InMemoryRepository and returns a pointer to it.
 func NewInMemoryRepository() *InMemoryRepository {
 	return &InMemoryRepository{
-		storage: make(map[string]models.ShortURL),
-		mutex:   sync.RWMutex{},
+		storage:   make(map[string]models.ShortURL),
+		originals: make(map[string]string),
+		clicks:    make(map[string][]models.Click),
+		revisions: make(map[string][]models.Revision),
+		apiKeys:   make(map[string]models.APIKey),
+		mutex:     sync.RWMutex{},
 	}
 }
 
 // SaveBatch saves multiple urls.
-// Checks if the urls are unique and then saving them.
+// Checks if the urls are unique and then saving them, so either all or none of them are saved.
 func (repo *InMemoryRepository) SaveBatch(_ context.Context, batch []models.ShortURL) error {
 	repo.mutex.Lock()
 	defer repo.mutex.Unlock()
 
+	ids := make(map[string]struct{}, len(batch))
+	originals := make(map[string]struct{}, len(batch))
 	for _, shortURL := range batch {
-		_, ok := repo.storage[shortURL.ShortURL]
-		if ok {
+		if err := repo.checkUnique(shortURL); err != nil {
+			return err
+		}
+		if _, ok := ids[shortURL.ShortURL]; ok {
+			return ErrIDTaken
+		}
+		if _, ok := originals[shortURL.OriginalURL]; ok {
 			return ErrDuplicate
 		}
+		ids[shortURL.ShortURL] = struct{}{}
+		originals[shortURL.OriginalURL] = struct{}{}
 	}
 
 	for _, shortURL := range batch {
-		repo.storage[shortURL.ShortURL] = shortURL
+		repo.put(withCreatedAt(shortURL))
 	}
 
 	return nil
@@ -44,19 +62,41 @@ func (repo *InMemoryRepository) SaveBatch(_ context.Context, batch []models.Shor
 
 // Save checks if the url is unique and then saving it to the memory.
 func (repo *InMemoryRepository) Save(_ context.Context, shortURL models.ShortURL) error {
-	repo.mutex.RLock()
-	_, ok := repo.storage[shortURL.ShortURL]
-	repo.mutex.RUnlock()
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	if err := repo.checkUnique(shortURL); err != nil {
+		return err
+	}
+	repo.put(withCreatedAt(shortURL))
 
-	if ok {
+	return nil
+}
+
+// checkUnique returns ErrIDTaken if the short id is used and ErrDuplicate if the original url is shortened.
+// Deleted urls keep their original urls until they are purged. The caller must hold the lock.
+func (repo *InMemoryRepository) checkUnique(shortURL models.ShortURL) error {
+	if _, ok := repo.storage[shortURL.ShortURL]; ok {
+		return ErrIDTaken
+	}
+	if _, ok := repo.originals[shortURL.OriginalURL]; ok {
 		return ErrDuplicate
 	}
+	return nil
+}
 
-	repo.mutex.Lock()
+// put saves the url and indexes its original url. The caller must hold the write lock.
+func (repo *InMemoryRepository) put(shortURL models.ShortURL) {
 	repo.storage[shortURL.ShortURL] = shortURL
-	repo.mutex.Unlock()
+	repo.originals[shortURL.OriginalURL] = shortURL.ShortURL
+}
 
-	return nil
+// remove removes the url with given id together with its revisions and clicks. The caller must hold the write lock.
+func (repo *InMemoryRepository) remove(id string) {
+	delete(repo.originals, repo.storage[id].OriginalURL)
+	delete(repo.storage, id)
+	delete(repo.revisions, id)
+	delete(repo.clicks, id)
 }
 
 // GetByID gets the url by id.
@@ -66,7 +106,7 @@ func (repo *InMemoryRepository) GetByID(_ context.Context, id string) (models.Sh
 	repo.mutex.RUnlock()
 
 	if !ok {
-		return models.ShortURL{}, errors.New("can't find full url by id")
+		return models.ShortURL{}, fmt.Errorf("can't find full url by id: %w", ErrNotFound)
 	}
 
 	return url, nil
@@ -77,30 +117,44 @@ func (repo *InMemoryRepository) ShortenByURL(_ context.Context, originalURL stri
 	repo.mutex.RLock()
 	defer repo.mutex.RUnlock()
 
-	for _, entry := range repo.storage {
-		if entry.OriginalURL == originalURL {
-			return entry, nil
-		}
+	if id, ok := repo.originals[originalURL]; ok {
+		return repo.storage[id], nil
 	}
-	return models.ShortURL{}, errors.New("can't find shortened URL by original URL")
+	return models.ShortURL{}, fmt.Errorf("can't find shortened URL by original URL: %w", ErrNotFound)
 }
 
-// GetUsersUrls gets all the urls that were created by the user with the given id.
-func (repo *InMemoryRepository) GetUsersUrls(_ context.Context, userID string) ([]models.ShortURL, error) {
+// GetUsersUrls gets the page of urls that were created by the user with the given id.
+func (repo *InMemoryRepository) GetUsersUrls(
+	_ context.Context,
+	userID string,
+	query models.URLQuery,
+) ([]models.ShortURL, error) {
 	repo.mutex.RLock()
 	var URLs []models.ShortURL
 	for _, URL := range repo.storage {
-		if URL.CreatedByID == userID {
+		if URL.CreatedByID == userID && query.Matches(URL) {
 			URLs = append(URLs, URL)
 		}
 	}
 	repo.mutex.RUnlock()
-	return URLs, nil
+	return pageOf(URLs, query), nil
+}
+
+// GetUrls returns up to limit urls, including deleted ones, with short ids after afterID ordered by short id.
+// Limit 0 means no limit.
+func (repo *InMemoryRepository) GetUrls(_ context.Context, afterID string, limit int) ([]models.ShortURL, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return urlsAfter(repo.storage, afterID, limit), nil
 }
 
-// Close clears map.
+// Close clears maps.
 func (repo *InMemoryRepository) Close(_ context.Context) error {
 	repo.storage = make(map[string]models.ShortURL)
+	repo.originals = make(map[string]string)
+	repo.clicks = make(map[string][]models.Click)
+	repo.revisions = make(map[string][]models.Revision)
 	return nil
 }
 
@@ -109,15 +163,18 @@ func (repo *InMemoryRepository) Check(_ context.Context) error {
 	return nil
 }
 
-// DeleteUrls deletes all given urls.
+// DeleteUrls marks all given urls as deleted and remembers time of deletion.
 func (repo *InMemoryRepository) DeleteUrls(_ context.Context, urls []models.ShortURL) error {
+	now := time.Now()
+
 	repo.mutex.Lock()
 	defer repo.mutex.Unlock()
 
 	for _, urlToDelete := range urls {
 		foundURL, ok := repo.storage[urlToDelete.ShortURL]
-		if ok && foundURL.CreatedByID == urlToDelete.CreatedByID {
+		if ok && foundURL.CreatedByID == urlToDelete.CreatedByID && !foundURL.IsDeleted {
 			foundURL.IsDeleted = true
+			foundURL.DeletedAt = &now
 			repo.storage[urlToDelete.ShortURL] = foundURL
 		}
 	}
@@ -125,6 +182,8 @@ func (repo *InMemoryRepository) DeleteUrls(_ context.Context, urls []models.Shor
 	return nil
 }
 
+// GetUsersAndUrlsCount returns number of users that created urls and number of urls, deleted urls are counted
+// until they are purged.
 func (repo *InMemoryRepository) GetUsersAndUrlsCount(_ context.Context) (int, int, error) {
 	uniqueUsersIds := make(map[string]bool)
 
@@ -137,3 +196,212 @@ func (repo *InMemoryRepository) GetUsersAndUrlsCount(_ context.Context) (int, in
 
 	return len(uniqueUsersIds), len(repo.storage), nil
 }
+
+// DeleteExpired removes urls that have expired by now together with their revisions and clicks.
+// Returns number of removed urls.
+func (repo *InMemoryRepository) DeleteExpired(_ context.Context, now time.Time) (int, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	deleted := 0
+	for id, shortURL := range repo.storage {
+		if shortURL.IsExpired(now) {
+			repo.remove(id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// DeleteExpiredURLs removes the urls with given ids that have expired by now together with their revisions
+// and clicks. Other urls are kept. Returns number of removed urls.
+func (repo *InMemoryRepository) DeleteExpiredURLs(_ context.Context, ids []string, now time.Time) (int, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	deleted := 0
+	for _, id := range ids {
+		if shortURL, ok := repo.storage[id]; ok && shortURL.IsExpired(now) {
+			repo.remove(id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// SaveClicks appends clicks to memory. Clicks of urls removed before they are saved are dropped.
+func (repo *InMemoryRepository) SaveClicks(_ context.Context, clicks []models.Click) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, click := range clicks {
+		if _, ok := repo.storage[click.ShortURL]; !ok {
+			continue
+		}
+		repo.clicks[click.ShortURL] = append(repo.clicks[click.ShortURL], click)
+	}
+	return nil
+}
+
+// GetClickStats returns click analytics of the url with given id.
+func (repo *InMemoryRepository) GetClickStats(_ context.Context, id string) (models.LinkStats, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return aggregateClicks(repo.clicks[id]), nil
+}
+
+// GetClicks returns clicks of the url with given id in order they were saved.
+func (repo *InMemoryRepository) GetClicks(_ context.Context, id string) ([]models.Click, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return append(make([]models.Click, 0, len(repo.clicks[id])), repo.clicks[id]...), nil
+}
+
+// UpdateURL changes destination of the url owned by shortURL.CreatedByID
+// and keeps the previous destination in revision history.
+func (repo *InMemoryRepository) UpdateURL(_ context.Context, shortURL models.ShortURL, changedAt time.Time) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	foundURL, ok := repo.storage[shortURL.ShortURL]
+	if !ok || foundURL.IsDeleted || foundURL.CreatedByID != shortURL.CreatedByID {
+		return ErrNotFound
+	}
+	if foundURL.OriginalURL == shortURL.OriginalURL {
+		return nil
+	}
+	if _, ok := repo.originals[shortURL.OriginalURL]; ok {
+		return ErrDuplicate
+	}
+
+	repo.revisions[foundURL.ShortURL] = append(repo.revisions[foundURL.ShortURL], models.Revision{
+		ShortURL:    foundURL.ShortURL,
+		OriginalURL: foundURL.OriginalURL,
+		ChangedAt:   changedAt,
+	})
+	delete(repo.originals, foundURL.OriginalURL)
+	foundURL.OriginalURL = shortURL.OriginalURL
+	repo.put(foundURL)
+
+	return nil
+}
+
+// GetRevisions returns previous destinations of the url with given id, oldest first.
+func (repo *InMemoryRepository) GetRevisions(_ context.Context, id string) ([]models.Revision, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return append(make([]models.Revision, 0, len(repo.revisions[id])), repo.revisions[id]...), nil
+}
+
+// SaveRevisions appends revisions to previous destinations of their urls.
+// Revisions of urls removed before they are saved are dropped.
+func (repo *InMemoryRepository) SaveRevisions(_ context.Context, revisions []models.Revision) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, revision := range revisions {
+		if _, ok := repo.storage[revision.ShortURL]; !ok {
+			continue
+		}
+		repo.revisions[revision.ShortURL] = append(repo.revisions[revision.ShortURL], revision)
+	}
+	return nil
+}
+
+// RestoreUrls undeletes given urls owned by CreatedByID that were deleted not earlier than deletedAfter.
+// Returns ids of restored urls.
+func (repo *InMemoryRepository) RestoreUrls(
+	_ context.Context,
+	urls []models.ShortURL,
+	deletedAfter time.Time,
+) ([]string, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	restored := make([]string, 0, len(urls))
+	for _, urlToRestore := range urls {
+		foundURL, ok := repo.storage[urlToRestore.ShortURL]
+		if ok && foundURL.CreatedByID == urlToRestore.CreatedByID && isRestorable(foundURL, deletedAfter) {
+			foundURL.IsDeleted = false
+			foundURL.DeletedAt = nil
+			repo.storage[urlToRestore.ShortURL] = foundURL
+			restored = append(restored, foundURL.ShortURL)
+		}
+	}
+
+	return restored, nil
+}
+
+// PurgeDeleted permanently removes urls deleted before deletedBefore together with their revisions and clicks.
+// Returns number of removed urls.
+func (repo *InMemoryRepository) PurgeDeleted(_ context.Context, deletedBefore time.Time) (int, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	purged := 0
+	for id, shortURL := range repo.storage {
+		if isPurgeable(shortURL, deletedBefore) {
+			repo.remove(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// SaveAPIKey saves api key. Returns ErrIDTaken if the key id or hash is already used.
+func (repo *InMemoryRepository) SaveAPIKey(_ context.Context, key models.APIKey) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, existing := range repo.apiKeys {
+		if existing.ID == key.ID || existing.Hash == key.Hash {
+			return ErrIDTaken
+		}
+	}
+	repo.apiKeys[key.ID] = key
+
+	return nil
+}
+
+// GetAPIKey returns active api key with given hash.
+func (repo *InMemoryRepository) GetAPIKey(_ context.Context, hash string) (models.APIKey, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	for _, key := range repo.apiKeys {
+		if key.Hash == hash && key.RevokedAt == nil {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, fmt.Errorf("can't find api key: %w", ErrNotFound)
+}
+
+// RevokeAPIKey revokes active api key with key.ID owned by key.UserID.
+// Returns ErrNotFound for missing, revoked or someone else's keys.
+func (repo *InMemoryRepository) RevokeAPIKey(_ context.Context, key models.APIKey, revokedAt time.Time) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	foundKey, ok := repo.apiKeys[key.ID]
+	if !ok || foundKey.UserID != key.UserID || foundKey.RevokedAt != nil {
+		return ErrNotFound
+	}
+	foundKey.RevokedAt = &revokedAt
+	repo.apiKeys[key.ID] = foundKey
+
+	return nil
+}
+
+// GetAPIKeys returns all api keys, including revoked ones, ordered by id.
+func (repo *InMemoryRepository) GetAPIKeys(_ context.Context) ([]models.APIKey, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	return sortedAPIKeys(repo.apiKeys), nil
+}
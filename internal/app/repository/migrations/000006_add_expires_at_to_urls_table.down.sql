@@ -0,0 +1,7 @@
+START TRANSACTION;
+
+DROP INDEX IF EXISTS urls_expires_at_idx;
+
+ALTER TABLE urls DROP COLUMN expires_at;
+
+COMMIT
\ No newline at end of file
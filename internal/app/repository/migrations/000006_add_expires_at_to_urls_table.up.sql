@@ -0,0 +1,7 @@
+START TRANSACTION;
+
+ALTER TABLE urls ADD COLUMN expires_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS urls_expires_at_idx ON urls (expires_at) WHERE expires_at IS NOT NULL;
+
+COMMIT
\ No newline at end of file
@@ -0,0 +1,5 @@
+START TRANSACTION;
+
+DROP TABLE IF EXISTS clicks;
+
+COMMIT
\ No newline at end of file
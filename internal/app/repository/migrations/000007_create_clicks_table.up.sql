@@ -0,0 +1,15 @@
+START TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS clicks
+(
+    id         BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    short_url  VARCHAR(200) NOT NULL,
+    clicked_at timestamptz  NOT NULL,
+    referrer   text,
+    user_agent text,
+    ip_hash    text
+);
+
+CREATE INDEX IF NOT EXISTS clicks_short_url_clicked_at_idx ON clicks (short_url, clicked_at);
+
+COMMIT
\ No newline at end of file
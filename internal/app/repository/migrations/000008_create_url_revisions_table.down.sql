@@ -0,0 +1,5 @@
+START TRANSACTION;
+
+DROP TABLE IF EXISTS url_revisions;
+
+COMMIT
\ No newline at end of file
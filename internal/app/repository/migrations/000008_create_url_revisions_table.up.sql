@@ -0,0 +1,13 @@
+START TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS url_revisions
+(
+    id         BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    short_url  VARCHAR(200) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE,
+    url        VARCHAR(200) NOT NULL,
+    changed_at timestamptz  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS url_revisions_short_url_changed_at_idx ON url_revisions (short_url, changed_at);
+
+COMMIT
\ No newline at end of file
@@ -0,0 +1,10 @@
+START TRANSACTION;
+
+ALTER TABLE urls ADD COLUMN deleted_at timestamptz;
+
+-- links deleted before the column existed get full grace period from now
+UPDATE urls SET deleted_at = now() WHERE is_deleted;
+
+CREATE INDEX IF NOT EXISTS urls_deleted_at_idx ON urls (deleted_at) WHERE is_deleted;
+
+COMMIT
\ No newline at end of file
@@ -0,0 +1,14 @@
+START TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id         VARCHAR(32) PRIMARY KEY,
+    user_token text        NOT NULL,
+    hash       CHAR(64)    NOT NULL UNIQUE,
+    created_at timestamptz NOT NULL,
+    revoked_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_token_idx ON api_keys (user_token);
+
+COMMIT
\ No newline at end of file
@@ -0,0 +1,9 @@
+START TRANSACTION;
+
+DROP INDEX IF EXISTS urls_user_token_domain_idx;
+DROP INDEX IF EXISTS urls_user_token_created_at_idx;
+
+ALTER TABLE urls DROP COLUMN domain;
+ALTER TABLE urls DROP COLUMN created_at;
+
+COMMIT
\ No newline at end of file
@@ -0,0 +1,13 @@
+START TRANSACTION;
+
+ALTER TABLE urls ADD COLUMN created_at timestamptz NOT NULL DEFAULT now();
+
+-- destination host, filled by the application, existing links are parsed here
+ALTER TABLE urls ADD COLUMN domain text NOT NULL DEFAULT '';
+UPDATE urls SET domain = coalesce(lower(substring(url from '^[^:/?#]+://(?:[^@/?#]*@)?([^:/?#]+)')), '');
+
+-- keyset pagination of user links ordered by creation time
+CREATE INDEX IF NOT EXISTS urls_user_token_created_at_idx ON urls (user_token, created_at, short_url);
+CREATE INDEX IF NOT EXISTS urls_user_token_domain_idx ON urls (user_token, domain);
+
+COMMIT
\ No newline at end of file
@@ -0,0 +1,5 @@
+START TRANSACTION;
+
+ALTER TABLE clicks DROP CONSTRAINT IF EXISTS clicks_short_url_fkey;
+
+COMMIT
\ No newline at end of file
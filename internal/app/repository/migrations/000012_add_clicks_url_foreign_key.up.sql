@@ -0,0 +1,9 @@
+START TRANSACTION;
+
+-- clicks of urls removed before the foreign key
+DELETE FROM clicks WHERE NOT EXISTS (SELECT 1 FROM urls WHERE urls.short_url = clicks.short_url);
+
+ALTER TABLE clicks
+    ADD CONSTRAINT clicks_short_url_fkey FOREIGN KEY (short_url) REFERENCES urls (short_url) ON DELETE CASCADE;
+
+COMMIT
\ No newline at end of file
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -11,31 +14,70 @@ import (
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/GTedya/shortener/internal/app/models"
 )
 
+// shortURLConstraint is the name of UNIQUE constraint on urls.short_url.
+const shortURLConstraint = "urls_short_url_key"
+
+// PoolConfig contains connection pool settings. Zero values keep pgxpool defaults.
+type PoolConfig struct {
+	MaxConns          int32         // maximum number of connections in the pool
+	MinConns          int32         // number of connections kept open even when idle
+	MaxConnLifetime   time.Duration // time after which connection is closed and replaced
+	MaxConnIdleTime   time.Duration // time after which idle connection is closed
+	HealthCheckPeriod time.Duration // period of checking idle connections health
+}
+
 type PostgresRepo struct {
-	conn *pgx.Conn // connection to the database
-	Dsn  string    // data source name for the Postgres database
+	pool *pgxpool.Pool // pool of connections to the database, safe for concurrent use
+	Dsn  string        // data source name for the Postgres database
 }
 
-// NewPgRepository creates a new Postgres connection, runs the migrations, and returns a new PostgresRepo.
-func NewPgRepository(dsn string, migrationsPath string) (*PostgresRepo, error) {
-	conn, err := pgx.Connect(context.Background(), dsn)
+// NewPgRepository creates a new Postgres connection pool, runs the migrations, and returns a new PostgresRepo.
+func NewPgRepository(dsn string, migrationsPath string, poolConfig PoolConfig) (*PostgresRepo, error) {
+	pgxConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("pgx config parsing error: %w", err)
+	}
+	applyPoolConfig(pgxConfig, poolConfig)
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), pgxConfig)
 	if err != nil {
-		return nil, fmt.Errorf("pgx connection error: %w", err)
+		return nil, fmt.Errorf("pgx pool creating error: %w", err)
 	}
 
 	if err = runMigrations(dsn, migrationsPath); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("migration running error: %w", err)
 	}
 	return &PostgresRepo{
 		Dsn:  dsn,
-		conn: conn,
+		pool: pool,
 	}, nil
 }
 
+// applyPoolConfig overrides pgxpool settings with non-zero values of poolConfig.
+func applyPoolConfig(pgxConfig *pgxpool.Config, poolConfig PoolConfig) {
+	if poolConfig.MaxConns > 0 {
+		pgxConfig.MaxConns = poolConfig.MaxConns
+	}
+	if poolConfig.MinConns > 0 {
+		pgxConfig.MinConns = poolConfig.MinConns
+	}
+	if poolConfig.MaxConnLifetime > 0 {
+		pgxConfig.MaxConnLifetime = poolConfig.MaxConnLifetime
+	}
+	if poolConfig.MaxConnIdleTime > 0 {
+		pgxConfig.MaxConnIdleTime = poolConfig.MaxConnIdleTime
+	}
+	if poolConfig.HealthCheckPeriod > 0 {
+		pgxConfig.HealthCheckPeriod = poolConfig.HealthCheckPeriod
+	}
+}
+
 // runMigrations выполняет миграции базы данных.
 func runMigrations(dsn string, migrationsPath string) error {
 	m, err := migrate.New(migrationsPath, dsn)
@@ -56,19 +98,24 @@ func runMigrations(dsn string, migrationsPath string) error {
 	return nil
 }
 
-// Save inserting a new row into the urls table.
+// Save inserting a new row into the urls table. Deleted urls keep their deletion time, so they can be imported.
 func (repo *PostgresRepo) Save(ctx context.Context, shortURL models.ShortURL) error {
-	_, err := repo.conn.Exec(
+	shortURL = withCreatedAt(shortURL)
+	_, err := repo.pool.Exec(
 		ctx,
-		"insert into urls (url, short_url, user_token) values ($1, $2, $3)",
+		`insert into urls (url, short_url, user_token, expires_at, created_at, domain, is_deleted, deleted_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)`,
 		shortURL.OriginalURL,
 		shortURL.ShortURL,
 		shortURL.CreatedByID,
+		shortURL.ExpiresAt,
+		shortURL.CreatedAt,
+		models.URLHost(shortURL.OriginalURL),
+		shortURL.IsDeleted,
+		shortURL.DeletedAt,
 	)
-
-	var pgErr *pgconn.PgError
-	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-		return ErrDuplicate
+	if dupErr := uniqueViolationError(err); dupErr != nil {
+		return dupErr
 	}
 	if err != nil {
 		return fmt.Errorf("exec error: %w", err)
@@ -77,16 +124,48 @@ func (repo *PostgresRepo) Save(ctx context.Context, shortURL models.ShortURL) er
 	return nil
 }
 
-// SaveBatch is a batch insert operation.
+// uniqueViolationError converts unique violation to ErrIDTaken or ErrDuplicate
+// depending on violated constraint. Returns nil for any other error.
+func uniqueViolationError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgerrcode.UniqueViolation {
+		return nil
+	}
+	if pgErr.ConstraintName == shortURLConstraint {
+		return ErrIDTaken
+	}
+	return ErrDuplicate
+}
+
+// SaveBatch is a batch insert operation. It copies the batch using connection acquired from the pool.
 func (repo *PostgresRepo) SaveBatch(ctx context.Context, batch []models.ShortURL) error {
-	_, err := repo.conn.CopyFrom(
+	conn, err := repo.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("connection acquiring error: %w", err)
+	}
+	defer conn.Release()
+
+	_, err = conn.CopyFrom(
 		ctx,
 		pgx.Identifier{"urls"},
-		[]string{"url", "short_url", "user_token"},
+		[]string{"url", "short_url", "user_token", "expires_at", "created_at", "domain", "is_deleted", "deleted_at"},
 		pgx.CopyFromSlice(len(batch), func(i int) ([]interface{}, error) {
-			return []interface{}{batch[i].OriginalURL, batch[i].ShortURL, batch[i].CreatedByID}, nil
+			shortURL := withCreatedAt(batch[i])
+			return []interface{}{
+				shortURL.OriginalURL,
+				shortURL.ShortURL,
+				shortURL.CreatedByID,
+				shortURL.ExpiresAt,
+				shortURL.CreatedAt,
+				models.URLHost(shortURL.OriginalURL),
+				shortURL.IsDeleted,
+				shortURL.DeletedAt,
+			}, nil
 		}),
 	)
+	if dupErr := uniqueViolationError(err); dupErr != nil {
+		return dupErr
+	}
 	if err != nil {
 		return fmt.Errorf("copy error: %w", err)
 	}
@@ -96,11 +175,15 @@ func (repo *PostgresRepo) SaveBatch(ctx context.Context, batch []models.ShortURL
 // GetByID gets url by id.
 func (repo *PostgresRepo) GetByID(ctx context.Context, id string) (models.ShortURL, error) {
 	var model models.ShortURL
-	err := repo.conn.QueryRow(
+	err := repo.pool.QueryRow(
 		ctx,
-		"select url, short_url, user_token, is_deleted from urls where short_url=$1",
+		"select url, short_url, user_token, is_deleted, expires_at, deleted_at, created_at from urls where short_url=$1",
 		id,
-	).Scan(&model.OriginalURL, &model.ShortURL, &model.CreatedByID, &model.IsDeleted)
+	).Scan(&model.OriginalURL, &model.ShortURL, &model.CreatedByID, &model.IsDeleted, &model.ExpiresAt, &model.DeletedAt,
+		&model.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.ShortURL{}, fmt.Errorf("query error: %w", ErrNotFound)
+	}
 	if err != nil {
 		return models.ShortURL{}, fmt.Errorf("query error: %w", err)
 	}
@@ -110,25 +193,32 @@ func (repo *PostgresRepo) GetByID(ctx context.Context, id string) (models.ShortU
 // ShortenByURL gets id by url.
 func (repo *PostgresRepo) ShortenByURL(ctx context.Context, url string) (models.ShortURL, error) {
 	var model models.ShortURL
-	err := repo.conn.QueryRow(
+	err := repo.pool.QueryRow(
 		ctx,
-		"select url, short_url, user_token, is_deleted from urls where url=$1",
+		"select url, short_url, user_token, is_deleted, expires_at, deleted_at, created_at from urls where url=$1",
 		url,
-	).Scan(&model.OriginalURL, &model.ShortURL, &model.CreatedByID, &model.IsDeleted)
+	).Scan(&model.OriginalURL, &model.ShortURL, &model.CreatedByID, &model.IsDeleted, &model.ExpiresAt, &model.DeletedAt,
+		&model.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.ShortURL{}, fmt.Errorf("query error: %w", ErrNotFound)
+	}
 	if err != nil {
 		return models.ShortURL{}, fmt.Errorf("query error: %w", err)
 	}
 	return model, nil
 }
 
-// GetUsersUrls returns all the urls created by a user.
-func (repo *PostgresRepo) GetUsersUrls(ctx context.Context, userID string) ([]models.ShortURL, error) {
+// GetUsersUrls returns the page of urls created by a user.
+// Filters and the cursor are pushed down to the query, which uses the (user_token, created_at, short_url) index.
+func (repo *PostgresRepo) GetUsersUrls(
+	ctx context.Context,
+	userID string,
+	query models.URLQuery,
+) ([]models.ShortURL, error) {
 	var URLs []models.ShortURL
 
-	rows, err := repo.conn.Query(
-		ctx,
-		"select url, short_url, is_deleted from urls where user_token=$1",
-		userID)
+	sql, args := usersUrlsQuery(userID, query)
+	rows, err := repo.pool.Query(ctx, sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf("getting user urls error: %w", err)
 	}
@@ -136,8 +226,10 @@ func (repo *PostgresRepo) GetUsersUrls(ctx context.Context, userID string) ([]mo
 	defer rows.Close()
 
 	for rows.Next() {
-		model := models.ShortURL{}
-		if err = rows.Scan(&model.OriginalURL, &model.ShortURL, &model.IsDeleted); err != nil {
+		model := models.ShortURL{CreatedByID: userID}
+		err = rows.Scan(&model.OriginalURL, &model.ShortURL, &model.IsDeleted, &model.ExpiresAt, &model.DeletedAt,
+			&model.CreatedAt)
+		if err != nil {
 			return nil, fmt.Errorf("scan row error: %w", err)
 		}
 		URLs = append(URLs, model)
@@ -150,18 +242,86 @@ func (repo *PostgresRepo) GetUsersUrls(ctx context.Context, userID string) ([]mo
 	return URLs, nil
 }
 
-// Close closes the connection to the database.
-func (repo *PostgresRepo) Close(ctx context.Context) error {
-	err := repo.conn.Close(ctx)
+// usersUrlsQuery builds the query of GetUsersUrls and its arguments.
+func usersUrlsQuery(userID string, query models.URLQuery) (string, []any) {
+	args := []any{userID}
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	var sql strings.Builder
+	sql.WriteString("select url, short_url, is_deleted, expires_at, deleted_at, created_at from urls where user_token=$1")
+	if query.Deleted != nil {
+		sql.WriteString(" and is_deleted=" + arg(*query.Deleted))
+	}
+	if query.Domain != "" {
+		sql.WriteString(" and domain=" + arg(strings.ToLower(query.Domain)))
+	}
+	if query.Contains != "" {
+		sql.WriteString(" and strpos(lower(url), " + arg(strings.ToLower(query.Contains)) + ") > 0")
+	}
+
+	order, compare := "asc", ">"
+	if query.Desc {
+		order, compare = "desc", "<"
+	}
+	if query.After != nil {
+		sql.WriteString(" and (created_at, short_url) " + compare + " (" + arg(query.After.CreatedAt) + ", " +
+			arg(query.After.ID) + ")")
+	}
+	sql.WriteString(" order by created_at " + order + ", short_url " + order)
+	if query.Limit > 0 {
+		sql.WriteString(" limit " + arg(query.Limit))
+	}
+	return sql.String(), args
+}
+
+// GetUrls returns up to limit urls, including deleted ones, with short ids after afterID ordered by short id.
+// Limit 0 means no limit.
+func (repo *PostgresRepo) GetUrls(ctx context.Context, afterID string, limit int) ([]models.ShortURL, error) {
+	var limitArg any = limit
+	if limit <= 0 {
+		limitArg = nil // limit null means no limit
+	}
+	rows, err := repo.pool.Query(
+		ctx,
+		`select url, short_url, user_token, is_deleted, expires_at, deleted_at, created_at from urls
+		where short_url > $1 order by short_url limit $2`,
+		afterID,
+		limitArg,
+	)
 	if err != nil {
-		return fmt.Errorf("close error: %w", err)
+		return nil, fmt.Errorf("getting urls error: %w", err)
+	}
+	defer rows.Close()
+
+	var URLs []models.ShortURL
+	for rows.Next() {
+		var model models.ShortURL
+		err = rows.Scan(&model.OriginalURL, &model.ShortURL, &model.CreatedByID, &model.IsDeleted, &model.ExpiresAt,
+			&model.DeletedAt, &model.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan row error: %w", err)
+		}
+		URLs = append(URLs, model)
 	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error :%w", rows.Err())
+	}
+
+	return URLs, nil
+}
+
+// Close closes all connections of the pool. It waits for acquired connections to be released.
+func (repo *PostgresRepo) Close(_ context.Context) error {
+	repo.pool.Close()
 	return nil
 }
 
 // Check checks if the database is up and running.
 func (repo *PostgresRepo) Check(ctx context.Context) error {
-	err := repo.conn.Ping(ctx)
+	err := repo.pool.Ping(ctx)
 	if err != nil {
 		return fmt.Errorf("ping error: %w", err)
 	}
@@ -169,8 +329,16 @@ func (repo *PostgresRepo) Check(ctx context.Context) error {
 }
 
 // DeleteUrls удаляет несколько URL из базы данных, используя указанный токен пользователя.
+// Время удаления сохраняется в deleted_at для последующего восстановления или окончательного удаления.
+// Транзакция выполняется на соединении, полученном из пула.
 func (repo *PostgresRepo) DeleteUrls(ctx context.Context, urls []models.ShortURL) (err error) {
-	tx, err := repo.conn.Begin(ctx)
+	conn, err := repo.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("connection acquiring error: %w", err)
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -190,7 +358,7 @@ func (repo *PostgresRepo) DeleteUrls(ctx context.Context, urls []models.ShortURL
 	b := &pgx.Batch{}
 
 	for _, url := range urls {
-		sqlStatement := "UPDATE urls SET is_deleted = true WHERE short_url=$1 AND user_token=$2"
+		sqlStatement := "UPDATE urls SET is_deleted = true, deleted_at = now() WHERE short_url=$1 AND user_token=$2 AND NOT is_deleted"
 		b.Queue(sqlStatement, url.ShortURL, url.CreatedByID)
 		if b.Len() >= DeleteBuffer {
 			batchResults := tx.SendBatch(ctx, b)
@@ -211,10 +379,12 @@ func (repo *PostgresRepo) DeleteUrls(ctx context.Context, urls []models.ShortURL
 	return nil
 }
 
+// GetUsersAndUrlsCount returns number of users that created urls and number of urls, deleted urls are counted
+// until they are purged.
 func (repo *PostgresRepo) GetUsersAndUrlsCount(ctx context.Context) (int, int, error) {
 	var urlsCount int
 	var usersCount int
-	err := repo.conn.QueryRow(
+	err := repo.pool.QueryRow(
 		ctx,
 		"select count('*'), count( distinct user_token) from urls",
 	).Scan(&urlsCount, &usersCount)
@@ -223,3 +393,361 @@ func (repo *PostgresRepo) GetUsersAndUrlsCount(ctx context.Context) (int, int, e
 	}
 	return usersCount, urlsCount, nil
 }
+
+// DeleteExpired deletes urls that have expired by now, their revisions and clicks are deleted by cascade.
+// Returns number of deleted rows.
+func (repo *PostgresRepo) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	tag, err := repo.pool.Exec(ctx, "delete from urls where expires_at <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("exec error: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// DeleteExpiredURLs deletes the urls with given ids that have expired by now, their revisions and clicks
+// are deleted by cascade. Other urls are kept. Returns number of deleted rows.
+func (repo *PostgresRepo) DeleteExpiredURLs(ctx context.Context, ids []string, now time.Time) (int, error) {
+	tag, err := repo.pool.Exec(ctx, "delete from urls where short_url = any($1) and expires_at <= $2", ids, now)
+	if err != nil {
+		return 0, fmt.Errorf("exec error: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// SaveClicks inserts clicks into the clicks table. Clicks of urls removed before they are saved are dropped,
+// clicks of removed urls are deleted by cascade.
+func (repo *PostgresRepo) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	ids := make([]string, 0, len(clicks))
+	times := make([]time.Time, 0, len(clicks))
+	referrers := make([]string, 0, len(clicks))
+	userAgents := make([]string, 0, len(clicks))
+	ipHashes := make([]string, 0, len(clicks))
+	for _, c := range clicks {
+		ids = append(ids, c.ShortURL)
+		times = append(times, c.At)
+		referrers = append(referrers, c.Referrer)
+		userAgents = append(userAgents, c.UserAgent)
+		ipHashes = append(ipHashes, c.IPHash)
+	}
+
+	_, err := repo.pool.Exec(
+		ctx,
+		`insert into clicks (short_url, clicked_at, referrer, user_agent, ip_hash)
+		select c.short_url, c.clicked_at, c.referrer, c.user_agent, c.ip_hash
+		from unnest($1::text[], $2::timestamptz[], $3::text[], $4::text[], $5::text[])
+			as c(short_url, clicked_at, referrer, user_agent, ip_hash)
+		where exists (select 1 from urls where urls.short_url = c.short_url)`,
+		ids, times, referrers, userAgents, ipHashes,
+	)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// GetClickStats returns click analytics of the url with given id.
+func (repo *PostgresRepo) GetClickStats(ctx context.Context, id string) (models.LinkStats, error) {
+	var stats models.LinkStats
+	err := repo.pool.QueryRow(
+		ctx,
+		"select count(*), count(distinct ip_hash) from clicks where short_url=$1",
+		id,
+	).Scan(&stats.TotalClicks, &stats.UniqueVisitors)
+	if err != nil {
+		return models.LinkStats{}, fmt.Errorf("query error: %w", err)
+	}
+
+	rows, err := repo.pool.Query(
+		ctx,
+		`select (clicked_at at time zone 'UTC')::date as day, count(*) from clicks
+		where short_url=$1 group by day order by day`,
+		id,
+	)
+	if err != nil {
+		return models.LinkStats{}, fmt.Errorf("getting daily clicks error: %w", err)
+	}
+	defer rows.Close()
+
+	stats.Daily = make([]models.DailyClicks, 0)
+	for rows.Next() {
+		var day time.Time
+		var daily models.DailyClicks
+		if err = rows.Scan(&day, &daily.Clicks); err != nil {
+			return models.LinkStats{}, fmt.Errorf("scan row error: %w", err)
+		}
+		daily.Date = day.Format(dateLayout)
+		stats.Daily = append(stats.Daily, daily)
+	}
+	if rows.Err() != nil {
+		return models.LinkStats{}, fmt.Errorf("rows error :%w", rows.Err())
+	}
+
+	return stats, nil
+}
+
+// GetClicks returns clicks of the url with given id ordered by time.
+func (repo *PostgresRepo) GetClicks(ctx context.Context, id string) ([]models.Click, error) {
+	rows, err := repo.pool.Query(
+		ctx,
+		`select short_url, clicked_at, coalesce(referrer, ''), coalesce(user_agent, ''), coalesce(ip_hash, '')
+		from clicks where short_url=$1 order by clicked_at, id`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting clicks error: %w", err)
+	}
+	defer rows.Close()
+
+	clicks := make([]models.Click, 0)
+	for rows.Next() {
+		var click models.Click
+		if err = rows.Scan(&click.ShortURL, &click.At, &click.Referrer, &click.UserAgent, &click.IPHash); err != nil {
+			return nil, fmt.Errorf("scan row error: %w", err)
+		}
+		clicks = append(clicks, click)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error :%w", rows.Err())
+	}
+
+	return clicks, nil
+}
+
+// UpdateURL changes destination of the url owned by shortURL.CreatedByID.
+// The previous destination is saved to url_revisions in the same transaction.
+func (repo *PostgresRepo) UpdateURL(ctx context.Context, shortURL models.ShortURL, changedAt time.Time) (err error) {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				err = fmt.Errorf("rollback failed: %w, original error: %w", rollbackErr, err)
+			}
+		} else {
+			if commitErr := tx.Commit(ctx); commitErr != nil {
+				err = fmt.Errorf("commit failed: %w", commitErr)
+			}
+		}
+	}()
+
+	var previous string
+	err = tx.QueryRow(
+		ctx,
+		"select url from urls where short_url=$1 and user_token=$2 and not is_deleted for update",
+		shortURL.ShortURL,
+		shortURL.CreatedByID,
+	).Scan(&previous)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("query error: %w", err)
+	}
+	if previous == shortURL.OriginalURL {
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, "update urls set url=$1, domain=$2 where short_url=$3",
+		shortURL.OriginalURL, models.URLHost(shortURL.OriginalURL), shortURL.ShortURL)
+	if dupErr := uniqueViolationError(err); dupErr != nil {
+		return dupErr
+	}
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		"insert into url_revisions (short_url, url, changed_at) values ($1, $2, $3)",
+		shortURL.ShortURL,
+		previous,
+		changedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+
+	return nil
+}
+
+// GetRevisions returns previous destinations of the url with given id, oldest first.
+func (repo *PostgresRepo) GetRevisions(ctx context.Context, id string) ([]models.Revision, error) {
+	rows, err := repo.pool.Query(
+		ctx,
+		"select short_url, url, changed_at from url_revisions where short_url=$1 order by changed_at, id",
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getting revisions error: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := make([]models.Revision, 0)
+	for rows.Next() {
+		var revision models.Revision
+		if err = rows.Scan(&revision.ShortURL, &revision.OriginalURL, &revision.ChangedAt); err != nil {
+			return nil, fmt.Errorf("scan row error: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error :%w", rows.Err())
+	}
+
+	return revisions, nil
+}
+
+// SaveRevisions inserts revisions into the url_revisions table.
+// Revisions of urls removed before they are saved are dropped.
+func (repo *PostgresRepo) SaveRevisions(ctx context.Context, revisions []models.Revision) error {
+	ids := make([]string, 0, len(revisions))
+	originals := make([]string, 0, len(revisions))
+	times := make([]time.Time, 0, len(revisions))
+	for _, r := range revisions {
+		ids = append(ids, r.ShortURL)
+		originals = append(originals, r.OriginalURL)
+		times = append(times, r.ChangedAt)
+	}
+
+	_, err := repo.pool.Exec(
+		ctx,
+		`insert into url_revisions (short_url, url, changed_at)
+		select r.short_url, r.url, r.changed_at
+		from unnest($1::text[], $2::text[], $3::timestamptz[]) with ordinality as r(short_url, url, changed_at, n)
+		where exists (select 1 from urls where urls.short_url = r.short_url)
+		order by r.n`,
+		ids, originals, times,
+	)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// RestoreUrls undeletes given urls owned by user_token that were deleted not earlier than deletedAfter.
+// Returns ids of restored urls.
+func (repo *PostgresRepo) RestoreUrls(
+	ctx context.Context,
+	urls []models.ShortURL,
+	deletedAfter time.Time,
+) ([]string, error) {
+	b := &pgx.Batch{}
+	for _, url := range urls {
+		b.Queue(
+			`UPDATE urls SET is_deleted = false, deleted_at = NULL
+			WHERE short_url=$1 AND user_token=$2 AND is_deleted AND deleted_at >= $3`,
+			url.ShortURL, url.CreatedByID, deletedAfter,
+		)
+	}
+
+	batchResults := repo.pool.SendBatch(ctx, b)
+	restored := make([]string, 0, len(urls))
+	for _, url := range urls {
+		tag, err := batchResults.Exec()
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("exec error: %w", err), batchResults.Close())
+		}
+		if tag.RowsAffected() > 0 {
+			restored = append(restored, url.ShortURL)
+		}
+	}
+	if err := batchResults.Close(); err != nil {
+		return nil, fmt.Errorf("batch closing error: %w", err)
+	}
+
+	return restored, nil
+}
+
+// PurgeDeleted permanently deletes urls deleted before deletedBefore, their revisions and clicks
+// are deleted by cascade.
+// Returns number of deleted rows.
+func (repo *PostgresRepo) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error) {
+	tag, err := repo.pool.Exec(ctx, "delete from urls where is_deleted and deleted_at < $1", deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("exec error: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// SaveAPIKey inserts a new row into the api_keys table. Returns ErrIDTaken if the key id or hash is already used.
+func (repo *PostgresRepo) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	_, err := repo.pool.Exec(
+		ctx,
+		"insert into api_keys (id, user_token, hash, created_at, revoked_at) values ($1, $2, $3, $4, $5)",
+		key.ID,
+		key.UserID,
+		key.Hash,
+		key.CreatedAt,
+		key.RevokedAt,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return ErrIDTaken
+	}
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKeys returns all api keys, including revoked ones, ordered by id.
+func (repo *PostgresRepo) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := repo.pool.Query(ctx, "select id, user_token, hash, created_at, revoked_at from api_keys order by id")
+	if err != nil {
+		return nil, fmt.Errorf("getting api keys error: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		var key models.APIKey
+		if err = rows.Scan(&key.ID, &key.UserID, &key.Hash, &key.CreatedAt, &key.RevokedAt); err != nil {
+			return nil, fmt.Errorf("scan row error: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows error :%w", rows.Err())
+	}
+
+	return keys, nil
+}
+
+// GetAPIKey returns active api key with given hash.
+func (repo *PostgresRepo) GetAPIKey(ctx context.Context, hash string) (models.APIKey, error) {
+	var key models.APIKey
+	err := repo.pool.QueryRow(
+		ctx,
+		"select id, user_token, hash, created_at, revoked_at from api_keys where hash=$1 and revoked_at is null",
+		hash,
+	).Scan(&key.ID, &key.UserID, &key.Hash, &key.CreatedAt, &key.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.APIKey{}, fmt.Errorf("query error: %w", ErrNotFound)
+	}
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("query error: %w", err)
+	}
+	return key, nil
+}
+
+// RevokeAPIKey revokes active api key with key.ID owned by key.UserID.
+// Returns ErrNotFound for missing, revoked or someone else's keys.
+func (repo *PostgresRepo) RevokeAPIKey(ctx context.Context, key models.APIKey, revokedAt time.Time) error {
+	tag, err := repo.pool.Exec(
+		ctx,
+		"update api_keys set revoked_at = $3 where id=$1 and user_token=$2 and revoked_at is null",
+		key.ID,
+		key.UserID,
+		revokedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GTedya/shortener/config"
+)
+
+func TestPoolConfigOf(t *testing.T) {
+	// keys of the JSON config match names of the flags
+	var cfg config.Config
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"db_max_conns": 20,
+		"db_min_conns": 2,
+		"db_conn_lifetime": "1h",
+		"db_conn_idle_time": "10m",
+		"db_health_check": "30s"
+	}`), &cfg))
+
+	assert.Equal(t, PoolConfig{
+		MaxConns:          20,
+		MinConns:          2,
+		MaxConnLifetime:   time.Hour,
+		MaxConnIdleTime:   10 * time.Minute,
+		HealthCheckPeriod: 30 * time.Second,
+	}, poolConfigOf(cfg))
+}
+
+func TestApplyPoolConfig(t *testing.T) {
+	defaults, err := pgxpool.ParseConfig("postgres://localhost:5432/shortener")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		config PoolConfig
+		want   func(c *pgxpool.Config)
+	}{
+		{
+			name:   "zero values keep defaults",
+			config: PoolConfig{},
+			want:   func(*pgxpool.Config) {},
+		},
+		{
+			name: "all values",
+			config: PoolConfig{
+				MaxConns:          20,
+				MinConns:          2,
+				MaxConnLifetime:   time.Minute,
+				MaxConnIdleTime:   time.Second,
+				HealthCheckPeriod: 5 * time.Second,
+			},
+			want: func(c *pgxpool.Config) {
+				c.MaxConns = 20
+				c.MinConns = 2
+				c.MaxConnLifetime = time.Minute
+				c.MaxConnIdleTime = time.Second
+				c.HealthCheckPeriod = 5 * time.Second
+			},
+		},
+		{
+			name:   "some values",
+			config: PoolConfig{MaxConns: 5, HealthCheckPeriod: time.Second},
+			want: func(c *pgxpool.Config) {
+				c.MaxConns = 5
+				c.HealthCheckPeriod = time.Second
+			},
+		},
+		{
+			name:   "negative values keep defaults",
+			config: PoolConfig{MaxConns: -1, MinConns: -1, MaxConnLifetime: -time.Second},
+			want:   func(*pgxpool.Config) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, want := defaults.Copy(), defaults.Copy()
+			tt.want(want)
+
+			applyPoolConfig(got, tt.config)
+			assert.Equal(t, want.MaxConns, got.MaxConns)
+			assert.Equal(t, want.MinConns, got.MinConns)
+			assert.Equal(t, want.MaxConnLifetime, got.MaxConnLifetime)
+			assert.Equal(t, want.MaxConnIdleTime, got.MaxConnIdleTime)
+			assert.Equal(t, want.HealthCheckPeriod, got.HealthCheckPeriod)
+		})
+	}
+}
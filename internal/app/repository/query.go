@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// withCreatedAt sets creation time of the url to now unless it is already set.
+// The time is truncated to microseconds, the precision of Postgres, so all storages return the same time.
+func withCreatedAt(shortURL models.ShortURL) models.ShortURL {
+	if shortURL.CreatedAt.IsZero() {
+		shortURL.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	}
+	return shortURL
+}
+
+// urlsAfter returns up to limit urls with short ids after afterID ordered by short id.
+// It is used by repositories that keep urls unordered.
+func urlsAfter(urls map[string]models.ShortURL, afterID string, limit int) []models.ShortURL {
+	var result []models.ShortURL
+	for id, url := range urls {
+		if id > afterID {
+			result = append(result, url)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ShortURL < result[j].ShortURL
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// sortedAPIKeys returns the api keys ordered by id.
+// It is used by repositories that keep api keys unordered.
+func sortedAPIKeys(keys map[string]models.APIKey) []models.APIKey {
+	result := make([]models.APIKey, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, key)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// pageOf returns the page of urls selected by query from urls already filtered by query.Matches.
+// Urls are ordered by creation time and short id, like Postgres orders them.
+func pageOf(urls []models.ShortURL, query models.URLQuery) []models.ShortURL {
+	sort.Slice(urls, func(i, j int) bool {
+		if query.Desc {
+			i, j = j, i
+		}
+		return models.CursorOf(urls[i]).Less(models.CursorOf(urls[j]))
+	})
+
+	if query.After != nil {
+		start := sort.Search(len(urls), func(i int) bool {
+			if query.Desc {
+				return models.CursorOf(urls[i]).Less(*query.After)
+			}
+			return query.After.Less(models.CursorOf(urls[i]))
+		})
+		urls = urls[start:]
+	}
+
+	if query.Limit > 0 && len(urls) > query.Limit {
+		urls = urls[:query.Limit]
+	}
+	return urls
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/GTedya/shortener/config"
 	"github.com/GTedya/shortener/internal/app/models"
@@ -13,34 +15,104 @@ const DeleteBuffer = 10
 // ErrDuplicate возвращается при попытке сохранить URL, который уже существует в базе данных.
 var ErrDuplicate = errors.New("this url already exists")
 
+// ErrIDTaken возвращается при попытке сохранить URL с уже занятым коротким идентификатором.
+var ErrIDTaken = errors.New("this short id is already taken")
+
+// ErrNotFound возвращается, если URL не найден, удален или принадлежит другому пользователю.
+var ErrNotFound = errors.New("url not found")
+
 // Repository saves and retrieves data from storage.
 type Repository interface {
 	Save(ctx context.Context, shortURL models.ShortURL) error
 	GetByID(ctx context.Context, id string) (models.ShortURL, error)
 	ShortenByURL(ctx context.Context, url string) (models.ShortURL, error)
-	GetUsersUrls(ctx context.Context, userID string) ([]models.ShortURL, error)
+	GetUsersUrls(ctx context.Context, userID string, query models.URLQuery) ([]models.ShortURL, error)
 	Close(_ context.Context) error
 	Check(ctx context.Context) error
 	SaveBatch(ctx context.Context, batch []models.ShortURL) error
 	DeleteUrls(ctx context.Context, urls []models.ShortURL) error
 	GetUsersAndUrlsCount(ctx context.Context) (int, int, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+	DeleteExpiredURLs(ctx context.Context, ids []string, now time.Time) (int, error)
+	SaveClicks(ctx context.Context, clicks []models.Click) error
+	GetClickStats(ctx context.Context, id string) (models.LinkStats, error)
+	UpdateURL(ctx context.Context, shortURL models.ShortURL, changedAt time.Time) error
+	GetRevisions(ctx context.Context, id string) ([]models.Revision, error)
+	RestoreUrls(ctx context.Context, urls []models.ShortURL, deletedAfter time.Time) ([]string, error)
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error)
+	SaveAPIKey(ctx context.Context, key models.APIKey) error
+	GetAPIKey(ctx context.Context, hash string) (models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, key models.APIKey, revokedAt time.Time) error
+	GetUrls(ctx context.Context, afterID string, limit int) ([]models.ShortURL, error)
+	GetClicks(ctx context.Context, id string) ([]models.Click, error)
+	SaveRevisions(ctx context.Context, revisions []models.Revision) error
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
 }
 
+// Хранилища, которые можно выбрать в config.Config.Storage.
+const (
+	StoragePostgres = "postgres"
+	StorageFile     = "file"
+	StorageBolt     = "bolt"
+	StorageMemory   = "memory"
+)
+
+// ErrUnknownStorage возвращается GetRepo для неподдерживаемого config.Config.Storage.
+var ErrUnknownStorage = errors.New("unknown storage")
+
 func GetRepo(cfg config.Config) Repository {
-	if cfg.DatabaseDSN != "" {
-		repo, err := NewPgRepository(cfg.DatabaseDSN, cfg.MigrationPath)
+	switch storageOf(cfg) {
+	case StoragePostgres:
+		repo, err := NewPgRepository(cfg.DatabaseDSN, cfg.MigrationPath, poolConfigOf(cfg))
 		if err != nil {
 			panic(err)
 		}
 		return repo
-	}
-	if cfg.FileStoragePath != "" {
-		repo, err := NewFileRepository(cfg.FileStoragePath)
+	case StorageFile:
+		repo, err := NewFileRepository(cfg.FileStoragePath, FileConfig{
+			Sync:            SyncPolicy(cfg.FileSync),
+			SyncInterval:    cfg.FileSyncInterval.Duration,
+			CompactInterval: cfg.FileCompact.Duration,
+		})
 		if err != nil {
 			panic(err)
 		}
 		return repo
+	case StorageBolt:
+		repo, err := NewBoltRepository(cfg.BoltPath)
+		if err != nil {
+			panic(err)
+		}
+		return repo
+	case StorageMemory:
+		return NewInMemoryRepository()
+	default:
+		panic(fmt.Errorf("%w: %q", ErrUnknownStorage, cfg.Storage))
 	}
+}
 
-	return NewInMemoryRepository()
+// poolConfigOf возвращает настройки пула соединений с базой данных из конфигурации.
+func poolConfigOf(cfg config.Config) PoolConfig {
+	return PoolConfig{
+		MaxConns:          int32(cfg.DBMaxConns),
+		MinConns:          int32(cfg.DBMinConns),
+		MaxConnLifetime:   cfg.DBConnLifetime.Duration,
+		MaxConnIdleTime:   cfg.DBConnIdleTime.Duration,
+		HealthCheckPeriod: cfg.DBHealthCheck.Duration,
+	}
+}
+
+// storageOf возвращает выбранное хранилище. Если оно не задано, используется база данных,
+// если задан ее DSN, затем файловое хранилище, если задан путь к нему, иначе хранилище в памяти.
+func storageOf(cfg config.Config) string {
+	switch {
+	case cfg.Storage != "":
+		return cfg.Storage
+	case cfg.DatabaseDSN != "":
+		return StoragePostgres
+	case cfg.FileStoragePath != "":
+		return StorageFile
+	default:
+		return StorageMemory
+	}
 }
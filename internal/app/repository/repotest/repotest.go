@@ -0,0 +1,732 @@
+// Package repotest provides the conformance suite of repository.Repository implementations.
+//
+// Every storage must behave like PostgresRepo: short ids and original urls are unique, batches are saved
+// atomically, deleted urls are kept (and keep their original urls) until they are purged,
+// revisions and clicks are removed together with their urls.
+package repotest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+// Factory creates an empty repository for a single test. The suite closes it when the test ends.
+type Factory func(t *testing.T) repository.Repository
+
+// conformanceCase is a single test of the suite.
+type conformanceCase struct {
+	name string
+	test func(t *testing.T, repo repository.Repository)
+}
+
+// Run runs the conformance suite against repositories created by newRepo.
+// Tests are run sequentially, so repositories may share a database cleared by newRepo.
+func Run(t *testing.T, newRepo Factory) {
+	t.Helper()
+
+	cases := []conformanceCase{
+		{name: "save and get", test: testSave},
+		{name: "unique short id", test: testUniqueID},
+		{name: "unique original url", test: testUniqueURL},
+		{name: "save batch", test: testSaveBatch},
+		{name: "user urls", test: testUsersUrls},
+		{name: "soft delete", test: testDelete},
+		{name: "count", test: testCount},
+		{name: "update", test: testUpdate},
+		{name: "restore", test: testRestore},
+		{name: "purge deleted", test: testPurge},
+		{name: "delete expired", test: testExpired},
+		{name: "delete expired urls", test: testExpiredURLs},
+		{name: "clicks", test: testClicks},
+		{name: "clicks of removed urls", test: testRemovedClicks},
+		{name: "saved revisions", test: testSaveRevisions},
+		{name: "api keys", test: testAPIKeys},
+		{name: "all api keys", test: testAllAPIKeys},
+		{name: "all urls", test: testAllUrls},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newRepo(t)
+			defer func() {
+				assert.NoError(t, repo.Close(context.Background()))
+			}()
+
+			tc.test(t, repo)
+		})
+	}
+}
+
+// created is creation time of urls saved by the suite, in microseconds like Postgres stores it.
+var created = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// saveURL saves the url created by the user.
+func saveURL(t *testing.T, repo repository.Repository, id, original, userID string) {
+	t.Helper()
+
+	require.NoError(t, repo.Save(context.Background(), models.ShortURL{
+		ShortURL:    id,
+		OriginalURL: original,
+		CreatedByID: userID,
+		CreatedAt:   created,
+	}))
+}
+
+// getURL returns the url with given id.
+func getURL(t *testing.T, repo repository.Repository, id string) models.ShortURL {
+	t.Helper()
+
+	url, err := repo.GetByID(context.Background(), id)
+	require.NoError(t, err)
+	return url
+}
+
+// ids returns short ids of the urls in their order.
+func ids(urls []models.ShortURL) []string {
+	result := make([]string, 0, len(urls))
+	for _, url := range urls {
+		result = append(result, url.ShortURL)
+	}
+	return result
+}
+
+func testSave(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	expires := created.Add(time.Hour)
+	require.NoError(t, repo.Save(ctx, models.ShortURL{
+		ShortURL:    "a",
+		OriginalURL: "https://a.example",
+		CreatedByID: "owner",
+		CreatedAt:   created,
+		ExpiresAt:   &expires,
+	}))
+	require.NoError(t, repo.Save(ctx, models.ShortURL{ShortURL: "b", OriginalURL: "https://b.example"}))
+
+	url := getURL(t, repo, "a")
+	assert.Equal(t, "https://a.example", url.OriginalURL)
+	assert.Equal(t, "owner", url.CreatedByID)
+	assert.False(t, url.IsDeleted)
+	assert.Nil(t, url.DeletedAt)
+	assert.True(t, created.Equal(url.CreatedAt), "created at %s", url.CreatedAt)
+	require.NotNil(t, url.ExpiresAt)
+	assert.True(t, expires.Equal(*url.ExpiresAt), "expires at %s", url.ExpiresAt)
+
+	// creation time is set by the storage if it is missing
+	assert.WithinDuration(t, time.Now(), getURL(t, repo, "b").CreatedAt, time.Minute)
+
+	url, err := repo.ShortenByURL(ctx, "https://a.example")
+	require.NoError(t, err)
+	assert.Equal(t, "a", url.ShortURL)
+
+	_, err = repo.GetByID(ctx, "missing")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.ShortenByURL(ctx, "https://missing.example")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.NoError(t, repo.Check(ctx))
+}
+
+func testUniqueID(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	saveURL(t, repo, "a", "https://a.example", "owner")
+
+	err := repo.Save(ctx, models.ShortURL{ShortURL: "a", OriginalURL: "https://b.example", CreatedByID: "owner"})
+	assert.ErrorIs(t, err, repository.ErrIDTaken)
+	assert.Equal(t, "https://a.example", getURL(t, repo, "a").OriginalURL)
+}
+
+func testUniqueURL(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	saveURL(t, repo, "a", "https://a.example", "owner")
+	saveURL(t, repo, "b", "https://b.example", "owner")
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "b", CreatedByID: "owner"}}))
+
+	tests := []struct {
+		name     string
+		original string
+	}{
+		{name: "shortened url", original: "https://a.example"},
+		{name: "deleted url", original: "https://b.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.Save(ctx, models.ShortURL{ShortURL: "c", OriginalURL: tt.original, CreatedByID: "other"})
+			assert.ErrorIs(t, err, repository.ErrDuplicate)
+			_, err = repo.GetByID(ctx, "c")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		})
+	}
+}
+
+func testSaveBatch(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	saveURL(t, repo, "a", "https://a.example", "owner")
+
+	tests := []struct {
+		name  string
+		batch []models.ShortURL
+		err   error
+	}{
+		{
+			name: "saved short id",
+			batch: []models.ShortURL{
+				{ShortURL: "b", OriginalURL: "https://b.example"},
+				{ShortURL: "a", OriginalURL: "https://c.example"},
+			},
+			err: repository.ErrIDTaken,
+		},
+		{
+			name: "saved original url",
+			batch: []models.ShortURL{
+				{ShortURL: "b", OriginalURL: "https://b.example"},
+				{ShortURL: "c", OriginalURL: "https://a.example"},
+			},
+			err: repository.ErrDuplicate,
+		},
+		{
+			name: "short id repeated in batch",
+			batch: []models.ShortURL{
+				{ShortURL: "b", OriginalURL: "https://b.example"},
+				{ShortURL: "b", OriginalURL: "https://c.example"},
+			},
+			err: repository.ErrIDTaken,
+		},
+		{
+			name: "original url repeated in batch",
+			batch: []models.ShortURL{
+				{ShortURL: "b", OriginalURL: "https://b.example"},
+				{ShortURL: "c", OriginalURL: "https://b.example"},
+			},
+			err: repository.ErrDuplicate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, repo.SaveBatch(ctx, tt.batch), tt.err)
+
+			// nothing is saved from a failed batch
+			_, err := repo.GetByID(ctx, "b")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		})
+	}
+
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "b", OriginalURL: "https://b.example", CreatedByID: "owner"},
+		{ShortURL: "c", OriginalURL: "https://c.example", CreatedByID: "owner"},
+	}))
+	assert.Equal(t, "https://b.example", getURL(t, repo, "b").OriginalURL)
+	assert.Equal(t, "https://c.example", getURL(t, repo, "c").OriginalURL)
+}
+
+func testUsersUrls(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "c", OriginalURL: "https://example.com/C", CreatedByID: "owner", CreatedAt: created.Add(time.Hour)},
+		{ShortURL: "b", OriginalURL: "https://other.example/b", CreatedByID: "owner", CreatedAt: created},
+		{ShortURL: "a", OriginalURL: "https://example.com/a", CreatedByID: "owner", CreatedAt: created},
+		{ShortURL: "d", OriginalURL: "https://example.com:8080/d", CreatedByID: "owner", CreatedAt: created.Add(2 * time.Hour)},
+		{ShortURL: "e", OriginalURL: "https://example.com/e", CreatedByID: "other", CreatedAt: created},
+	}))
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "d", CreatedByID: "owner"}}))
+
+	cursor := func(id string) *models.URLCursor {
+		c := models.CursorOf(getURL(t, repo, id))
+		return &c
+	}
+	deleted, notDeleted := true, false
+
+	tests := []struct {
+		name  string
+		query models.URLQuery
+		want  []string
+	}{
+		{name: "all", query: models.URLQuery{}, want: []string{"a", "b", "c", "d"}},
+		{name: "first page", query: models.URLQuery{Limit: 2}, want: []string{"a", "b"}},
+		{name: "next page", query: models.URLQuery{Limit: 2, After: cursor("b")}, want: []string{"c", "d"}},
+		{name: "last page", query: models.URLQuery{Limit: 2, After: cursor("d")}, want: []string{}},
+		{name: "newest first", query: models.URLQuery{Desc: true}, want: []string{"d", "c", "b", "a"}},
+		{name: "newest first next page", query: models.URLQuery{Desc: true, After: cursor("c")}, want: []string{"b", "a"}},
+		{name: "deleted", query: models.URLQuery{Deleted: &deleted}, want: []string{"d"}},
+		{name: "not deleted", query: models.URLQuery{Deleted: &notDeleted}, want: []string{"a", "b", "c"}},
+		{name: "domain", query: models.URLQuery{Domain: "EXAMPLE.com"}, want: []string{"a", "c", "d"}},
+		{name: "contains", query: models.URLQuery{Contains: "/c"}, want: []string{"c"}},
+		{
+			name:  "filtered page",
+			query: models.URLQuery{Limit: 1, After: cursor("a"), Domain: "example.com"},
+			want:  []string{"c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, err := repo.GetUsersUrls(ctx, "owner", tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids(urls))
+		})
+	}
+
+	urls, err := repo.GetUsersUrls(ctx, "nobody", models.URLQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+}
+
+func testDelete(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	saveURL(t, repo, "a", "https://a.example", "owner")
+	saveURL(t, repo, "b", "https://b.example", "owner")
+
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{
+		{ShortURL: "a", CreatedByID: "owner"},
+		{ShortURL: "b", CreatedByID: "other"},
+		{ShortURL: "missing", CreatedByID: "owner"},
+	}))
+
+	url := getURL(t, repo, "a")
+	assert.True(t, url.IsDeleted)
+	require.NotNil(t, url.DeletedAt)
+	assert.WithinDuration(t, time.Now(), *url.DeletedAt, time.Minute)
+
+	// the deleted url is still found by its original url
+	url, err := repo.ShortenByURL(ctx, "https://a.example")
+	require.NoError(t, err)
+	assert.True(t, url.IsDeleted)
+
+	// only the owner deletes urls
+	assert.False(t, getURL(t, repo, "b").IsDeleted)
+
+	// deleting again keeps the deletion time
+	deletedAt := *getURL(t, repo, "a").DeletedAt
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "a", CreatedByID: "owner"}}))
+	assert.True(t, deletedAt.Equal(*getURL(t, repo, "a").DeletedAt))
+}
+
+func testCount(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	users, urls, err := repo.GetUsersAndUrlsCount(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, users)
+	assert.Zero(t, urls)
+
+	saveURL(t, repo, "a", "https://a.example", "owner")
+	saveURL(t, repo, "b", "https://b.example", "owner")
+	saveURL(t, repo, "c", "https://c.example", "other")
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "c", CreatedByID: "other"}}))
+
+	// deleted urls are counted until they are purged
+	users, urls, err = repo.GetUsersAndUrlsCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, users)
+	assert.Equal(t, 3, urls)
+
+	_, err = repo.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	users, urls, err = repo.GetUsersAndUrlsCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, users)
+	assert.Equal(t, 2, urls)
+}
+
+func testUpdate(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	saveURL(t, repo, "a", "https://a.example", "owner")
+	saveURL(t, repo, "b", "https://b.example", "owner")
+	saveURL(t, repo, "c", "https://c.example", "owner")
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "c", CreatedByID: "owner"}}))
+
+	tests := []struct {
+		name string
+		url  models.ShortURL
+		err  error
+	}{
+		{
+			name: "missing url",
+			url:  models.ShortURL{ShortURL: "missing", OriginalURL: "https://x.example", CreatedByID: "owner"},
+			err:  repository.ErrNotFound,
+		},
+		{
+			name: "someone else's url",
+			url:  models.ShortURL{ShortURL: "a", OriginalURL: "https://x.example", CreatedByID: "other"},
+			err:  repository.ErrNotFound,
+		},
+		{
+			name: "deleted url",
+			url:  models.ShortURL{ShortURL: "c", OriginalURL: "https://x.example", CreatedByID: "owner"},
+			err:  repository.ErrNotFound,
+		},
+		{
+			name: "shortened destination",
+			url:  models.ShortURL{ShortURL: "a", OriginalURL: "https://b.example", CreatedByID: "owner"},
+			err:  repository.ErrDuplicate,
+		},
+		{
+			name: "deleted url destination",
+			url:  models.ShortURL{ShortURL: "a", OriginalURL: "https://c.example", CreatedByID: "owner"},
+			err:  repository.ErrDuplicate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, repo.UpdateURL(ctx, tt.url, created), tt.err)
+			assert.Equal(t, "https://a.example", getURL(t, repo, "a").OriginalURL)
+		})
+	}
+
+	revisions, err := repo.GetRevisions(ctx, "a")
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+
+	owned := models.ShortURL{ShortURL: "a", OriginalURL: "https://x.example", CreatedByID: "owner"}
+	require.NoError(t, repo.UpdateURL(ctx, owned, created.Add(time.Hour)))
+	// the same destination isn't a change
+	require.NoError(t, repo.UpdateURL(ctx, owned, created.Add(2*time.Hour)))
+	owned.OriginalURL = "https://y.example"
+	require.NoError(t, repo.UpdateURL(ctx, owned, created.Add(3*time.Hour)))
+
+	url, err := repo.ShortenByURL(ctx, "https://y.example")
+	require.NoError(t, err)
+	assert.Equal(t, "a", url.ShortURL)
+	_, err = repo.ShortenByURL(ctx, "https://a.example")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	// the previous destination can be shortened again
+	saveURL(t, repo, "d", "https://a.example", "owner")
+
+	revisions, err = repo.GetRevisions(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "https://a.example", revisions[0].OriginalURL)
+	assert.True(t, created.Add(time.Hour).Equal(revisions[0].ChangedAt))
+	assert.Equal(t, "https://x.example", revisions[1].OriginalURL)
+	assert.Equal(t, "a", revisions[1].ShortURL)
+}
+
+func testRestore(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	saveURL(t, repo, "a", "https://a.example", "owner")
+	saveURL(t, repo, "b", "https://b.example", "owner")
+	saveURL(t, repo, "c", "https://c.example", "owner")
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{
+		{ShortURL: "a", CreatedByID: "owner"},
+		{ShortURL: "b", CreatedByID: "owner"},
+	}))
+
+	// urls deleted before deletedAfter can't be restored
+	restored, err := repo.RestoreUrls(ctx, []models.ShortURL{{ShortURL: "a", CreatedByID: "owner"}},
+		time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+
+	restored, err = repo.RestoreUrls(ctx, []models.ShortURL{
+		{ShortURL: "a", CreatedByID: "owner"},
+		{ShortURL: "b", CreatedByID: "other"},
+		{ShortURL: "c", CreatedByID: "owner"},
+		{ShortURL: "missing", CreatedByID: "owner"},
+	}, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, restored)
+
+	url := getURL(t, repo, "a")
+	assert.False(t, url.IsDeleted)
+	assert.Nil(t, url.DeletedAt)
+	assert.True(t, getURL(t, repo, "b").IsDeleted)
+}
+
+func testPurge(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	saveURL(t, repo, "a", "https://a.example", "owner")
+	saveURL(t, repo, "b", "https://b.example", "owner")
+	require.NoError(t, repo.UpdateURL(ctx,
+		models.ShortURL{ShortURL: "a", OriginalURL: "https://x.example", CreatedByID: "owner"}, created))
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "a", CreatedByID: "owner"}}))
+
+	purged, err := repo.PurgeDeleted(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+
+	purged, err = repo.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = repo.GetByID(ctx, "a")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	revisions, err := repo.GetRevisions(ctx, "a")
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+	assert.False(t, getURL(t, repo, "b").IsDeleted)
+
+	// the purged url frees its short id and original url
+	saveURL(t, repo, "a", "https://x.example", "other")
+}
+
+func testExpired(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	expired, later := now, now.Add(time.Hour)
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner", ExpiresAt: &expired},
+		{ShortURL: "b", OriginalURL: "https://b.example", CreatedByID: "owner", ExpiresAt: &later},
+		{ShortURL: "c", OriginalURL: "https://c.example", CreatedByID: "owner"},
+	}))
+	require.NoError(t, repo.UpdateURL(ctx,
+		models.ShortURL{ShortURL: "a", OriginalURL: "https://x.example", CreatedByID: "owner"}, now))
+
+	// urls expire at their expiration time
+	deleted, err := repo.DeleteExpired(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	_, err = repo.GetByID(ctx, "a")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	revisions, err := repo.GetRevisions(ctx, "a")
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+	getURL(t, repo, "b")
+	getURL(t, repo, "c")
+}
+
+func testExpiredURLs(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	expired, later := now, now.Add(time.Hour)
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner", ExpiresAt: &expired},
+		{ShortURL: "b", OriginalURL: "https://b.example", CreatedByID: "owner", ExpiresAt: &expired},
+		{ShortURL: "c", OriginalURL: "https://c.example", CreatedByID: "owner", ExpiresAt: &later},
+	}))
+	require.NoError(t, repo.SaveClicks(ctx, []models.Click{{ShortURL: "a", At: now}}))
+
+	// only the listed urls that have expired are removed
+	deleted, err := repo.DeleteExpiredURLs(ctx, []string{"a", "c", "missing"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	_, err = repo.GetByID(ctx, "a")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	clicks, err := repo.GetClicks(ctx, "a")
+	require.NoError(t, err)
+	assert.Empty(t, clicks)
+	getURL(t, repo, "b")
+	getURL(t, repo, "c")
+
+	// the removed url frees its short id and original url
+	saveURL(t, repo, "a", "https://a.example", "other")
+}
+
+func testClicks(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	saveURL(t, repo, "a", "https://a.example", "owner")
+
+	stats, err := repo.GetClickStats(ctx, "a")
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalClicks)
+	assert.Empty(t, stats.Daily)
+
+	day := created
+	require.NoError(t, repo.SaveClicks(ctx, []models.Click{
+		{ShortURL: "a", At: day, IPHash: "1"},
+		{ShortURL: "a", At: day.Add(time.Minute), IPHash: "2"},
+		{ShortURL: "a", At: day.Add(24 * time.Hour), IPHash: "1"},
+		{ShortURL: "b", At: day, IPHash: "3"},
+	}))
+
+	stats, err = repo.GetClickStats(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, models.LinkStats{
+		TotalClicks:    3,
+		UniqueVisitors: 2,
+		Daily: []models.DailyClicks{
+			{Date: "2024-01-01", Clicks: 2},
+			{Date: "2024-01-02", Clicks: 1},
+		},
+	}, stats)
+
+	clicks, err := repo.GetClicks(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, clicks, 3)
+	for i, at := range []time.Time{day, day.Add(time.Minute), day.Add(24 * time.Hour)} {
+		assert.Equal(t, "a", clicks[i].ShortURL)
+		assert.True(t, at.Equal(clicks[i].At), clicks[i].At)
+	}
+	assert.Equal(t, "2", clicks[1].IPHash)
+
+	clicks, err = repo.GetClicks(ctx, "b")
+	require.NoError(t, err)
+	assert.Empty(t, clicks)
+}
+
+func testRemovedClicks(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner", CreatedAt: created},
+		{ShortURL: "b", OriginalURL: "https://b.example", CreatedByID: "owner", CreatedAt: created, ExpiresAt: &now},
+		{ShortURL: "c", OriginalURL: "https://c.example", CreatedByID: "owner", CreatedAt: created},
+	}))
+	require.NoError(t, repo.SaveClicks(ctx, []models.Click{
+		{ShortURL: "a", At: created, IPHash: "1"},
+		{ShortURL: "b", At: created, IPHash: "1"},
+		{ShortURL: "c", At: created, IPHash: "1"},
+	}))
+
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "a", CreatedByID: "owner"}}))
+	purged, err := repo.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, purged)
+	expired, err := repo.DeleteExpired(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, 1, expired)
+
+	// clicks saved after their url is removed are dropped
+	require.NoError(t, repo.SaveClicks(ctx, []models.Click{{ShortURL: "a", At: created, IPHash: "2"}}))
+
+	// a new url reusing the short id doesn't inherit clicks of the removed url
+	saveURL(t, repo, "a", "https://x.example", "other")
+	saveURL(t, repo, "b", "https://y.example", "other")
+	for _, id := range []string{"a", "b"} {
+		stats, err := repo.GetClickStats(ctx, id)
+		require.NoError(t, err)
+		assert.Zero(t, stats.TotalClicks, id)
+	}
+
+	stats, err := repo.GetClickStats(ctx, "c")
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.TotalClicks)
+}
+
+func testSaveRevisions(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	saveURL(t, repo, "a", "https://a.example", "owner")
+
+	changed := created.Add(time.Hour)
+	require.NoError(t, repo.SaveRevisions(ctx, []models.Revision{
+		{ShortURL: "a", OriginalURL: "https://first.example", ChangedAt: created},
+		{ShortURL: "a", OriginalURL: "https://second.example", ChangedAt: changed},
+		{ShortURL: "b", OriginalURL: "https://b.example", ChangedAt: created},
+	}))
+
+	revisions, err := repo.GetRevisions(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "https://first.example", revisions[0].OriginalURL)
+	assert.Equal(t, "https://second.example", revisions[1].OriginalURL)
+	assert.True(t, changed.Equal(revisions[1].ChangedAt))
+
+	// revisions of missing urls are dropped, so a url saved later doesn't inherit them
+	saveURL(t, repo, "b", "https://x.example", "owner")
+	revisions, err = repo.GetRevisions(ctx, "b")
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+}
+
+func testAPIKeys(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	hash := strings.Repeat("a", 64)
+	key := models.APIKey{ID: "key", UserID: "owner", Hash: hash, CreatedAt: created}
+	require.NoError(t, repo.SaveAPIKey(ctx, key))
+
+	assert.ErrorIs(t, repo.SaveAPIKey(ctx, models.APIKey{
+		ID: "key", UserID: "owner", Hash: strings.Repeat("b", 64), CreatedAt: created,
+	}), repository.ErrIDTaken)
+	assert.ErrorIs(t, repo.SaveAPIKey(ctx, models.APIKey{
+		ID: "other", UserID: "owner", Hash: hash, CreatedAt: created,
+	}), repository.ErrIDTaken)
+
+	found, err := repo.GetAPIKey(ctx, hash)
+	require.NoError(t, err)
+	assert.Equal(t, "key", found.ID)
+	assert.Equal(t, "owner", found.UserID)
+	assert.Nil(t, found.RevokedAt)
+	_, err = repo.GetAPIKey(ctx, strings.Repeat("c", 64))
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	assert.ErrorIs(t, repo.RevokeAPIKey(ctx, models.APIKey{ID: "key", UserID: "other"}, created),
+		repository.ErrNotFound)
+	assert.ErrorIs(t, repo.RevokeAPIKey(ctx, models.APIKey{ID: "missing", UserID: "owner"}, created),
+		repository.ErrNotFound)
+	require.NoError(t, repo.RevokeAPIKey(ctx, models.APIKey{ID: "key", UserID: "owner"}, created))
+	assert.ErrorIs(t, repo.RevokeAPIKey(ctx, models.APIKey{ID: "key", UserID: "owner"}, created),
+		repository.ErrNotFound)
+
+	_, err = repo.GetAPIKey(ctx, hash)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testAllAPIKeys(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	keys, err := repo.GetAPIKeys(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// saved revoked keys stay revoked, so keys can be moved between storages
+	revoked := created.Add(time.Hour)
+	require.NoError(t, repo.SaveAPIKey(ctx, models.APIKey{
+		ID: "b", UserID: "owner", Hash: strings.Repeat("b", 64), CreatedAt: created, RevokedAt: &revoked,
+	}))
+	require.NoError(t, repo.SaveAPIKey(ctx, models.APIKey{
+		ID: "a", UserID: "other", Hash: strings.Repeat("a", 64), CreatedAt: created,
+	}))
+	_, err = repo.GetAPIKey(ctx, strings.Repeat("b", 64))
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	keys, err = repo.GetAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "a", keys[0].ID)
+	assert.Equal(t, "other", keys[0].UserID)
+	assert.Equal(t, strings.Repeat("a", 64), keys[0].Hash)
+	assert.True(t, created.Equal(keys[0].CreatedAt))
+	assert.Nil(t, keys[0].RevokedAt)
+	assert.Equal(t, "b", keys[1].ID)
+	require.NotNil(t, keys[1].RevokedAt)
+	assert.True(t, revoked.Equal(*keys[1].RevokedAt))
+}
+
+func testAllUrls(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+	deletedAt := created.Add(time.Hour)
+	expires := created.Add(2 * time.Hour)
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "c", OriginalURL: "https://c.example", CreatedByID: "other", CreatedAt: created, ExpiresAt: &expires},
+		{ShortURL: "a", OriginalURL: "https://a.example", CreatedByID: "owner", CreatedAt: created},
+		{
+			ShortURL:    "b",
+			OriginalURL: "https://b.example",
+			CreatedByID: "owner",
+			CreatedAt:   created,
+			IsDeleted:   true,
+			DeletedAt:   &deletedAt,
+		},
+	}))
+
+	// saved deleted urls keep their deletion time, so urls can be moved between storages
+	url := getURL(t, repo, "b")
+	assert.True(t, url.IsDeleted)
+	require.NotNil(t, url.DeletedAt)
+	assert.True(t, deletedAt.Equal(*url.DeletedAt))
+
+	urls, err := repo.GetUrls(ctx, "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, ids(urls))
+	assert.Equal(t, "owner", urls[1].CreatedByID)
+	assert.True(t, urls[1].IsDeleted)
+
+	urls, err = repo.GetUrls(ctx, "b", 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c"}, ids(urls))
+	assert.Equal(t, "other", urls[0].CreatedByID)
+	assert.True(t, created.Equal(urls[0].CreatedAt))
+	require.NotNil(t, urls[0].ExpiresAt)
+	assert.True(t, expires.Equal(*urls[0].ExpiresAt))
+
+	urls, err = repo.GetUrls(ctx, "c", 2)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	urls, err = repo.GetUrls(ctx, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, ids(urls))
+}
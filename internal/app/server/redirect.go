@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/handlers"
+	"github.com/GTedya/shortener/internal/app/middlewares"
+)
+
+// RedirectServer принимает HTTP-запросы на config.Config.RedirectAddress и перенаправляет их на HTTPS,
+// чтобы клиенты, обращающиеся по HTTP, не получали ошибку соединения.
+type RedirectServer struct {
+	srv *http.Server
+}
+
+// NewRedirectServer создает сервер, отвечающий на все запросы перенаправлением 308 на тот же адрес по HTTPS.
+// Код 308 сохраняет метод и тело запроса. Если задан config.Config.RedirectLinks, переходы по коротким
+// ссылкам обслуживаются сразу, без лишнего перенаправления.
+func NewRedirectServer(conf config.Config, router *chi.Mux,
+	handler handlers.Handler, middle middlewares.Middleware) *RedirectServer {
+	router.Use(middle.LogHandle, middle.SecurityHeaders)
+
+	if conf.RedirectLinks {
+		handler.RegisterRedirects(router)
+	}
+
+	redirect := httpsRedirect(conf.Address)
+	router.NotFound(redirect)
+	router.MethodNotAllowed(redirect)
+
+	return &RedirectServer{
+		srv: &http.Server{Addr: conf.RedirectAddress, Handler: router},
+	}
+}
+
+// Run запускает HTTP-сервер и блокируется до его остановки.
+// Возвращает nil после остановки сервера методом Shutdown.
+func (s *RedirectServer) Run() error {
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("redirect server serving error: %w", err)
+	}
+	return nil
+}
+
+// Shutdown плавно останавливает сервер, дожидаясь завершения активных запросов, пока не истек ctx.
+func (s *RedirectServer) Shutdown(ctx context.Context) error {
+	if err := s.srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("redirect server shutdown error: %w", err)
+	}
+	return nil
+}
+
+// httpsRedirect перенаправляет запрос на тот же хост, путь и параметры по HTTPS
+// на порт из адреса HTTPS-сервера httpsAddress.
+func httpsRedirect(httpsAddress string) http.HandlerFunc {
+	_, port, err := net.SplitHostPort(httpsAddress)
+	if err != nil || port == "443" {
+		port = ""
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		switch {
+		case port != "":
+			host = net.JoinHostPort(host, port)
+		case strings.Contains(host, ":"):
+			host = "[" + host + "]" // IPv6 без порта
+		}
+
+		target := url.URL{
+			Scheme:   "https",
+			Host:     host,
+			Path:     r.URL.Path,
+			RawPath:  r.URL.RawPath,
+			RawQuery: r.URL.RawQuery,
+		}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/middlewares"
+)
+
+// handlerStub отвечает на переходы по коротким ссылкам перенаправлением на example.org.
+type handlerStub struct{}
+
+func (handlerStub) Register(*chi.Mux, middlewares.Middleware) {}
+
+func (handlerStub) RegisterRedirects(router *chi.Mux) {
+	router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.org", http.StatusTemporaryRedirect)
+	})
+}
+
+func TestRedirectServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     config.Config
+		method   string
+		host     string
+		target   string
+		code     int
+		location string
+	}{
+		{
+			name:     "redirect to https port",
+			conf:     config.Config{Address: ":8443"},
+			method:   http.MethodGet,
+			host:     "short.example:8080",
+			target:   "/abc?x=1",
+			code:     http.StatusPermanentRedirect,
+			location: "https://short.example:8443/abc?x=1",
+		},
+		{
+			name:     "default https port",
+			conf:     config.Config{Address: ":443"},
+			method:   http.MethodPost,
+			host:     "short.example",
+			target:   "/api/shorten",
+			code:     http.StatusPermanentRedirect,
+			location: "https://short.example/api/shorten",
+		},
+		{
+			name:     "ipv6 host",
+			conf:     config.Config{Address: ":443"},
+			method:   http.MethodGet,
+			host:     "[::1]:80",
+			target:   "/",
+			code:     http.StatusPermanentRedirect,
+			location: "https://[::1]/",
+		},
+		{
+			name:     "short link is served",
+			conf:     config.Config{Address: ":443", RedirectLinks: true},
+			method:   http.MethodGet,
+			host:     "short.example",
+			target:   "/abc",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://example.org",
+		},
+		{
+			name:     "other method with short links",
+			conf:     config.Config{Address: ":443", RedirectLinks: true},
+			method:   http.MethodPost,
+			host:     "short.example",
+			target:   "/abc",
+			code:     http.StatusPermanentRedirect,
+			location: "https://short.example/abc",
+		},
+		{
+			name:     "api with short links",
+			conf:     config.Config{Address: ":443", RedirectLinks: true},
+			method:   http.MethodGet,
+			host:     "short.example",
+			target:   "/api/user/urls",
+			code:     http.StatusPermanentRedirect,
+			location: "https://short.example/api/user/urls",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRedirectServer(tt.conf, chi.NewRouter(), handlerStub{}, middlewares.Middleware{Log: zap.S()})
+
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			r.Host = tt.host
+			w := httptest.NewRecorder()
+			s.srv.Handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+		})
+	}
+}
@@ -2,32 +2,38 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
 
 	"github.com/GTedya/shortener/config"
 	"github.com/GTedya/shortener/internal/app/handlers"
 	"github.com/GTedya/shortener/internal/app/middlewares"
+	"github.com/GTedya/shortener/internal/app/tlsutils"
 
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
-	"github.com/kabukky/httpscerts"
 	"go.uber.org/zap"
 )
 
-var timeout = 5 * time.Second
+// HTTPServer обслуживает HTTP-запросы к сервису.
+type HTTPServer struct {
+	srv  *http.Server
+	conf config.Config
+	log  *zap.SugaredLogger
 
-// Start запускает HTTP-сервер для обслуживания запросов.
-// Возвращает ошибку, если сервер не удалось запустить.
-func Start(conf config.Config, log *zap.SugaredLogger, router *chi.Mux,
-	handler handlers.Handler, middle middlewares.Middleware) error {
-	// Использование посредников для обработки логов, сжатия gzip и декомпрессии gzip.
-	router.Use(middle.LogHandle, middle.GzipCompressHandle, middle.GzipDecompressMiddleware)
+	reloadCtx  context.Context    // завершается при остановке сервера, останавливая перезагрузку сертификата
+	stopReload context.CancelFunc // завершает reloadCtx
+}
+
+// NewHTTPServer создает HTTP-сервер, регистрируя посредников и обработчики маршрутов в маршрутизаторе.
+func NewHTTPServer(conf config.Config, log *zap.SugaredLogger, router *chi.Mux,
+	handler handlers.Handler, middle middlewares.Middleware) *HTTPServer {
+	// Использование посредников для обработки логов, заголовков безопасности, сжатия gzip, декомпрессии gzip
+	// и перевыпуска токенов пользователей, выданных устаревшими ключами.
+	router.Use(middle.LogHandle, middle.SecurityHeaders, middle.GzipCompressHandle, middle.GzipDecompressMiddleware,
+		middle.RefreshToken)
 
 	// Регистрация профилировщика Chi для мониторинга и отладки.
 	router.Mount("/debug", chiMiddleware.Profiler())
@@ -35,68 +41,78 @@ func Start(conf config.Config, log *zap.SugaredLogger, router *chi.Mux,
 	// Регистрация обработчика запросов.
 	handler.Register(router, middle)
 
-	sigs := make(chan os.Signal, 1)
-	idleConnsClosed := make(chan struct{})
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-
-	srv := &http.Server{Addr: conf.Address, Handler: router}
-
-	go handleShutdown(sigs, srv, log, idleConnsClosed)
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	return &HTTPServer{
+		srv:        &http.Server{Addr: conf.Address, Handler: router},
+		conf:       conf,
+		log:        log,
+		reloadCtx:  reloadCtx,
+		stopReload: stopReload,
+	}
+}
 
-	// Запуск HTTPS-сервера.
-	if conf.EnableHTTPS {
-		return runHTTPS(log, srv)
+// Run запускает HTTP- или HTTPS-сервер и блокируется до его остановки.
+// Возвращает nil после остановки сервера методом Shutdown.
+func (s *HTTPServer) Run() error {
+	var err error
+	if s.conf.EnableHTTPS {
+		err = s.runHTTPS()
+	} else {
+		err = s.srv.ListenAndServe()
 	}
 
-	// Запуск HTTP-сервера.
-	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("server serving error: %w", err)
 	}
-
-	<-idleConnsClosed
 	return nil
 }
 
-// handleShutdown gracefully shuts down the HTTP server upon receiving a signal.
-// It waits for a signal from the provided channel, then attempts to gracefully
-// shut down the server. If the shutdown encounters any errors, they are logged.
-// Once the server is shut down, the idleConnsClosed channel is closed.
-//
-// Parameters:
-//   - sigs: A channel to receive OS signals for initiating shutdown.
-//   - srv: The HTTP server to shut down.
-//   - log: A sugared logger for logging messages.
-//   - idleConnsClosed: A channel to signal that all connections are closed.
-func handleShutdown(sigs chan os.Signal, srv *http.Server, log *zap.SugaredLogger, idleConnsClosed chan struct{}) {
-	<-sigs
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Errorw("HTTP server Shutdown", "error", err)
+// Shutdown плавно останавливает сервер, дожидаясь завершения активных запросов, пока не истек ctx.
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
+	s.stopReload()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("HTTP server shutdown error: %w", err)
 	}
-	close(idleConnsClosed)
+	return nil
 }
 
-// runHTTPS starts the HTTPS server with the provided server configuration.
-// If the required certificate and key files are not found, it attempts to
-// generate them using the provided address. The server listens and serves
-// HTTPS requests using the generated or existing certificate and key files.
+// runHTTPS starts the HTTPS server with the TLS settings from the configuration.
 //
-// Parameters:
-//   - log: A sugared logger for logging messages.
-//   - srv: The HTTP server to start.
+// If ACME domains are configured, certificates are obtained and renewed automatically, see tlsutils.NewACMEManager.
+// Otherwise the certificate and key files from the configuration are used. If they are not found,
+// a self-signed certificate for the server address is generated, see tlsutils.EnsureCertificate.
+// The certificate is reloaded on SIGHUP and when the files change, so it can be replaced without restart.
 //
 // Returns:
-//   - error: An error if the server fails to start or serve.
-func runHTTPS(log *zap.SugaredLogger, srv *http.Server) error {
-	if err := httpscerts.Check("cert.pem", "key.pem"); err != nil {
-		if err := httpscerts.Generate("cert.pem", "key.pem", "127.0.0.1:8081"); err != nil {
-			log.Fatal("Ошибка: Не можем сгенерировать https сертификат.")
+//   - error: An error if the TLS settings are invalid or the server fails to start or serve.
+func (s *HTTPServer) runHTTPS() error {
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return fmt.Errorf("HTTPS config error: %w", err)
+	}
+	s.srv.TLSConfig = tlsConfig
+
+	return s.srv.ListenAndServeTLS("", "") //nolint:wrapcheck // wrapped by caller
+}
+
+// tlsConfig creates the TLS configuration of the server and starts certificate reloading if needed.
+func (s *HTTPServer) tlsConfig() (*tls.Config, error) {
+	if s.conf.ACMEDomains != "" {
+		manager, err := tlsutils.NewACMEManager(s.conf)
+		if err != nil {
+			return nil, fmt.Errorf("ACME error: %w", err)
 		}
+		return tlsutils.ACMEConfig(s.conf, manager) //nolint:wrapcheck // wrapped by caller
 	}
 
-	if err := srv.ListenAndServeTLS("cert.pem", "key.pem"); err != nil {
-		return fmt.Errorf("server serving error: %w", err)
+	if err := tlsutils.EnsureCertificate(s.conf.TLSCertFile, s.conf.TLSKeyFile, s.conf.Address); err != nil {
+		return nil, fmt.Errorf("certificate error: %w", err)
 	}
-	return nil
+	certs, err := tlsutils.NewCertReloader(s.conf.TLSCertFile, s.conf.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("certificate error: %w", err)
+	}
+	go certs.Watch(s.reloadCtx, s.conf.TLSReload.Duration, s.log)
+
+	return tlsutils.ServerConfig(s.conf, certs, "") //nolint:wrapcheck // wrapped by caller
 }
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// shutdownTimeout ограничивает время плавной остановки всех серверов.
+var shutdownTimeout = 5 * time.Second
+
+// Runner - сервер, управляемый супервизором.
+type Runner interface {
+	// Run обслуживает запросы и блокируется до остановки. После Shutdown возвращает nil.
+	Run() error
+	// Shutdown плавно останавливает сервер.
+	Shutdown(ctx context.Context) error
+}
+
+// Run запускает все серверы одновременно и блокируется, пока они работают.
+// Когда ctx завершается или любой из серверов падает с ошибкой, все серверы останавливаются вместе.
+// Возвращает первую ошибку запуска или ошибки остановки серверов.
+func Run(ctx context.Context, log *zap.SugaredLogger, runners ...Runner) error {
+	group, groupCtx := errgroup.WithContext(ctx)
+	for _, runner := range runners {
+		group.Go(runner.Run)
+	}
+
+	group.Go(func() error {
+		<-groupCtx.Done()
+		log.Info("shutting down servers")
+		return shutdown(runners)
+	})
+
+	return group.Wait() //nolint:wrapcheck // errors are already wrapped by runners
+}
+
+// shutdown останавливает все серверы параллельно и объединяет их ошибки.
+func shutdown(runners []Runner) error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(runners))
+	for i, runner := range runners {
+		wg.Add(1)
+		go func(i int, runner Runner) {
+			defer wg.Done()
+			errs[i] = runner.Shutdown(ctx)
+		}(i, runner)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
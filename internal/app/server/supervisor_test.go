@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// runnerStub работает, пока его не остановят, или сразу возвращает runErr.
+type runnerStub struct {
+	runErr   error
+	stopped  chan struct{}
+	shutdown bool
+}
+
+func newRunnerStub(runErr error) *runnerStub {
+	return &runnerStub{runErr: runErr, stopped: make(chan struct{})}
+}
+
+func (r *runnerStub) Run() error {
+	if r.runErr != nil {
+		return r.runErr
+	}
+	<-r.stopped
+	return nil
+}
+
+func (r *runnerStub) Shutdown(_ context.Context) error {
+	if !r.shutdown {
+		r.shutdown = true
+		if r.runErr == nil {
+			close(r.stopped)
+		}
+	}
+	return nil
+}
+
+func TestRun_StopsAllOnContextDone(t *testing.T) {
+	first, second := newRunnerStub(nil), newRunnerStub(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, zap.S(), first, second)
+	}()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("servers must be stopped after context is done")
+	}
+	assert.True(t, first.shutdown)
+	assert.True(t, second.shutdown)
+}
+
+func TestRun_StopsAllOnFailure(t *testing.T) {
+	errListen := errors.New("address already in use")
+	failing, healthy := newRunnerStub(errListen), newRunnerStub(nil)
+
+	done := make(chan error)
+	go func() {
+		done <- Run(context.Background(), zap.S(), failing, healthy)
+	}()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, errListen)
+	case <-time.After(time.Second):
+		t.Fatal("servers must be stopped after one of them fails")
+	}
+	assert.True(t, healthy.shutdown)
+}
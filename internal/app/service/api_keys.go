@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/GTedya/shortener/internal/app/models"
+)
+
+// apiKeyPrefix makes api keys recognizable, e.g. by secret scanners.
+const apiKeyPrefix = "shk_"
+
+// Sizes of random parts of api keys in bytes.
+const (
+	apiKeyIDSize     = 8
+	apiKeySecretSize = 32
+)
+
+// IssueAPIKey creates new api key owned by userID.
+// Returns the stored key and the key itself, which is not saved anywhere and can't be shown again.
+func (service *Shortener) IssueAPIKey(ctx context.Context, userID string) (models.APIKey, string, error) {
+	id, err := randomBytes(apiKeyIDSize)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	secret, err := randomBytes(apiKeySecretSize)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	apiKey := models.APIKey{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		Hash:      hashAPIKey(key),
+		CreatedAt: time.Now(),
+	}
+	if err = service.repository.SaveAPIKey(ctx, apiKey); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("error while saving api key: %w", err)
+	}
+	return apiKey, key, nil
+}
+
+// RevokeAPIKey revokes api key with given id owned by userID.
+// repository.ErrNotFound is returned for missing, already revoked and someone else's keys.
+func (service *Shortener) RevokeAPIKey(ctx context.Context, id string, userID string) error {
+	err := service.repository.RevokeAPIKey(ctx, models.APIKey{ID: id, UserID: userID}, time.Now())
+	if err != nil {
+		return fmt.Errorf("error while revoking api key: %w", err)
+	}
+	return nil
+}
+
+// ResolveAPIKey returns id of the user owning active api key.
+// repository.ErrNotFound is returned for unknown and revoked keys.
+func (service *Shortener) ResolveAPIKey(ctx context.Context, key string) (string, error) {
+	apiKey, err := service.repository.GetAPIKey(ctx, hashAPIKey(key))
+	if err != nil {
+		return "", fmt.Errorf("error while getting api key: %w", err)
+	}
+	return apiKey.UserID, nil
+}
+
+// hashAPIKey returns hex encoded SHA-256 of the key.
+// Keys are long random strings, so they don't need salting or slow hashing.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomBytes returns n cryptographically secure random bytes.
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("random reading error: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/idgen"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+func TestShortener_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	service := NewShortener(repository.NewInMemoryRepository(), idgen.NewRandom(8), &config.Config{})
+
+	apiKey, key, err := service.IssueAPIKey(ctx, "owner")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, apiKeyPrefix))
+	assert.NotContains(t, apiKey.Hash, key)
+	assert.Equal(t, "owner", apiKey.UserID)
+
+	userID, err := service.ResolveAPIKey(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, "owner", userID)
+
+	_, err = service.ResolveAPIKey(ctx, key+"x")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	assert.ErrorIs(t, service.RevokeAPIKey(ctx, apiKey.ID, "someone else"), repository.ErrNotFound)
+	require.NoError(t, service.RevokeAPIKey(ctx, apiKey.ID, "owner"))
+	assert.ErrorIs(t, service.RevokeAPIKey(ctx, apiKey.ID, "owner"), repository.ErrNotFound)
+
+	_, err = service.ResolveAPIKey(ctx, key)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
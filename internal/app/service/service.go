@@ -12,6 +12,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/idgen"
 	"github.com/GTedya/shortener/internal/app/models"
 	"github.com/GTedya/shortener/internal/app/repository"
 )
@@ -19,30 +20,40 @@ import (
 var timeout = 5 * time.Second
 
 type ShortenerInterface interface {
-	Shorten(ctx context.Context, url string, userID string) (models.ShortURL, error)
+	Shorten(ctx context.Context, shortURL models.ShortURL) (models.ShortURL, error)
 	Expand(ctx context.Context, id string) (models.ShortURL, error)
 	FormatShortURL(urlID string) string
-	GetUrlsCreatedBy(ctx context.Context, userID string) ([]models.ShortURL, error)
+	GetUrlsCreatedBy(ctx context.Context, userID string, query models.URLQuery) (models.URLPage, error)
 	HealthCheck(ctx context.Context) error
 	ShortenBatch(ctx context.Context, batch []models.ShortURL, userID string) ([]models.ShortURL, error)
 	GenerateNewUserID() string
 	DeleteUrls(ctx context.Context, ids []string, userID string)
 	GetStats(ctx context.Context) (models.Stats, error)
+	UpdateURL(ctx context.Context, shortURL models.ShortURL) error
+	GetRevisions(ctx context.Context, id string, userID string) ([]models.Revision, error)
+	GetClickStats(ctx context.Context, id string, userID string) (models.LinkStats, error)
+	RestoreUrls(ctx context.Context, ids []string, userID string) ([]string, error)
+	IssueAPIKey(ctx context.Context, userID string) (models.APIKey, string, error)
+	RevokeAPIKey(ctx context.Context, id string, userID string) error
+	ResolveAPIKey(ctx context.Context, key string) (string, error)
 }
 
 // Shortener is main service of application.
 type Shortener struct {
 	repository repository.Repository
+	generator  idgen.Generator
 	config     *config.Config
 }
 
 // NewShortener creates new service.
 func NewShortener(
 	repository repository.Repository,
+	generator idgen.Generator,
 	config *config.Config,
 ) *Shortener {
 	return &Shortener{
 		repository: repository,
+		generator:  generator,
 		config:     config}
 }
 
@@ -68,19 +79,32 @@ func NewShorteningError(shortURL models.ShortURL, err error) error {
 	}
 }
 
-// Shorten shortens full url and returns filled struct ShortURL.
-func (service *Shortener) Shorten(ctx context.Context, url string, userID string) (models.ShortURL, error) {
-	urlID := uuid.NewString()
-
-	shortURL := models.ShortURL{
-		OriginalURL: url,
-		ShortURL:    urlID,
-		CreatedByID: userID,
+// Shorten saves shortURL and returns it with filled short id.
+// OriginalURL and CreatedByID must be set, ExpiresAt is optional.
+// If ShortURL is not empty it is used as custom alias instead of generated id,
+// repository.ErrIDTaken is returned when the alias is already in use.
+// If OriginalURL is already shortened, the existing short url is returned
+// together with error wrapping repository.ErrDuplicate.
+// Expired urls that aren't removed by the sweeper yet don't hold their aliases and original urls.
+func (service *Shortener) Shorten(ctx context.Context, shortURL models.ShortURL) (models.ShortURL, error) {
+	var err error
+	if alias := shortURL.ShortURL; alias != "" {
+		if err = idgen.ValidateAlias(alias); err != nil {
+			return models.ShortURL{}, fmt.Errorf("alias validation error: %w", err)
+		}
+		err = service.save(ctx, shortURL)
+	} else {
+		_, err = idgen.Reserve(service.generator, func(id string) error {
+			shortURL.ShortURL = id
+			return service.save(ctx, shortURL)
+		})
 	}
-
-	err := service.repository.Save(ctx, shortURL)
 	if errors.Is(err, repository.ErrDuplicate) {
-		return shortURL, NewShorteningError(shortURL, err)
+		existing, lookupErr := service.repository.ShortenByURL(ctx, shortURL.OriginalURL)
+		if lookupErr != nil {
+			return models.ShortURL{}, fmt.Errorf("error while getting existing shortening URL: %w", lookupErr)
+		}
+		return existing, NewShorteningError(existing, err)
 	}
 	if err != nil {
 		return models.ShortURL{}, fmt.Errorf("error while saving shortening URL: %w", err)
@@ -89,6 +113,74 @@ func (service *Shortener) Shorten(ctx context.Context, url string, userID string
 	return shortURL, nil
 }
 
+// save saves the url. If its short id or original url is taken by an expired url,
+// the expired url is deleted and the url is saved again.
+func (service *Shortener) save(ctx context.Context, shortURL models.ShortURL) error {
+	return service.saveOverExpired(ctx, []models.ShortURL{shortURL}, func() error {
+		return service.repository.Save(ctx, shortURL) //nolint:wrapcheck // wrapped by the caller
+	})
+}
+
+// saveBatch saves the batch like save does, expired urls taking short ids or original urls
+// of the batch are deleted and the batch is saved again.
+func (service *Shortener) saveBatch(ctx context.Context, batch []models.ShortURL) error {
+	return service.saveOverExpired(ctx, batch, func() error {
+		return service.repository.SaveBatch(ctx, batch) //nolint:wrapcheck // wrapped by the caller
+	})
+}
+
+// saveOverExpired calls save until it succeeds or fails for another reason than a conflict with expired url.
+// After every conflict the expired urls taking short ids or original urls of the urls are deleted,
+// other urls aren't touched.
+func (service *Shortener) saveOverExpired(ctx context.Context, urls []models.ShortURL, save func() error) error {
+	for {
+		err := save()
+		if !errors.Is(err, repository.ErrDuplicate) && !errors.Is(err, repository.ErrIDTaken) {
+			return err
+		}
+
+		deleted, deleteErr := service.deleteExpiredConflicts(ctx, urls, err)
+		if deleteErr != nil {
+			return deleteErr
+		}
+		if deleted == 0 {
+			return err
+		}
+	}
+}
+
+// deleteExpiredConflicts deletes expired urls taking original urls of the urls if conflict is
+// repository.ErrDuplicate, or their short ids otherwise. Returns number of deleted urls.
+func (service *Shortener) deleteExpiredConflicts(
+	ctx context.Context,
+	urls []models.ShortURL,
+	conflict error,
+) (int, error) {
+	now := time.Now()
+	var expired []string
+	for _, shortURL := range urls {
+		var taken models.ShortURL
+		var err error
+		if errors.Is(conflict, repository.ErrDuplicate) {
+			taken, err = service.repository.ShortenByURL(ctx, shortURL.OriginalURL)
+		} else {
+			taken, err = service.repository.GetByID(ctx, shortURL.ShortURL)
+		}
+		if err == nil && taken.IsExpired(now) {
+			expired = append(expired, taken.ShortURL)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	deleted, err := service.repository.DeleteExpiredURLs(ctx, expired, now)
+	if err != nil {
+		return 0, fmt.Errorf("expired urls deleting error: %w", err)
+	}
+	return deleted, nil
+}
+
 // Expand expands full url from given id. Returns filled ShortURL struct.
 func (service *Shortener) Expand(ctx context.Context, id string) (models.ShortURL, error) {
 	origURL, err := service.repository.GetByID(ctx, id)
@@ -103,14 +195,34 @@ func (service *Shortener) FormatShortURL(urlID string) string {
 	return fmt.Sprintf("%s/%s", service.config.URL, urlID)
 }
 
-// GetUrlsCreatedBy returns array of all urs that was shortened by given userID.
-// It's just a wrapper for repository.GetUsersUrls.
-func (service *Shortener) GetUrlsCreatedBy(ctx context.Context, userID string) ([]models.ShortURL, error) {
-	urls, err := service.repository.GetUsersUrls(ctx, userID)
+// GetUrlsCreatedBy returns the page of urls that was shortened by given userID.
+// Limit of the query is clamped to [1, models.MaxPageSize], zero means models.DefaultPageSize.
+// NextCursor of the page is empty if there are no more urls.
+func (service *Shortener) GetUrlsCreatedBy(
+	ctx context.Context,
+	userID string,
+	query models.URLQuery,
+) (models.URLPage, error) {
+	switch {
+	case query.Limit <= 0:
+		query.Limit = models.DefaultPageSize
+	case query.Limit > models.MaxPageSize:
+		query.Limit = models.MaxPageSize
+	}
+	limit := query.Limit
+	query.Limit++ // one more url tells whether there is the next page
+
+	urls, err := service.repository.GetUsersUrls(ctx, userID, query)
 	if err != nil {
-		return []models.ShortURL{}, fmt.Errorf("error while getting user's shortening URLs: %w", err)
+		return models.URLPage{}, fmt.Errorf("error while getting user's shortening URLs: %w", err)
+	}
+
+	page := models.URLPage{URLs: urls}
+	if len(urls) > limit {
+		page.URLs = urls[:limit]
+		page.NextCursor = models.EncodeCursor(models.CursorOf(urls[limit-1]))
 	}
-	return urls, nil
+	return page, nil
 }
 
 // HealthCheck checks if service is working correctly.
@@ -127,21 +239,55 @@ func (service *Shortener) HealthCheck(ctx context.Context) error {
 
 // ShortenBatch shortens array of urls.
 // All entries of batch must contain OriginalURL.
+// Entries with filled ShortURL are saved with it as custom alias.
+// Expired urls that aren't removed by the sweeper yet don't hold their aliases and original urls.
 func (service *Shortener) ShortenBatch(ctx context.Context,
 	batch []models.ShortURL, userID string) ([]models.ShortURL, error) {
+	generated := make([]int, 0, len(batch))
+	aliases := make(map[string]bool)
 	for i := range batch {
-		urlID := uuid.NewString()
-		batch[i].ShortURL = urlID
 		batch[i].CreatedByID = userID
+
+		alias := batch[i].ShortURL
+		if alias == "" {
+			generated = append(generated, i)
+			continue
+		}
+		if err := service.checkAlias(ctx, alias, aliases); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := service.repository.SaveBatch(ctx, batch); err != nil {
+	_, err := idgen.ReserveBatch(service.generator, len(generated), func(ids []string) error {
+		for j, i := range generated {
+			batch[i].ShortURL = ids[j]
+		}
+		return service.saveBatch(ctx, batch)
+	})
+	if err != nil {
 		return nil, fmt.Errorf("error while batch: %w", err)
 	}
 
 	return batch, nil
 }
 
+// checkAlias validates batch alias and makes sure it is used neither in storage, unless the url using it
+// has expired, nor earlier in the same batch (seen).
+func (service *Shortener) checkAlias(ctx context.Context, alias string, seen map[string]bool) error {
+	if err := idgen.ValidateAlias(alias); err != nil {
+		return fmt.Errorf("alias validation error: %w", err)
+	}
+	if seen[alias] {
+		return fmt.Errorf("alias %s is repeated: %w", alias, repository.ErrIDTaken)
+	}
+	seen[alias] = true
+
+	if taken, err := service.repository.GetByID(ctx, alias); err == nil && !taken.IsExpired(time.Now()) {
+		return fmt.Errorf("alias %s: %w", alias, repository.ErrIDTaken)
+	}
+	return nil
+}
+
 // GenerateNewUserID generates new user id.
 // It's just a wrapper for random.GenerateNewUserID().
 func (service *Shortener) GenerateNewUserID() string {
@@ -192,6 +338,83 @@ func (service *Shortener) GetStats(ctx context.Context) (models.Stats, error) {
 	return models.Stats{UsersCount: usersCount, UrlsCount: urlsCount}, nil
 }
 
+// UpdateURL changes destination of the url with shortURL.ShortURL id to shortURL.OriginalURL.
+// Only the creator of the url given in shortURL.CreatedByID can change it,
+// repository.ErrNotFound is returned for missing, deleted or someone else's urls.
+// The previous destination is kept in revision history.
+func (service *Shortener) UpdateURL(ctx context.Context, shortURL models.ShortURL) error {
+	if err := service.repository.UpdateURL(ctx, shortURL, time.Now()); err != nil {
+		return fmt.Errorf("error while updating URL: %w", err)
+	}
+	return nil
+}
+
+// GetRevisions returns previous destinations of the url with given id, oldest first.
+// Revisions are available only to the creator of the url,
+// repository.ErrNotFound is returned for missing and someone else's urls.
+func (service *Shortener) GetRevisions(ctx context.Context, id string, userID string) ([]models.Revision, error) {
+	if err := service.checkOwner(ctx, id, userID); err != nil {
+		return nil, err
+	}
+
+	revisions, err := service.repository.GetRevisions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetClickStats returns click analytics of the url with given id.
+// Analytics is available only to the creator of the url,
+// repository.ErrNotFound is returned for missing and someone else's urls.
+func (service *Shortener) GetClickStats(ctx context.Context, id string, userID string) (models.LinkStats, error) {
+	if err := service.checkOwner(ctx, id, userID); err != nil {
+		return models.LinkStats{}, err
+	}
+
+	stats, err := service.repository.GetClickStats(ctx, id)
+	if err != nil {
+		return models.LinkStats{}, fmt.Errorf("error while getting click stats: %w", err)
+	}
+	return stats, nil
+}
+
+// checkOwner returns repository.ErrNotFound if the url with given id doesn't exist or wasn't created by userID.
+func (service *Shortener) checkOwner(ctx context.Context, id string, userID string) error {
+	shortURL, err := service.repository.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error while getting shortening URL: %w", err)
+	}
+	if shortURL.CreatedByID != userID {
+		return fmt.Errorf("url %s belongs to another user: %w", id, repository.ErrNotFound)
+	}
+	return nil
+}
+
+// RestoreUrls undeletes urls with given ids that were created by userID
+// and deleted no longer than config.Config.DeleteGrace ago. Returns ids of restored urls.
+func (service *Shortener) RestoreUrls(ctx context.Context, ids []string, userID string) ([]string, error) {
+	urls := make([]models.ShortURL, 0, len(ids))
+	for _, id := range ids {
+		urls = append(urls, models.ShortURL{ShortURL: id, CreatedByID: userID})
+	}
+
+	restored, err := service.repository.RestoreUrls(ctx, urls, service.restoreDeadline(time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("error while restoring URLs: %w", err)
+	}
+	return restored, nil
+}
+
+// restoreDeadline returns the earliest deletion time of urls that still can be restored.
+// Zero time is returned when grace period is disabled, so any deleted url can be restored.
+func (service *Shortener) restoreDeadline(now time.Time) time.Time {
+	if service.config.DeleteGrace.Duration <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-service.config.DeleteGrace.Duration)
+}
+
 func newWorker(urlID string, userID string, out chan models.ShortURL) {
 	go func() {
 		defer func() {
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GTedya/shortener/config"
+	"github.com/GTedya/shortener/internal/app/idgen"
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+func TestShortener_restoreDeadline(t *testing.T) {
+	now := time.Now()
+
+	service := NewShortener(nil, nil, &config.Config{DeleteGrace: config.Duration{Duration: time.Hour}})
+	assert.Equal(t, now.Add(-time.Hour), service.restoreDeadline(now))
+
+	service = NewShortener(nil, nil, &config.Config{})
+	assert.True(t, service.restoreDeadline(now).IsZero())
+}
+
+func TestShortener_ownership(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	service := NewShortener(repo, idgen.NewRandom(8), &config.Config{})
+
+	shortURL, err := service.Shorten(ctx, models.ShortURL{OriginalURL: "https://example.com", CreatedByID: "owner"})
+	require.NoError(t, err)
+
+	_, err = service.GetClickStats(ctx, shortURL.ShortURL, "owner")
+	assert.NoError(t, err)
+	_, err = service.GetRevisions(ctx, shortURL.ShortURL, "owner")
+	assert.NoError(t, err)
+
+	_, err = service.GetClickStats(ctx, shortURL.ShortURL, "someone else")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = service.GetRevisions(ctx, "missing", "owner")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestShortener_Shorten_expired(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	service := NewShortener(repo, idgen.NewRandom(8), &config.Config{})
+
+	expired := time.Now().Add(-time.Minute)
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{ShortURL: "old", OriginalURL: "https://example.com", CreatedByID: "owner", ExpiresAt: &expired},
+		{ShortURL: "alias", OriginalURL: "https://alias.example", CreatedByID: "owner", ExpiresAt: &expired},
+		{ShortURL: "live", OriginalURL: "https://live.example", CreatedByID: "owner"},
+		{ShortURL: "batch", OriginalURL: "https://batch.example", CreatedByID: "owner", ExpiresAt: &expired},
+		{ShortURL: "unrelated", OriginalURL: "https://unrelated.example", CreatedByID: "owner", ExpiresAt: &expired},
+	}))
+
+	t.Run("original url of expired url", func(t *testing.T) {
+		shortURL, err := service.Shorten(ctx, models.ShortURL{OriginalURL: "https://example.com", CreatedByID: "other"})
+		require.NoError(t, err)
+		assert.NotEqual(t, "old", shortURL.ShortURL)
+		assert.Equal(t, "other", getURL(t, repo, shortURL.ShortURL).CreatedByID)
+	})
+
+	t.Run("alias of expired url", func(t *testing.T) {
+		_, err := service.Shorten(ctx,
+			models.ShortURL{ShortURL: "alias", OriginalURL: "https://new.example", CreatedByID: "other"})
+		require.NoError(t, err)
+		assert.Equal(t, "https://new.example", getURL(t, repo, "alias").OriginalURL)
+	})
+
+	t.Run("batch with alias and original url of expired urls", func(t *testing.T) {
+		saved, err := service.ShortenBatch(ctx, []models.ShortURL{
+			{ShortURL: "batch", OriginalURL: "https://batch-new.example"},
+			{OriginalURL: "https://batch.example"},
+		}, "other")
+		require.NoError(t, err)
+		require.Len(t, saved, 2)
+		assert.Equal(t, "https://batch-new.example", getURL(t, repo, "batch").OriginalURL)
+		assert.Equal(t, "other", getURL(t, repo, saved[1].ShortURL).CreatedByID)
+	})
+
+	t.Run("unrelated expired url is kept", func(t *testing.T) {
+		// only the conflicting urls are deleted, the rest is left to the sweeper
+		assert.Equal(t, "https://unrelated.example", getURL(t, repo, "unrelated").OriginalURL)
+	})
+
+	t.Run("original url of live url", func(t *testing.T) {
+		shortURL, err := service.Shorten(ctx, models.ShortURL{OriginalURL: "https://live.example", CreatedByID: "other"})
+		assert.ErrorIs(t, err, repository.ErrDuplicate)
+		assert.Equal(t, "live", shortURL.ShortURL)
+	})
+}
+
+// getURL returns the url with given id from the repository.
+func getURL(t *testing.T, repo repository.Repository, id string) models.ShortURL {
+	t.Helper()
+
+	url, err := repo.GetByID(context.Background(), id)
+	require.NoError(t, err)
+	return url
+}
+
+func TestShortener_GetUrlsCreatedBy(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	service := NewShortener(repo, idgen.NewRandom(8), &config.Config{})
+
+	start := time.Now().UTC().Truncate(time.Microsecond)
+	for i, id := range []string{"a", "b", "c"} {
+		err := repo.Save(ctx, models.ShortURL{
+			ShortURL:    id,
+			OriginalURL: "https://example.com/" + id,
+			CreatedByID: "owner",
+			CreatedAt:   start.Add(time.Duration(i) * time.Second),
+		})
+		require.NoError(t, err)
+	}
+
+	page, err := service.GetUrlsCreatedBy(ctx, "owner", models.URLQuery{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 2)
+	assert.Equal(t, "a", page.URLs[0].ShortURL)
+	assert.Equal(t, "b", page.URLs[1].ShortURL)
+	require.NotEmpty(t, page.NextCursor)
+
+	cursor, err := models.DecodeCursor(page.NextCursor)
+	require.NoError(t, err)
+	page, err = service.GetUrlsCreatedBy(ctx, "owner", models.URLQuery{Limit: 2, After: &cursor})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 1)
+	assert.Equal(t, "c", page.URLs[0].ShortURL)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = service.GetUrlsCreatedBy(ctx, "owner", models.URLQuery{Desc: true})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 3)
+	assert.Equal(t, "c", page.URLs[0].ShortURL)
+	assert.Empty(t, page.NextCursor)
+}
@@ -0,0 +1,80 @@
+// Package sweeper periodically cleans up the storage in background.
+package sweeper
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Repository is the part of storage the sweeper cleans up.
+type Repository interface {
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error)
+}
+
+// Sweeper removes expired urls and urls deleted longer than grace period ago
+// from repository once per interval.
+type Sweeper struct {
+	repo     Repository
+	interval time.Duration
+	grace    time.Duration
+	log      *zap.SugaredLogger
+	now      func() time.Time
+}
+
+// New creates sweeper. Non-positive interval disables sweeping,
+// non-positive grace disables purging of deleted urls.
+func New(repo Repository, interval, grace time.Duration, log *zap.SugaredLogger) *Sweeper {
+	return &Sweeper{
+		repo:     repo,
+		interval: interval,
+		grace:    grace,
+		log:      log,
+		now:      time.Now,
+	}
+}
+
+// Run sweeps the repository until ctx is done.
+func (s *Sweeper) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep(ctx)
+		}
+	}
+}
+
+// Sweep removes urls that have expired by now and purges urls deleted before the grace period.
+func (s *Sweeper) Sweep(ctx context.Context) {
+	now := s.now()
+
+	deleted, err := s.repo.DeleteExpired(ctx, now)
+	if err != nil {
+		s.log.Errorw("expired urls deleting error", "error", err)
+	} else if deleted > 0 {
+		s.log.Infow("expired urls deleted", "count", deleted)
+	}
+
+	if s.grace <= 0 {
+		return
+	}
+	purged, err := s.repo.PurgeDeleted(ctx, now.Add(-s.grace))
+	if err != nil {
+		s.log.Errorw("deleted urls purging error", "error", err)
+		return
+	}
+	if purged > 0 {
+		s.log.Infow("deleted urls purged", "count", purged)
+	}
+}
@@ -0,0 +1,79 @@
+package sweeper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/GTedya/shortener/internal/app/models"
+	"github.com/GTedya/shortener/internal/app/repository"
+)
+
+func TestSweeper_Sweep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	repo := repository.NewInMemoryRepository()
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{OriginalURL: "http://expired.com", ShortURL: "expired", ExpiresAt: &past},
+		{OriginalURL: "http://alive.com", ShortURL: "alive", ExpiresAt: &future},
+		{OriginalURL: "http://forever.com", ShortURL: "forever"},
+	}))
+
+	s := New(repo, time.Minute, 0, zap.S())
+	s.now = func() time.Time { return now }
+	s.Sweep(ctx)
+
+	_, err := repo.GetByID(ctx, "expired")
+	assert.Error(t, err)
+
+	for _, id := range []string{"alive", "forever"} {
+		_, err = repo.GetByID(ctx, id)
+		assert.NoError(t, err, id)
+	}
+}
+
+func TestSweeper_Purge(t *testing.T) {
+	ctx := context.Background()
+
+	repo := repository.NewInMemoryRepository()
+	require.NoError(t, repo.SaveBatch(ctx, []models.ShortURL{
+		{OriginalURL: "http://deleted.com", ShortURL: "deleted", CreatedByID: "user"},
+		{OriginalURL: "http://alive.com", ShortURL: "alive", CreatedByID: "user"},
+	}))
+	require.NoError(t, repo.DeleteUrls(ctx, []models.ShortURL{{ShortURL: "deleted", CreatedByID: "user"}}))
+
+	s := New(repo, time.Minute, time.Hour, zap.S())
+
+	// still within grace period
+	s.Sweep(ctx)
+	_, err := repo.GetByID(ctx, "deleted")
+	assert.NoError(t, err)
+
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	s.Sweep(ctx)
+	_, err = repo.GetByID(ctx, "deleted")
+	assert.Error(t, err)
+	_, err = repo.GetByID(ctx, "alive")
+	assert.NoError(t, err)
+}
+
+func TestSweeper_RunDisabled(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		New(repository.NewInMemoryRepository(), 0, 0, zap.S()).Run(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper with zero interval must return immediately")
+	}
+}
@@ -0,0 +1,62 @@
+package tlsutils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/GTedya/shortener/config"
+)
+
+// NewACMEManager создает менеджер сертификатов, выпускающий и продлевающий сертификаты по протоколу ACME
+// для доменов из config.Config.ACMEDomains. Домен подтверждается вызовом tls-alpn-01 на HTTPS-порту сервера.
+// Учетная запись и сертификаты хранятся в config.Config.ACMECacheDir, поэтому переживают перезапуск.
+//
+// Если задан config.Config.ACMECARoot, сервер ACME проверяется по этим сертификатам,
+// это нужно для тестового сервера, например Pebble.
+func NewACMEManager(conf config.Config) (*autocert.Manager, error) {
+	var domains []string
+	for _, domain := range strings.Split(conf.ACMEDomains, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	client := &acme.Client{DirectoryURL: conf.ACMEDirectory}
+	if conf.ACMECARoot != "" {
+		pool, err := loadCertPool(conf.ACMECARoot)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения сертификата сервера ACME: %w", err)
+		}
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			},
+		}
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(conf.ACMECacheDir),
+		Email:      conf.ACMEEmail,
+		Client:     client,
+	}, nil
+}
+
+// ACMEConfig создает конфигурацию TLS сервера, получающую сертификаты от менеджера ACME
+// и отвечающую на вызовы tls-alpn-01.
+func ACMEConfig(conf config.Config, manager *autocert.Manager) (*tls.Config, error) {
+	tlsConfig, err := BaseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	acmeConfig := manager.TLSConfig()
+	tlsConfig.GetCertificate = acmeConfig.GetCertificate
+	tlsConfig.NextProtos = acmeConfig.NextProtos
+	return tlsConfig, nil
+}
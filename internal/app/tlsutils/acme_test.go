@@ -0,0 +1,108 @@
+package tlsutils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+
+	"github.com/GTedya/shortener/config"
+)
+
+func TestNewACMEManager(t *testing.T) {
+	dir := t.TempDir()
+	conf := config.Config{
+		ACMEDomains:   "short.example, www.short.example",
+		ACMEDirectory: "https://acme.example/dir",
+		ACMECacheDir:  filepath.Join(dir, "cache"),
+	}
+
+	t.Run("host policy", func(t *testing.T) {
+		manager, err := NewACMEManager(conf)
+		require.NoError(t, err)
+		assert.Equal(t, "https://acme.example/dir", manager.Client.DirectoryURL)
+		assert.NoError(t, manager.HostPolicy(context.Background(), "www.short.example"))
+		assert.Error(t, manager.HostPolicy(context.Background(), "other.example"))
+	})
+
+	t.Run("tls config", func(t *testing.T) {
+		manager, err := NewACMEManager(conf)
+		require.NoError(t, err)
+		tlsConfig, err := ACMEConfig(config.Config{TLSMinVersion: "1.3"}, manager)
+		require.NoError(t, err)
+		assert.Contains(t, tlsConfig.NextProtos, acme.ALPNProto)
+		assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+		assert.NotNil(t, tlsConfig.GetCertificate)
+	})
+
+	t.Run("invalid CA root", func(t *testing.T) {
+		conf := conf
+		conf.ACMECARoot = filepath.Join(dir, "missing.pem")
+		_, err := NewACMEManager(conf)
+		assert.Error(t, err)
+	})
+}
+
+// TestACMEManager_Pebble obtains a certificate from a local Pebble ACME server.
+// It runs only if PEBBLE_DIRECTORY is set, for example:
+//
+//	pebble -config test/config/pebble-config.json &
+//	PEBBLE_DIRECTORY=https://localhost:14000/dir PEBBLE_CA_ROOT=test/certs/pebble.minica.pem go test ./internal/app/tlsutils
+//
+// Pebble validates the domain with tls-alpn-01 on PEBBLE_TLS_PORT (5001 by default), so the domain
+// (PEBBLE_DOMAIN, localhost by default) must resolve to this host, or run Pebble with PEBBLE_VA_ALWAYS_VALID=1.
+func TestACMEManager_Pebble(t *testing.T) {
+	directory := os.Getenv("PEBBLE_DIRECTORY")
+	if directory == "" {
+		t.Skip("PEBBLE_DIRECTORY is not set")
+	}
+	domain := envOrDefault("PEBBLE_DOMAIN", "localhost")
+
+	conf := config.Config{
+		ACMEDomains:   domain,
+		ACMEDirectory: directory,
+		ACMECacheDir:  t.TempDir(),
+		ACMECARoot:    os.Getenv("PEBBLE_CA_ROOT"),
+	}
+	manager, err := NewACMEManager(conf)
+	require.NoError(t, err)
+	tlsConfig, err := ACMEConfig(conf, manager)
+	require.NoError(t, err)
+
+	// answer tls-alpn-01 challenges
+	listener, err := tls.Listen("tcp", net.JoinHostPort("", envOrDefault("PEBBLE_TLS_PORT", "5001")), tlsConfig)
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = conn.(*tls.Conn).Handshake()
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	cert, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: domain})
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.NoError(t, leaf.VerifyHostname(domain))
+}
+
+func envOrDefault(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,130 @@
+package tlsutils
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CertReloader хранит сертификат сервера и перезагружает его из файлов без перезапуска сервера.
+// Используется в tls.Config.GetCertificate, поэтому новые соединения сразу получают новый сертификат.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+
+	mu      sync.Mutex
+	modTime time.Time // время изменения файлов при последней загрузке
+}
+
+// NewCertReloader загружает сертификат из файлов.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate возвращает текущий сертификат, реализует tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Reload загружает сертификат из файлов. При ошибке продолжает использоваться прежний сертификат.
+func (r *CertReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// время изменения берется до чтения, чтобы изменение во время чтения вызвало еще одну перезагрузку
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+	// поврежденные файлы тоже запоминаются, чтобы не загружать их повторно, пока они не изменятся
+	r.modTime = modTime
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки сертификата: %w", err)
+	}
+
+	r.cert.Store(&cert)
+	return nil
+}
+
+// Watch перезагружает сертификат при получении SIGHUP и при изменении файлов,
+// которое проверяется с периодом interval (0 отключает проверку). Блокируется, пока ctx не завершится.
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration, log *zap.SugaredLogger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	r.watch(ctx, interval, hup, log)
+}
+
+// watch перезагружает сертификат при получении сигнала из hup и при изменении файлов.
+func (r *CertReloader) watch(ctx context.Context, interval time.Duration, hup <-chan os.Signal, log *zap.SugaredLogger) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reload(log, "signal")
+		case <-tick:
+			if r.changed() {
+				r.reload(log, "file change")
+			}
+		}
+	}
+}
+
+// reload перезагружает сертификат, записывая результат в журнал.
+func (r *CertReloader) reload(log *zap.SugaredLogger, reason string) {
+	if err := r.Reload(); err != nil {
+		log.Errorw("certificate reload error", "cert", r.certFile, "reason", reason, "error", err)
+		return
+	}
+	log.Infow("certificate reloaded", "cert", r.certFile, "reason", reason)
+}
+
+// changed сообщает, изменились ли файлы сертификата после последней загрузки.
+func (r *CertReloader) changed() bool {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !modTime.Equal(r.modTime)
+}
+
+// filesModTime возвращает наибольшее время изменения файлов сертификата и ключа.
+func (r *CertReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("ошибка чтения файла сертификата: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,91 @@
+package tlsutils
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// replaceCertificate writes a new self-signed certificate over the files and returns it.
+func replaceCertificate(t *testing.T, certFile, keyFile, host string, modTime time.Time) []byte {
+	t.Helper()
+
+	dir := t.TempDir()
+	newCert, newKey := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, EnsureCertificate(newCert, newKey, host))
+	for src, dst := range map[string]string{newCert: certFile, newKey: keyFile} {
+		data, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(dst, data, 0o600))
+		require.NoError(t, os.Chtimes(dst, modTime, modTime))
+	}
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	return pair.Certificate[0]
+}
+
+// currentCertificate returns DER of the certificate served by the reloader.
+func currentCertificate(t *testing.T, r *CertReloader) []byte {
+	t.Helper()
+
+	cert, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	return cert.Certificate[0]
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	first := replaceCertificate(t, certFile, keyFile, "first.example", time.Now().Add(-time.Hour))
+
+	r, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, first, currentCertificate(t, r))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hup := make(chan os.Signal)
+	go r.watch(ctx, 10*time.Millisecond, hup, zap.S())
+
+	t.Run("file change", func(t *testing.T) {
+		second := replaceCertificate(t, certFile, keyFile, "second.example", time.Now())
+		assert.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual(second, currentCertificate(t, r))
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	future := time.Now().Add(time.Hour)
+
+	t.Run("broken file keeps the certificate", func(t *testing.T) {
+		before := currentCertificate(t, r)
+		require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+		require.NoError(t, os.Chtimes(certFile, future, future))
+
+		hup <- syscall.SIGHUP
+		assert.Equal(t, before, currentCertificate(t, r))
+	})
+
+	t.Run("signal", func(t *testing.T) {
+		// the file time is the same as before, so only the signal reloads the certificate
+		third := replaceCertificate(t, certFile, keyFile, "third.example", future)
+		hup <- syscall.SIGHUP
+		assert.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual(third, currentCertificate(t, r))
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestNewCertReloader_missingFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	assert.Error(t, err)
+}
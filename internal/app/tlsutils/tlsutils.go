@@ -0,0 +1,141 @@
+// Пакет tlsutils предоставляет общую для HTTP- и gRPC-серверов работу с TLS-сертификатами.
+package tlsutils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/kabukky/httpscerts"
+
+	"github.com/GTedya/shortener/config"
+)
+
+// ErrNoCertificates возвращается, если в файле сертификатов удостоверяющих центров нет ни одного сертификата.
+var ErrNoCertificates = errors.New("no certificates in file")
+
+// ErrUnknownVersion возвращается для неизвестной версии TLS.
+var ErrUnknownVersion = errors.New("unknown TLS version")
+
+// ErrUnknownCipherSuite возвращается для неизвестного или небезопасного набора шифров.
+var ErrUnknownCipherSuite = errors.New("unknown TLS cipher suite")
+
+// EnsureCertificate проверяет наличие файлов сертификата и ключа.
+// Если их нет, создает самоподписанный сертификат для хоста из адреса сервера и localhost.
+func EnsureCertificate(certFile, keyFile, address string) error {
+	if err := httpscerts.Check(certFile, keyFile); err == nil {
+		return nil
+	}
+	if err := httpscerts.Generate(certFile, keyFile, certHosts(address)); err != nil {
+		return fmt.Errorf("ошибка создания сертификата: %w", err)
+	}
+	return nil
+}
+
+// BaseConfig создает конфигурацию TLS с минимальной версией и наборами шифров из конфигурации приложения.
+func BaseConfig(conf config.Config) (*tls.Config, error) {
+	version, err := ParseVersion(conf.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := ParseCipherSuites(conf.TLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{MinVersion: version, CipherSuites: suites}, nil
+}
+
+// ServerConfig создает конфигурацию TLS сервера, получающую сертификат из certs,
+// поэтому перезагруженный сертификат используется без перезапуска сервера.
+// Если задан clientCAFile, клиенты обязаны предъявить сертификат, подписанный одним из сертификатов
+// этого файла (взаимный TLS), иначе сертификат клиента не запрашивается.
+func ServerConfig(conf config.Config, certs *CertReloader, clientCAFile string) (*tls.Config, error) {
+	tlsConfig, err := BaseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.GetCertificate = certs.GetCertificate
+	if clientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pool, err := loadCertPool(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения сертификата клиентов: %w", err)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+// ParseVersion возвращает версию TLS по ее номеру, например "1.2". Пустая строка означает TLS 1.2.
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrUnknownVersion, version)
+	}
+}
+
+// ParseCipherSuites возвращает наборы шифров по их именам через запятую, например
+// "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256". Допускаются только безопасные наборы из tls.CipherSuites.
+// Наборы шифров TLS 1.3 не настраиваются. Пустая строка означает наборы Go по умолчанию.
+func ParseCipherSuites(names string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownCipherSuite, name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+// loadCertPool загружает сертификаты из PEM-файла.
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%w: %s", ErrNoCertificates, file)
+	}
+	return pool, nil
+}
+
+// certHosts возвращает хосты самоподписанного сертификата через запятую.
+// Для адреса без хоста или с неуказанным IP сертификат выдается на localhost.
+func certHosts(address string) string {
+	hosts := []string{"localhost", "127.0.0.1"}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() || slices.Contains(hosts, host) {
+		return strings.Join(hosts, ",")
+	}
+	return strings.Join(append([]string{host}, hosts...), ",")
+}
@@ -0,0 +1,105 @@
+package tlsutils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GTedya/shortener/config"
+)
+
+func TestEnsureCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	require.NoError(t, EnsureCertificate(certFile, keyFile, "example.com:3200"))
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	require.NoError(t, err)
+	assert.NoError(t, cert.VerifyHostname("example.com"))
+	assert.NoError(t, cert.VerifyHostname("localhost"))
+
+	// existing certificate is kept
+	before, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	require.NoError(t, EnsureCertificate(certFile, keyFile, ":3200"))
+	after, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+func TestServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, EnsureCertificate(certFile, keyFile, "localhost:3200"))
+	certs, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	t.Run("without client CA", func(t *testing.T) {
+		conf, err := ServerConfig(config.Config{}, certs, "")
+		require.NoError(t, err)
+		assert.Equal(t, tls.NoClientCert, conf.ClientAuth)
+		assert.Equal(t, uint16(tls.VersionTLS12), conf.MinVersion)
+
+		cert, err := conf.GetCertificate(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		assert.NotNil(t, cert)
+	})
+
+	t.Run("with client CA", func(t *testing.T) {
+		conf, err := ServerConfig(config.Config{}, certs, certFile)
+		require.NoError(t, err)
+		assert.Equal(t, tls.RequireAndVerifyClientCert, conf.ClientAuth)
+		assert.NotNil(t, conf.ClientCAs)
+	})
+
+	t.Run("invalid client CA", func(t *testing.T) {
+		_, err := ServerConfig(config.Config{}, certs, keyFile)
+		assert.ErrorIs(t, err, ErrNoCertificates)
+	})
+
+	t.Run("version and cipher suites", func(t *testing.T) {
+		conf, err := ServerConfig(config.Config{
+			TLSMinVersion:   "1.3",
+			TLSCipherSuites: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		}, certs, "")
+		require.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS13), conf.MinVersion)
+		assert.Equal(t, []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		}, conf.CipherSuites)
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		_, err := ServerConfig(config.Config{TLSMinVersion: "2.0"}, certs, "")
+		assert.ErrorIs(t, err, ErrUnknownVersion)
+	})
+
+	t.Run("insecure cipher suite", func(t *testing.T) {
+		_, err := ServerConfig(config.Config{TLSCipherSuites: "TLS_RSA_WITH_RC4_128_SHA"}, certs, "")
+		assert.ErrorIs(t, err, ErrUnknownCipherSuite)
+	})
+}
+
+func TestCertHosts(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{address: ":8080", want: "localhost,127.0.0.1"},
+		{address: "0.0.0.0:8080", want: "localhost,127.0.0.1"},
+		{address: "localhost:3200", want: "localhost,127.0.0.1"},
+		{address: "10.0.0.1:3200", want: "10.0.0.1,localhost,127.0.0.1"},
+		{address: "example.com", want: "example.com,localhost,127.0.0.1"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, certHosts(tt.address), tt.address)
+	}
+}
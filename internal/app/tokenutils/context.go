@@ -0,0 +1,33 @@
+package tokenutils
+
+import "context"
+
+// userIDKey - ключ контекста, под которым хранится идентификатор аутентифицированного пользователя.
+type userIDKey struct{}
+
+// WithUserID возвращает копию контекста с идентификатором аутентифицированного пользователя.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext возвращает идентификатор пользователя, сохраненный в контексте WithUserID.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(string)
+	return userID, ok && userID != ""
+}
+
+// apiKeyUserKey - ключ контекста, отмечающий, что пользователь аутентифицирован по API-ключу.
+type apiKeyUserKey struct{}
+
+// WithAPIKeyUserID возвращает копию контекста с идентификатором владельца API-ключа.
+// Такой пользователь не получает токен пользователя, иначе ключ можно было бы обменять
+// на токен, который продолжает действовать после отзыва ключа.
+func WithAPIKeyUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(WithUserID(ctx, userID), apiKeyUserKey{}, true)
+}
+
+// IsAPIKeyUser сообщает, аутентифицирован ли пользователь контекста по API-ключу (см. WithAPIKeyUserID).
+func IsAPIKeyUser(ctx context.Context) bool {
+	fromKey, _ := ctx.Value(apiKeyUserKey{}).(bool)
+	return fromKey
+}
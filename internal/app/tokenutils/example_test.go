@@ -2,19 +2,21 @@ package tokenutils
 
 import (
 	"fmt"
+
+	"github.com/GTedya/shortener/config"
 )
 
 // ExampleDecrypt demonstrates how to use the Encrypt and Decrypt functions.
 func ExampleDecrypt() {
 	// Encrypt a user ShortURL
-	encryptedUserID, err := Encrypt("userID", "abc&1*~#^2^#s0^=)^^7%b34")
+	encryptedUserID, err := Encrypt("userID", "secret_key")
 	if err != nil {
 		fmt.Println("Encryption Error:", err)
 		return
 	}
 
 	// Decrypt the encrypted user ShortURL
-	decryptedUserID, err := Decrypt(encryptedUserID, "abc&1*~#^2^#s0^=)^^7%b34")
+	decryptedUserID, err := Decrypt(encryptedUserID, "secret_key")
 	if err != nil {
 		fmt.Println("Decryption Error:", err)
 		return
@@ -23,3 +25,25 @@ func ExampleDecrypt() {
 	fmt.Println("Decrypted UserID:", decryptedUserID)
 	// Output: Decrypted UserID: userID
 }
+
+// ExampleKeyring demonstrates how to issue and verify user tokens during key rotation.
+func ExampleKeyring() {
+	// The token is issued while only one key is configured.
+	old, _ := NewKeyring(config.Config{SecretKeys: "k1:first_secret"})
+	token, err := old.Issue("userID")
+	if err != nil {
+		fmt.Println("Token Error:", err)
+		return
+	}
+
+	// After a newer key is added the token is still valid, but should be re-issued.
+	rotated, _ := NewKeyring(config.Config{SecretKeys: "k1:first_secret,k2:second_secret"})
+	userID, rotate, err := rotated.Parse(token)
+	if err != nil {
+		fmt.Println("Token Error:", err)
+		return
+	}
+
+	fmt.Println("UserID:", userID, "rotate:", rotate)
+	// Output: UserID: userID rotate: true
+}
@@ -0,0 +1,118 @@
+package tokenutils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GTedya/shortener/config"
+)
+
+// keyIDSeparator отделяет идентификатор ключа от зашифрованной части токена.
+const keyIDSeparator = "."
+
+// ErrNoKeys возвращается NewKeyring, если в конфигурации нет ни одного секретного ключа.
+var ErrNoKeys = errors.New("no secret keys configured")
+
+// ErrInvalidKey возвращается NewKeyring для неверно заданного ключа.
+var ErrInvalidKey = errors.New("invalid secret key")
+
+// Key - секретный ключ токенов с его идентификатором.
+// Токены ключа с пустым идентификатором, например config.Config.SecretKey, не содержат идентификатора.
+type Key struct {
+	ID     string
+	Secret string
+}
+
+// Keyring хранит действующие ключи токенов пользователей.
+// Токены выдаются самым новым ключом, а проверяются любым из действующих,
+// поэтому смена ключа не приводит к выходу пользователей: чтобы вывести ключ из обращения,
+// его достаточно удалить из конфигурации после того, как выданные им токены будут перевыпущены.
+type Keyring struct {
+	keys []Key // от старого к новому
+}
+
+// NewKeyring создает набор ключей из конфигурации.
+// Если задан config.Config.SecretKeys, используются только ключи из него в формате "id:secret,id:secret",
+// последний из которых - самый новый, а config.Config.SecretKey выводится из обращения. Чтобы во время
+// смены ключа принимать токены без идентификатора, выданные config.Config.SecretKey, его указывают
+// в списке с пустым идентификатором: ":secret,id:secret". Иначе используется только config.Config.SecretKey.
+func NewKeyring(conf config.Config) (*Keyring, error) {
+	keys, err := ParseKeys(conf.SecretKeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 && conf.SecretKey != "" {
+		keys = append(keys, Key{Secret: conf.SecretKey})
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	return &Keyring{keys: keys}, nil
+}
+
+// ParseKeys разбирает список ключей в формате "id:secret,id:secret".
+// Пустой идентификатор допускается для ключа токенов без идентификатора, см. NewKeyring.
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(item, ":")
+		if !ok || secret == "" || strings.Contains(id, keyIDSeparator) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKey, id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("%w: duplicate id %q", ErrInvalidKey, id)
+		}
+		seen[id] = true
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+	return keys, nil
+}
+
+// Issue создает токен пользователя самым новым ключом.
+func (k *Keyring) Issue(userID string) (string, error) {
+	return k.issue(userID, time.Now().Add(TokenLifetime))
+}
+
+// Parse проверяет токен и возвращает идентификатор пользователя из него.
+// rotate равен true, если токен выдан не самым новым ключом и его следует перевыпустить.
+// Токены ключей, которых нет в наборе, считаются недействительными.
+func (k *Keyring) Parse(token string) (userID string, rotate bool, err error) {
+	id, payload, ok := strings.Cut(token, keyIDSeparator)
+	if !ok {
+		id, payload = "", token
+	}
+
+	for i, key := range k.keys {
+		if key.ID != id {
+			continue
+		}
+		userID, err = parseToken(payload, key.Secret)
+		if err != nil {
+			return "", false, err
+		}
+		return userID, i != len(k.keys)-1, nil
+	}
+	return "", false, ErrInvalidToken
+}
+
+// issue создает токен самым новым ключом, действительный до expiresAt.
+func (k *Keyring) issue(userID string, expiresAt time.Time) (string, error) {
+	key := k.keys[len(k.keys)-1]
+	token, err := newToken(userID, key.Secret, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	if key.ID == "" {
+		return token, nil
+	}
+	return key.ID + keyIDSeparator + token, nil
+}
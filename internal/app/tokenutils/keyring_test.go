@@ -0,0 +1,98 @@
+package tokenutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GTedya/shortener/config"
+)
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name string
+		conf config.Config
+		err  error
+	}{
+		{name: "legacy secret", conf: config.Config{SecretKey: "secret"}},
+		{name: "key list", conf: config.Config{SecretKeys: "k1:first, k2:second"}},
+		{name: "no keys", conf: config.Config{}, err: ErrNoKeys},
+		{name: "missing secret", conf: config.Config{SecretKeys: "k1:"}, err: ErrInvalidKey},
+		{name: "key without id", conf: config.Config{SecretKeys: ":legacy,k1:first"}},
+		{name: "missing separator", conf: config.Config{SecretKeys: "first"}, err: ErrInvalidKey},
+		{name: "duplicate empty id", conf: config.Config{SecretKeys: ":first,:second"}, err: ErrInvalidKey},
+		{name: "separator in id", conf: config.Config{SecretKeys: "k.1:first"}, err: ErrInvalidKey},
+		{name: "duplicate id", conf: config.Config{SecretKeys: "k1:first,k1:second"}, err: ErrInvalidKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys, err := NewKeyring(test.conf)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, keys)
+		})
+	}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	legacy, err := NewKeyring(config.Config{SecretKey: "legacy"})
+	require.NoError(t, err)
+	first, err := NewKeyring(config.Config{SecretKey: "other", SecretKeys: ":legacy,k1:first"})
+	require.NoError(t, err)
+	second, err := NewKeyring(config.Config{SecretKeys: "k1:first,k2:second"})
+	require.NoError(t, err)
+	retired, err := NewKeyring(config.Config{SecretKeys: "k2:second"})
+	require.NoError(t, err)
+
+	legacyToken, err := legacy.Issue("user")
+	require.NoError(t, err)
+	assert.NotContains(t, legacyToken, keyIDSeparator)
+
+	t.Run("old key is accepted and rotated", func(t *testing.T) {
+		userID, rotate, err := first.Parse(legacyToken)
+		require.NoError(t, err)
+		assert.Equal(t, "user", userID)
+		assert.True(t, rotate)
+	})
+
+	token, err := first.Issue("user")
+	require.NoError(t, err)
+
+	t.Run("newest key is used to issue", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(token, "k1"+keyIDSeparator))
+
+		userID, rotate, err := first.Parse(token)
+		require.NoError(t, err)
+		assert.Equal(t, "user", userID)
+		assert.False(t, rotate)
+	})
+
+	t.Run("secret key is retired by key list", func(t *testing.T) {
+		listed, err := NewKeyring(config.Config{SecretKey: "legacy", SecretKeys: "k1:first"})
+		require.NoError(t, err)
+
+		_, _, err = listed.Parse(legacyToken)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+		_, _, err = listed.Parse(token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("retired key is rejected", func(t *testing.T) {
+		_, _, err := second.Parse(legacyToken)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+
+		_, _, err = retired.Parse(token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("forged key id is rejected", func(t *testing.T) {
+		_, _, err := second.Parse("k2" + token[len("k1"):])
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
@@ -1,103 +1,161 @@
 // Пакет tokenutils предоставляет функции для работы с зашифрованными идентификаторами пользователей в виде куки.
+//
+// Токен пользователя - это зашифрованные AES-GCM утверждения (идентификатор пользователя и срок действия),
+// закодированные в base64url и снабженные идентификатором ключа. Ключи шифрования получаются
+// из секретных ключей конфигурации, поэтому токены, выданные HTTP и gRPC серверами, взаимозаменяемы.
 package tokenutils
 
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
-	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-// mySecret - это секретный ключ, используемый для шифрования и дешифрования.
-const mySecret = "abc&1*~#^2^#s0^=)^^7%b34"
-
 // UserIDCookieName - имя куки, в которой хранится зашифрованный идентификатор пользователя.
 const UserIDCookieName = "user-id"
 
-// GetUserID извлекает расшифрованный идентификатор пользователя из куки запроса.
-// Если куки отсутствует или расшифровка не удалась, создается и возвращается новый UUID.
-func GetUserID(r *http.Request) string {
-	encodedCookie, err := r.Cookie(UserIDCookieName)
-	if err != nil {
-		return uuid.NewString()
+// TokenLifetime - срок действия выдаваемого токена пользователя.
+const TokenLifetime = 30 * 24 * time.Hour
+
+// ErrInvalidToken возвращается, если токен поврежден или зашифрован другим ключом.
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrTokenExpired возвращается, если срок действия токена истек.
+var ErrTokenExpired = errors.New("token expired")
+
+// claims - содержимое токена пользователя.
+type claims struct {
+	UserID    string `json:"uid"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// GetUserID возвращает идентификатор пользователя, уже аутентифицированного посредником (например, по API-ключу),
+// или извлекает расшифрованный идентификатор пользователя из куки запроса.
+// Если куки отсутствует, токен недействителен или его срок истек, создается и возвращается новый UUID.
+func GetUserID(r *http.Request, keys *Keyring) string {
+	if userID, ok := UserIDFromContext(r.Context()); ok {
+		return userID
 	}
 
-	decodedCookie, err := hex.DecodeString(encodedCookie.Value)
+	cookie, err := r.Cookie(UserIDCookieName)
 	if err != nil {
 		return uuid.NewString()
 	}
 
-	decryptedUserID, err := Decrypt(string(decodedCookie), mySecret)
+	userID, _, err := keys.Parse(cookie.Value)
 	if err != nil {
 		return uuid.NewString()
 	}
 
-	return decryptedUserID
+	return userID
 }
 
 // AddEncryptedUserIDToCookie добавляет зашифрованный идентификатор пользователя в куки ответа.
-// Идентификатор пользователя шифруется с использованием шифрования AES с заданным секретным ключом.
-func AddEncryptedUserIDToCookie(w *http.ResponseWriter, userID string) error {
-	encryptedUserID, err := Encrypt(userID, mySecret)
+// Токен выдается самым новым ключом, срок действия куки совпадает со сроком действия токена.
+func AddEncryptedUserIDToCookie(w *http.ResponseWriter, userID string, keys *Keyring) error {
+	expiresAt := time.Now().Add(TokenLifetime)
+	token, err := keys.issue(userID, expiresAt)
 	if err != nil {
-		return fmt.Errorf("ошибка шифрования: %w", err)
+		return fmt.Errorf("ошибка создания токена: %w", err)
 	}
 
-	encodedCookieValue := hex.EncodeToString([]byte(encryptedUserID))
-
 	http.SetCookie(
 		*w,
 		&http.Cookie{
-			Name:  UserIDCookieName,
-			Value: encodedCookieValue,
+			Name:     UserIDCookieName,
+			Value:    token,
+			Path:     "/",
+			Expires:  expiresAt,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
 		},
 	)
 	return nil
 }
 
-// Decode декодирует строку base64 в байтовый срез.
-func Decode(s string) []byte {
-	data, err := base64.StdEncoding.DecodeString(s)
+// parseToken проверяет зашифрованную часть токена и возвращает идентификатор пользователя из нее.
+// Возвращает ErrInvalidToken для поврежденного или чужого токена и ErrTokenExpired для просроченного.
+func parseToken(token, secret string) (string, error) {
+	plainText, err := Decrypt(token, secret)
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+
+	var c claims
+	if err = json.Unmarshal([]byte(plainText), &c); err != nil || c.UserID == "" {
+		return "", ErrInvalidToken
+	}
+	if time.Now().Unix() >= c.ExpiresAt {
+		return "", ErrTokenExpired
 	}
-	return data
+	return c.UserID, nil
 }
 
-// Decrypt дешифрует заданный текст с использованием расшифровки AES с заданным секретным ключом.
-func Decrypt(text, mySecret string) (string, error) {
-	block, err := aes.NewCipher([]byte(mySecret))
+// newToken создает зашифрованную часть токена пользователя, действительную до expiresAt.
+func newToken(userID, secret string, expiresAt time.Time) (string, error) {
+	data, err := json.Marshal(claims{UserID: userID, ExpiresAt: expiresAt.Unix()})
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания нового шифра: %w", err)
+		return "", fmt.Errorf("ошибка сериализации токена: %w", err)
 	}
-	cipherText := Decode(text)
-	cfb := cipher.NewCFBDecrypter(block, someBytes)
-	plainText := make([]byte, len(cipherText))
-	cfb.XORKeyStream(plainText, cipherText)
-	return string(plainText), nil
+	return Encrypt(string(data), secret)
 }
 
-// Encrypt шифрует заданный текст с использованием шифрования AES с заданным секретным ключом.
-func Encrypt(text, mySecret string) (string, error) {
-	block, err := aes.NewCipher([]byte(mySecret))
+// Decrypt расшифровывает текст, зашифрованный Encrypt с тем же секретным ключом.
+// Возвращает ErrInvalidToken, если текст поврежден или зашифрован другим ключом.
+func Decrypt(text, secret string) (string, error) {
+	aead, err := newAEAD(secret)
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания нового шифра: %w", err)
+		return "", err
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(text)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", ErrInvalidToken
 	}
-	plainText := []byte(text)
-	cfb := cipher.NewCFBEncrypter(block, someBytes)
-	cipherText := make([]byte, len(plainText))
-	cfb.XORKeyStream(cipherText, plainText)
-	return Encode(cipherText), nil
+
+	nonce, cipherText := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plainText, err := aead.Open(nil, nonce, cipherText, nil)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	return string(plainText), nil
 }
 
-// someBytes используется в качестве вектора инициализации (IV) для шифрования AES.
-var someBytes = []byte{35, 46, 57, 24, 85, 35, 24, 74, 87, 35, 88, 98, 66, 32, 14, 05}
+// Encrypt шифрует текст AES-GCM со случайным nonce и кодирует результат в base64url.
+func Encrypt(text, secret string) (string, error) {
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("ошибка генерации nonce: %w", err)
+	}
+
+	cipherText := aead.Seal(nonce, nonce, []byte(text), nil)
+	return base64.RawURLEncoding.EncodeToString(cipherText), nil
+}
 
-// Encode кодирует байтовый срез в строку base64.
-func Encode(b []byte) string {
-	return base64.StdEncoding.EncodeToString(b)
+// newAEAD создает шифр AES-256-GCM с ключом, полученным из секретного ключа хешированием SHA-256.
+func newAEAD(secret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания нового шифра: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания GCM: %w", err)
+	}
+	return aead, nil
 }
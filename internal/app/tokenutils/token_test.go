@@ -0,0 +1,77 @@
+package tokenutils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GTedya/shortener/config"
+)
+
+func TestParseToken(t *testing.T) {
+	const secret = "secret_key"
+
+	token, err := newToken("user", secret, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		userID, err := parseToken(token, secret)
+		require.NoError(t, err)
+		assert.Equal(t, "user", userID)
+	})
+
+	t.Run("other secret", func(t *testing.T) {
+		_, err := parseToken(token, "other_key")
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		b := []byte(token)
+		i := len(b) / 2
+		if b[i] == 'A' {
+			b[i] = 'B'
+		} else {
+			b[i] = 'A'
+		}
+		_, err := parseToken(string(b), secret)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		_, err := parseToken("not a token", secret)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired, err := newToken("user", secret, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+		_, err = parseToken(expired, secret)
+		assert.ErrorIs(t, err, ErrTokenExpired)
+	})
+}
+
+func TestGetUserID(t *testing.T) {
+	keys, err := NewKeyring(config.Config{SecretKey: "secret_key"})
+	require.NoError(t, err)
+	other, err := NewKeyring(config.Config{SecretKey: "other_key"})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	var w http.ResponseWriter = recorder
+	require.NoError(t, AddEncryptedUserIDToCookie(&w, "user", keys))
+
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, UserIDCookieName, cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	assert.Equal(t, "user", GetUserID(r, keys))
+	assert.NotEqual(t, "user", GetUserID(r, other))
+	assert.NotEmpty(t, GetUserID(httptest.NewRequest(http.MethodGet, "/", nil), keys))
+}